@@ -18,8 +18,17 @@ import (
 	"github.com/clumineau/pareto/apps/api/internal/shared/database"
 	"github.com/clumineau/pareto/apps/api/internal/shared/cache"
 
+	alertsDomain "github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	alertsHandler "github.com/clumineau/pareto/apps/api/internal/alerts/handler"
+	alertsNotifier "github.com/clumineau/pareto/apps/api/internal/alerts/notifier"
+	alertsRepository "github.com/clumineau/pareto/apps/api/internal/alerts/repository"
+	alertsService "github.com/clumineau/pareto/apps/api/internal/alerts/service"
 	catalogHandler "github.com/clumineau/pareto/apps/api/internal/catalog/handler"
+	catalogRepository "github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+	catalogService "github.com/clumineau/pareto/apps/api/internal/catalog/service"
 	compareHandler "github.com/clumineau/pareto/apps/api/internal/compare/handler"
+	compareRepository "github.com/clumineau/pareto/apps/api/internal/compare/repository"
+	compareService "github.com/clumineau/pareto/apps/api/internal/compare/service"
 )
 
 func main() {
@@ -32,6 +41,12 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 	log.Info().Str("env", cfg.Env).Msg("Starting Pareto API")
+	if len(cfg.IngestAPIKeys) == 0 {
+		log.Warn().Msg("INGEST_API_KEYS is not set, offer ingestion is disabled")
+	}
+	if len(cfg.AdminAPIKeys) == 0 {
+		log.Warn().Msg("ADMIN_API_KEYS is not set, catalog admin writes are disabled")
+	}
 
 	// Initialize database
 	db, err := database.New(cfg.DatabaseURL)
@@ -44,6 +59,38 @@ func main() {
 	redisClient := cache.New(cfg.RedisURL)
 	defer redisClient.Close()
 
+	// Initialize repositories and services
+	productRepo := catalogRepository.NewPostgresProductRepository(db)
+	variantRepo := catalogRepository.NewPostgresVariantRepository(db)
+	offerRepo := catalogRepository.NewPostgresOfferRepository(db)
+	categoryRepo := catalogRepository.NewPostgresCategoryRepository(db)
+	retailerRepo := catalogRepository.NewPostgresRetailerRepository(db)
+
+	catalogSvc := catalogService.NewCatalogService(productRepo, variantRepo, offerRepo, categoryRepo, retailerRepo, redisClient, cfg.UnknownAttributes)
+
+	// Price alert channels: email needs an SMTP server, the log sink is for
+	// development only
+	notifiers := alertsNotifier.Registry{
+		alertsDomain.ChannelWebhook: alertsNotifier.NewWebhook(),
+	}
+	if cfg.SMTPAddr != "" {
+		notifiers[alertsDomain.ChannelEmail] = alertsNotifier.NewEmail(cfg.SMTPAddr, cfg.AlertEmailFrom)
+	}
+	if cfg.Env != "production" {
+		notifiers[alertsDomain.ChannelLog] = alertsNotifier.NewLog()
+	}
+	alertSvc := alertsService.NewAlertService(alertsRepository.NewPostgresRepository(db), notifiers)
+
+	compareSvc := compareService.NewCompareService(compareRepository.NewPostgresRepository(db))
+
+	// Background workers stop when the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go catalogSvc.RunSchemaMigrations(workerCtx)
+	go catalogSvc.RunStalenessSweeper(workerCtx)
+	go alertSvc.RunWorker(workerCtx)
+
 	// Setup router
 	r := chi.NewRouter()
 
@@ -74,12 +121,16 @@ func main() {
 	// API v1 routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Catalog routes
-		r.Mount("/products", catalogHandler.NewRouter(db, redisClient))
-		r.Mount("/categories", catalogHandler.NewCategoryRouter(db))
-		r.Mount("/retailers", catalogHandler.NewRetailerRouter(db))
+		r.Mount("/products", catalogHandler.NewRouter(catalogSvc, redisClient))
+		r.Mount("/categories", catalogHandler.NewCategoryRouter(catalogSvc, cfg.AdminAPIKeys))
+		r.Mount("/retailers", catalogHandler.NewRetailerRouter(catalogSvc, cfg.AdminAPIKeys))
+		r.Mount("/offers", catalogHandler.NewOfferRouter(catalogSvc, cfg.IngestAPIKeys))
+
+		// Price alert routes
+		r.Mount("/alerts", alertsHandler.NewRouter(alertSvc))
 
 		// Comparison routes
-		r.Mount("/compare", compareHandler.NewRouter(db, redisClient))
+		r.Mount("/compare", compareHandler.NewRouter(compareSvc))
 
 		// Health endpoint (for API namespace)
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -111,6 +162,7 @@ func main() {
 
 	<-shutdown
 	log.Info().Msg("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
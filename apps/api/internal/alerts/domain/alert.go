@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notification channels
+const (
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+	// ChannelLog writes notifications to the API log; meant for tests and
+	// local development
+	ChannelLog = "log"
+)
+
+// Notification delivery outcomes
+const (
+	NotificationSent   = "sent"
+	NotificationFailed = "failed"
+)
+
+// Subscription asks to be notified when the best price of a product, or of
+// one of its variants, falls to TargetPrice or DropPercent below
+// ReferencePrice. Exactly one of TargetPrice and DropPercent is set.
+type Subscription struct {
+	ID             string   `json:"id" db:"id"`
+	ProductID      string   `json:"productId" db:"product_id"`
+	VariantID      *string  `json:"variantId,omitempty" db:"variant_id"`
+	TargetPrice    *float64 `json:"targetPrice,omitempty" db:"target_price"`
+	DropPercent    *int     `json:"dropPercent,omitempty" db:"drop_percent"`
+	ReferencePrice *float64 `json:"referencePrice,omitempty" db:"reference_price"`
+	Channel        string   `json:"channel" db:"channel"`
+	Target         string   `json:"target" db:"target"`
+	Active         bool     `json:"active" db:"active"`
+
+	// LastNotifiedPrice is the price of the last notification: the
+	// subscription fires again only below it
+	LastNotifiedPrice *float64   `json:"lastNotifiedPrice,omitempty" db:"last_notified_price"`
+	LastNotifiedAt    *time.Time `json:"lastNotifiedAt,omitempty" db:"last_notified_at"`
+	// FailedDeliveries counts the consecutive failed deliveries; the next
+	// attempt waits until RetryAt
+	FailedDeliveries int        `json:"failedDeliveries" db:"failed_deliveries"`
+	RetryAt          *time.Time `json:"retryAt,omitempty" db:"retry_at"`
+
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// Threshold returns the price at or below which the subscription fires
+func (s *Subscription) Threshold() float64 {
+	if s.TargetPrice != nil {
+		return *s.TargetPrice
+	}
+	if s.DropPercent != nil && s.ReferencePrice != nil {
+		return *s.ReferencePrice * float64(100-*s.DropPercent) / 100
+	}
+	return 0
+}
+
+// Triggered reports whether price should be notified
+func (s *Subscription) Triggered(price float64) bool {
+	return s.Active && price <= s.Threshold() &&
+		(s.LastNotifiedPrice == nil || price < *s.LastNotifiedPrice)
+}
+
+// Failed delivery handling: a failed notification is retried after a delay
+// doubling with each consecutive failure, and the subscription is
+// deactivated after MaxDeliveryFailures of them
+const (
+	MaxDeliveryFailures = 5
+	deliveryRetryDelay  = 5 * time.Minute
+	maxDeliveryRetry    = 6 * time.Hour
+)
+
+// DeliveryBackoff returns the wait before retrying a subscription whose last
+// failures deliveries failed
+func DeliveryBackoff(failures int) time.Duration {
+	delay := deliveryRetryDelay
+	for i := 1; i < failures && delay < maxDeliveryRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDeliveryRetry)
+}
+
+// CreateSubscriptionRequest represents a request to subscribe to price drops.
+// ReferencePrice defaults to the current best price.
+type CreateSubscriptionRequest struct {
+	ProductID      string   `json:"productId" validate:"required,uuid"`
+	VariantID      *string  `json:"variantId,omitempty" validate:"omitempty,uuid"`
+	TargetPrice    *float64 `json:"targetPrice,omitempty" validate:"omitempty,gte=0"`
+	DropPercent    *int     `json:"dropPercent,omitempty" validate:"omitempty,gte=1,lte=99"`
+	ReferencePrice *float64 `json:"referencePrice,omitempty" validate:"omitempty,gte=0"`
+	Channel        string   `json:"channel" validate:"required,oneof=webhook email log"`
+	Target         string   `json:"target" validate:"required,max=2000"`
+}
+
+// UpdateSubscriptionRequest represents a request to update a subscription.
+// Setting TargetPrice or DropPercent replaces the condition and re-arms it;
+// re-arming or reactivating it also clears its failed deliveries.
+type UpdateSubscriptionRequest struct {
+	TargetPrice    *float64 `json:"targetPrice,omitempty" validate:"omitempty,gte=0"`
+	DropPercent    *int     `json:"dropPercent,omitempty" validate:"omitempty,gte=1,lte=99"`
+	ReferencePrice *float64 `json:"referencePrice,omitempty" validate:"omitempty,gte=0"`
+	Active         *bool    `json:"active,omitempty"`
+}
+
+// CheckTarget reports what is wrong with target as the address of channel,
+// or returns an empty string when it is usable
+func CheckTarget(channel, target string) string {
+	switch channel {
+	case ChannelWebhook:
+		u, err := url.ParseRequestURI(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be a valid http(s) URL for webhook notifications"
+		}
+		// Names are resolved, and checked again, when the webhook is called
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if addr, err := netip.ParseAddr(host); (err == nil && !PublicAddr(addr)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return "must not point to a local or private address"
+		}
+	case ChannelEmail:
+		addr, err := mail.ParseAddress(target)
+		if err != nil || addr.Address != target {
+			return "must be a valid email address for email notifications"
+		}
+	}
+	return ""
+}
+
+// nonPublicPrefixes are the ranges, beyond those the netip predicates cover,
+// that webhooks must not reach: "this network", shared address space
+// (carrier-grade NAT) and the NAT64 prefix, which can embed any IPv4 address
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// PublicAddr reports whether addr is a globally routable unicast address,
+// not a loopback, link-local, private or otherwise internal one
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Trigger is a subscription whose condition is met by the current best offer
+type Trigger struct {
+	Subscription Subscription
+	ProductName  string
+	RetailerID   string
+	OfferURL     string
+	Price        float64
+}
+
+// Notification is what notifiers deliver
+type Notification struct {
+	SubscriptionID string    `json:"subscriptionId"`
+	ProductID      string    `json:"productId"`
+	ProductName    string    `json:"productName"`
+	VariantID      *string   `json:"variantId,omitempty"`
+	RetailerID     string    `json:"retailerId"`
+	OfferURL       string    `json:"offerUrl"`
+	Price          float64   `json:"price"`
+	Threshold      float64   `json:"threshold"`
+	TriggeredAt    time.Time `json:"triggeredAt"`
+
+	// Target is the channel address, not part of the payload
+	Target string `json:"-"`
+}
+
+// NewNotification builds the notification of a trigger
+func NewNotification(t *Trigger, at time.Time) *Notification {
+	return &Notification{
+		SubscriptionID: t.Subscription.ID,
+		ProductID:      t.Subscription.ProductID,
+		ProductName:    t.ProductName,
+		VariantID:      t.Subscription.VariantID,
+		RetailerID:     t.RetailerID,
+		OfferURL:       t.OfferURL,
+		Price:          t.Price,
+		Threshold:      t.Subscription.Threshold(),
+		TriggeredAt:    at,
+		Target:         t.Subscription.Target,
+	}
+}
+
+// Summary is a one-line description of the notification
+func (n *Notification) Summary() string {
+	return fmt.Sprintf("%s is now %.2f EUR at %s", n.ProductName, n.Price, n.RetailerID)
+}
+
+// Delivery records one attempt to deliver a notification
+type Delivery struct {
+	ID             int64     `json:"id" db:"id"`
+	SubscriptionID string    `json:"subscriptionId" db:"subscription_id"`
+	RetailerID     string    `json:"retailerId" db:"retailer_id"`
+	Price          float64   `json:"price" db:"price"`
+	Status         string    `json:"status" db:"status"`
+	Error          *string   `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+}
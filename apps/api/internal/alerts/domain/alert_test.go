@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCheckTarget(t *testing.T) {
+	tests := []struct {
+		channel, target string
+		ok              bool
+	}{
+		{ChannelWebhook, "https://hooks.example.com/pareto", true},
+		{ChannelWebhook, "http://93.184.215.14:8080/hook", true},
+		{ChannelWebhook, "ftp://hooks.example.com/pareto", false},
+		{ChannelWebhook, "hooks.example.com/pareto", false},
+		{ChannelWebhook, "http://localhost:8080/hook", false},
+		{ChannelWebhook, "http://LOCALHOST./hook", false},
+		{ChannelWebhook, "http://api.localhost/hook", false},
+		{ChannelWebhook, "http://127.0.0.1/hook", false},
+		{ChannelWebhook, "http://[::1]/hook", false},
+		{ChannelWebhook, "http://169.254.169.254/latest/meta-data", false},
+		{ChannelWebhook, "http://10.0.0.5/hook", false},
+		{ChannelWebhook, "http://192.168.1.1/hook", false},
+		{ChannelWebhook, "http://[::ffff:10.0.0.5]/hook", false},
+		{ChannelEmail, "ana@example.com", true},
+		{ChannelEmail, "Ana <ana@example.com>", false},
+		{ChannelLog, "anything", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel+" "+tt.target, func(t *testing.T) {
+			if msg := CheckTarget(tt.channel, tt.target); (msg == "") != tt.ok {
+				t.Errorf("CheckTarget = %q, want ok %v", msg, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.0.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+	for _, tt := range tests {
+		if got := PublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{MaxDeliveryFailures, 80 * time.Minute},
+		{8, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := DeliveryBackoff(tt.failures); got != tt.want {
+			t.Errorf("DeliveryBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/httpx"
+)
+
+// AlertService is the price alert business logic used by the handlers
+type AlertService interface {
+	Subscribe(ctx context.Context, req *domain.CreateSubscriptionRequest) (*domain.Subscription, error)
+	GetSubscription(ctx context.Context, id string) (*domain.Subscription, error)
+	UpdateSubscription(ctx context.Context, id string, req *domain.UpdateSubscriptionRequest) (*domain.Subscription, error)
+	Unsubscribe(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, id string) ([]domain.Delivery, error)
+}
+
+// NewRouter creates a new price alert router. Subscriptions are addressed by
+// their unguessable ID, which is returned only to the subscriber.
+func NewRouter(svc AlertService) http.Handler {
+	r := chi.NewRouter()
+
+	h := &AlertHandler{svc: svc}
+
+	r.Post("/", h.Subscribe)
+	r.Get("/{id}", h.GetByID)
+	r.Put("/{id}", h.Update)
+	r.Delete("/{id}", h.Unsubscribe)
+	r.Get("/{id}/deliveries", h.ListDeliveries)
+
+	return r
+}
+
+// AlertHandler handles price alert requests
+type AlertHandler struct {
+	svc AlertService
+}
+
+// Subscribe creates a price alert subscription
+func (h *AlertHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateSubscriptionRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	sub, err := h.svc.Subscribe(r.Context(), &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusCreated, sub)
+}
+
+// GetByID returns a subscription
+func (h *AlertHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.svc.GetSubscription(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, sub)
+}
+
+// Update changes the condition of a subscription or pauses it
+func (h *AlertHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req domain.UpdateSubscriptionRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	sub, err := h.svc.UpdateSubscription(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, sub)
+}
+
+// Unsubscribe deletes a subscription
+func (h *AlertHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.Unsubscribe(r.Context(), chi.URLParam(r, "id")); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]string{
+		"message": "Subscription deleted",
+	})
+}
+
+// ListDeliveries returns the latest notifications sent for a subscription
+func (h *AlertHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	deliveries, err := h.svc.ListDeliveries(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"subscriptionId": id,
+		"deliveries":     deliveries,
+	})
+}
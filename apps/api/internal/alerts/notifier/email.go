@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+// emailTimeout bounds an SMTP session, so a stalled relay cannot hold up the
+// other notifications
+const emailTimeout = 30 * time.Second
+
+// Email sends notifications through an SMTP relay. There is no
+// authentication: it targets a local relay, or a capture server such as
+// Mailpit in development.
+type Email struct {
+	addr string
+	from string
+}
+
+// NewEmail creates an email notifier sending through the SMTP server at addr
+// (host:port) from the given sender address
+func NewEmail(addr, from string) *Email {
+	return &Email{addr: addr, from: from}
+}
+
+// Notify emails n to the subscription's address
+func (e *Email) Notify(ctx context.Context, n *domain.Notification) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.Target)
+	fmt.Fprintf(&msg, "Subject: Price alert: %s\r\n", sanitizeHeader(n.ProductName))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	fmt.Fprintf(&msg, "%s.\r\n\r\n", n.Summary())
+	fmt.Fprintf(&msg, "Your alert threshold: %.2f EUR\r\n", n.Threshold)
+	fmt.Fprintf(&msg, "Offer: %s\r\n", n.OfferURL)
+
+	return e.send(ctx, n.Target, msg.String())
+}
+
+// send runs one SMTP session delivering msg to rcpt, like smtp.SendMail but
+// bound to ctx: the connection carries ctx's deadline and is closed when ctx
+// is cancelled, which aborts the command in progress.
+func (e *Email) send(ctx context.Context, rcpt, msg string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		stop()
+		// Report the cancellation rather than the closed connection it caused
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	host, _, _ := net.SplitHostPort(e.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// sanitizeHeader keeps a value on a single header line
+func sanitizeHeader(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+// smtpSession is what a fakeSMTP server received in one session
+type smtpSession struct {
+	from, rcpt, data string
+}
+
+// fakeSMTP serves one SMTP session without extensions on a loopback port
+// and sends what it received on the returned channel
+func fakeSMTP(t *testing.T) (string, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var s smtpSession
+		tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO" || cmd == "HELO":
+				tp.PrintfLine("250 fake")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				s.from = line[len("MAIL FROM:"):]
+				tp.PrintfLine("250 OK")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				s.rcpt = line[len("RCPT TO:"):]
+				tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				s.data = string(data)
+				tp.PrintfLine("250 OK")
+			case cmd == "QUIT":
+				tp.PrintfLine("221 bye")
+				received <- s
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestEmail_Delivers(t *testing.T) {
+	addr, received := fakeSMTP(t)
+
+	n := &domain.Notification{
+		Target:      "user@example.com",
+		ProductName: "Phone\r\nBcc: victim@example.com",
+		OfferURL:    "https://shop.example/p/1",
+	}
+	if err := NewEmail(addr, "alerts@pareto.example").Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	s := <-received
+	if s.from != "<alerts@pareto.example>" || s.rcpt != "<user@example.com>" {
+		t.Errorf("envelope = %s -> %s, want <alerts@pareto.example> -> <user@example.com>", s.from, s.rcpt)
+	}
+	if !strings.Contains(s.data, "Subject: Price alert: Phone  Bcc: victim@example.com\n") {
+		t.Errorf("message does not keep the product name on the subject line:\n%s", s.data)
+	}
+	if !strings.Contains(s.data, "Offer: https://shop.example/p/1") {
+		t.Errorf("message lacks the offer URL:\n%s", s.data)
+	}
+}
+
+func TestEmail_StalledServerHonorsContext(t *testing.T) {
+	// The server accepts the connection but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			<-done
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = NewEmail(ln.Addr().String(), "alerts@pareto.example").Notify(ctx, &domain.Notification{Target: "user@example.com"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Notify returned after %s, want it bound to the context", elapsed)
+	}
+}
+
+func TestEmail_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewEmail("127.0.0.1:1", "alerts@pareto.example").Notify(ctx, &domain.Notification{Target: "user@example.com"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,29 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+// Log writes notifications to the application log, for local development
+type Log struct{}
+
+// NewLog creates a log notifier
+func NewLog() *Log {
+	return &Log{}
+}
+
+// Notify logs n; it never fails
+func (l *Log) Notify(ctx context.Context, n *domain.Notification) error {
+	log.Info().
+		Str("subscription", n.SubscriptionID).
+		Str("product", n.ProductID).
+		Str("retailer", n.RetailerID).
+		Float64("price", n.Price).
+		Str("target", n.Target).
+		Msg("Price alert: " + n.Summary())
+	return nil
+}
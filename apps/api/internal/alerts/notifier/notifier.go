@@ -0,0 +1,16 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+// Notifier delivers price alert notifications over one channel
+type Notifier interface {
+	Notify(ctx context.Context, n *domain.Notification) error
+}
+
+// Registry maps domain.Channel* values to the notifier delivering them.
+// Subscriptions can only use channels present in the registry.
+type Registry map[string]Notifier
@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+// webhookTimeout bounds a webhook call, so a slow endpoint cannot hold up
+// the other notifications
+const webhookTimeout = 10 * time.Second
+
+// Webhook POSTs notifications as JSON to the subscription's URL
+type Webhook struct {
+	client *http.Client
+}
+
+// NewWebhook creates a webhook notifier. It only connects to public
+// addresses, checked after DNS resolution so a name cannot point it at the
+// internal network, and does not follow redirects.
+func NewWebhook() *Webhook {
+	return newWebhook(domain.PublicAddr)
+}
+
+// newWebhook creates a webhook notifier connecting only to the addresses
+// allowed accepts
+func newWebhook(allowed func(netip.Addr) bool) *Webhook {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowed(addrPort.Addr()) {
+				return fmt.Errorf("webhook address %s is not public", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	// No proxy: the address check must apply to the webhook host itself
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Webhook{client: &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		// A redirect could lead anywhere; the 3xx is reported as a failure
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Notify posts n and expects a 2xx response
+func (wh *Webhook) Notify(ctx context.Context, n *domain.Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event": "price_alert",
+		"data":  n,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Pareto-Alerts/1.0")
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
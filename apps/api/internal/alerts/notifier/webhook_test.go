@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+func TestWebhook_RefusesNonPublicAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	err := NewWebhook().Notify(context.Background(), &domain.Notification{Target: srv.URL})
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Errorf("err = %v, want the loopback address refused", err)
+	}
+	if called {
+		t.Error("the webhook reached the loopback server")
+	}
+}
+
+func TestWebhook_DoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	allowAll := func(netip.Addr) bool { return true }
+	err := newWebhook(allowAll).Notify(context.Background(), &domain.Notification{Target: srv.URL + "/hook"})
+	if err == nil || !strings.Contains(err.Error(), "307") {
+		t.Errorf("err = %v, want the redirect reported as a failure", err)
+	}
+	if followed {
+		t.Error("the webhook followed the redirect")
+	}
+}
+
+func TestWebhook_Delivers(t *testing.T) {
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	allowAll := func(netip.Addr) bool { return true }
+	if err := newWebhook(allowAll).Notify(context.Background(), &domain.Notification{Target: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("content type = %q, want application/json", contentType)
+	}
+}
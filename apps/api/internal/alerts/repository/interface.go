@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+)
+
+// Repository defines the interface for price alert data access
+type Repository interface {
+	GetByID(ctx context.Context, id string) (*domain.Subscription, error)
+	Create(ctx context.Context, s *domain.Subscription) (*domain.Subscription, error)
+	Update(ctx context.Context, id string, req *domain.UpdateSubscriptionRequest) (*domain.Subscription, error)
+	Delete(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]domain.Delivery, error)
+
+	// BestPrice returns the best fresh in-stock price of a product, or of
+	// one of its variants, nil when nothing is on sale
+	BestPrice(ctx context.Context, productID string, variantID *string) (*float64, error)
+
+	// Candidates returns the active subscriptions of productIDs (of every
+	// product when empty) together with their current best offer
+	Candidates(ctx context.Context, productIDs []string) ([]domain.Trigger, error)
+	// Claim marks the subscription as notified at price, unless another
+	// worker did first; previous is the last notified price it was read with
+	Claim(ctx context.Context, id string, price float64, previous *float64) (bool, error)
+	// Release restores the last notified price after a failed delivery so
+	// the subscription fires again, not before retryAt, and counts the
+	// failure; deactivate also pauses the subscription
+	Release(ctx context.Context, id string, price float64, previous *float64, retryAt time.Time, deactivate bool) error
+	// ClearFailures resets the failed delivery count of a subscription
+	ClearFailures(ctx context.Context, id string) error
+	RecordDelivery(ctx context.Context, d *domain.Delivery) error
+
+	// ListenPriceChanges calls fn with the product of every new price
+	// history row until ctx is cancelled or the connection fails
+	ListenPriceChanges(ctx context.Context, fn func(productID string)) error
+}
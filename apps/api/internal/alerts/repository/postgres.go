@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// priceHistoryChannel is the NOTIFY channel of the price_history trigger
+const priceHistoryChannel = "price_history"
+
+// PostgreSQL error codes handled by translateError
+const (
+	pgForeignKeyViolation       = "23503"
+	pgCheckViolation            = "23514"
+	pgInvalidTextRepresentation = "22P02"
+)
+
+// foreignKeyRef describes the target of a foreign key constraint
+type foreignKeyRef struct {
+	Resource string
+	Field    string
+}
+
+// foreignKeyRefs maps foreign key constraints to the referenced resource and API field
+var foreignKeyRefs = map[string]foreignKeyRef{
+	"alert_subscriptions_product_id_fkey": {Resource: "product", Field: "productId"},
+	"alert_subscriptions_variant_id_fkey": {Resource: "variant", Field: "variantId"},
+}
+
+// checkViolations maps check constraints to the API field and rule they enforce
+var checkViolations = map[string]validator.FieldError{
+	"alert_subscriptions_check":  {Field: "targetPrice", Rule: "required_without", Message: "or dropPercent is required, but not both"},
+	"alert_subscriptions_check1": {Field: "referencePrice", Rule: "required", Message: "is required for a percentage drop"},
+}
+
+// subscriptionColumns is the column list used to scan a domain.Subscription (table alias "s")
+const subscriptionColumns = `s.id, s.product_id, s.variant_id, s.target_price, s.drop_percent,
+	s.reference_price, s.channel, s.target, COALESCE(s.active, true),
+	s.last_notified_price, s.last_notified_at, s.failed_deliveries, s.retry_at,
+	COALESCE(s.created_at, NOW()), COALESCE(s.updated_at, NOW())`
+
+// deliveryColumns is the column list used to scan a domain.Delivery (table alias "n")
+const deliveryColumns = `n.id, n.subscription_id, n.retailer_id, n.price, n.status, n.error, n.created_at`
+
+// bestOfferJoin picks the cheapest fresh in-stock offer of a subscription
+const bestOfferJoin = `JOIN LATERAL (
+	SELECT o.price, o.retailer_id, COALESCE(o.affiliate_url, o.url) AS url
+	FROM offers o
+	WHERE o.product_id = s.product_id
+	  AND (s.variant_id IS NULL OR o.variant_id = s.variant_id)
+	  AND COALESCE(o.in_stock, true) AND o.stale_at IS NULL
+	ORDER BY o.price
+	LIMIT 1
+) best ON true`
+
+// PostgresRepository implements Repository on top of pgx
+type PostgresRepository struct {
+	db *database.DB
+}
+
+// NewPostgresRepository creates a new Postgres-backed price alert repository
+func NewPostgresRepository(db *database.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// GetByID retrieves a subscription by ID
+func (r *PostgresRepository) GetByID(ctx context.Context, id string) (*domain.Subscription, error) {
+	var s domain.Subscription
+	err := scanSubscription(r.db.Pool.QueryRow(ctx,
+		`SELECT `+subscriptionColumns+` FROM alert_subscriptions s WHERE s.id = $1`, id), &s)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &s, nil
+}
+
+// Create inserts a subscription
+func (r *PostgresRepository) Create(ctx context.Context, s *domain.Subscription) (*domain.Subscription, error) {
+	var created domain.Subscription
+	err := scanSubscription(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO alert_subscriptions AS s (
+			product_id, variant_id, target_price, drop_percent, reference_price, channel, target
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+subscriptionColumns,
+		s.ProductID, s.VariantID, s.TargetPrice, s.DropPercent, s.ReferencePrice, s.Channel, s.Target,
+	), &created)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &created, nil
+}
+
+// Update applies the non-nil fields of req. A new condition replaces the
+// previous one and re-arms the subscription.
+func (r *PostgresRepository) Update(ctx context.Context, id string, req *domain.UpdateSubscriptionRequest) (*domain.Subscription, error) {
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.TargetPrice != nil {
+		set("target_price", *req.TargetPrice)
+		set("drop_percent", nil)
+	}
+	if req.DropPercent != nil {
+		set("drop_percent", *req.DropPercent)
+		set("target_price", nil)
+	}
+	if req.ReferencePrice != nil {
+		set("reference_price", *req.ReferencePrice)
+	}
+	if req.TargetPrice != nil || req.DropPercent != nil || req.ReferencePrice != nil {
+		set("last_notified_price", nil)
+	}
+	if req.Active != nil {
+		set("active", *req.Active)
+	}
+	if len(sets) > 0 && (req.Active == nil || *req.Active) {
+		set("failed_deliveries", 0)
+		set("retry_at", nil)
+	}
+
+	if len(sets) == 0 {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, id)
+	var s domain.Subscription
+	err := scanSubscription(r.db.Pool.QueryRow(ctx, fmt.Sprintf(
+		`UPDATE alert_subscriptions AS s SET %s WHERE s.id = $%d RETURNING %s`,
+		strings.Join(sets, ", "), len(args), subscriptionColumns), args...), &s)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &s, nil
+}
+
+// Delete removes a subscription and its delivery log
+func (r *PostgresRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM alert_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return translateError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NewNotFoundError("subscription")
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest delivery attempts of a subscription, newest first
+func (r *PostgresRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]domain.Delivery, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM alert_notifications n
+		WHERE n.subscription_id = $1
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $2`, subscriptionID, limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []domain.Delivery{}
+	for rows.Next() {
+		var d domain.Delivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.RetailerID, &d.Price, &d.Status, &d.Error, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// BestPrice returns the best fresh in-stock price of a product or variant
+func (r *PostgresRepository) BestPrice(ctx context.Context, productID string, variantID *string) (*float64, error) {
+	var (
+		productExists, variantExists bool
+		price                        *float64
+	)
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM products WHERE id = $1),
+		       $2::uuid IS NULL OR EXISTS (SELECT 1 FROM variants WHERE id = $2 AND product_id = $1),
+		       (SELECT MIN(o.price) FROM offers o
+		        WHERE o.product_id = $1
+		          AND ($2::uuid IS NULL OR o.variant_id = $2)
+		          AND COALESCE(o.in_stock, true) AND o.stale_at IS NULL)`,
+		productID, variantID).Scan(&productExists, &variantExists, &price)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if !productExists {
+		return nil, apperr.NewNotFoundError("product")
+	}
+	if !variantExists {
+		return nil, apperr.NewNotFoundError("variant")
+	}
+	return price, nil
+}
+
+// Candidates returns active subscriptions with their current best offer.
+// Subscriptions without any offer on sale, or waiting to retry a failed
+// delivery, are left out.
+func (r *PostgresRepository) Candidates(ctx context.Context, productIDs []string) ([]domain.Trigger, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+subscriptionColumns+`, p.name, best.retailer_id, best.url, best.price
+		FROM alert_subscriptions s
+		JOIN products p ON p.id = s.product_id
+		`+bestOfferJoin+`
+		WHERE COALESCE(s.active, true)
+		  AND (s.retry_at IS NULL OR s.retry_at <= NOW())
+		  AND (COALESCE(cardinality($1::uuid[]), 0) = 0 OR s.product_id = ANY($1))
+		ORDER BY s.created_at`, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []domain.Trigger{}
+	for rows.Next() {
+		var t domain.Trigger
+		if err := scanSubscription(rows, &t.Subscription, &t.ProductName, &t.RetailerID, &t.OfferURL, &t.Price); err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, rows.Err()
+}
+
+// Claim records price as notified if the subscription was not notified since
+// it was read
+func (r *PostgresRepository) Claim(ctx context.Context, id string, price float64, previous *float64) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE alert_subscriptions
+		SET last_notified_price = $2, last_notified_at = NOW()
+		WHERE id = $1 AND last_notified_price IS NOT DISTINCT FROM $3`, id, price, previous)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Release undoes a claim and counts the failure, unless the subscription
+// changed meanwhile
+func (r *PostgresRepository) Release(ctx context.Context, id string, price float64, previous *float64, retryAt time.Time, deactivate bool) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE alert_subscriptions
+		SET last_notified_price = $3, failed_deliveries = failed_deliveries + 1,
+		    retry_at = $4, active = active AND NOT $5
+		WHERE id = $1 AND last_notified_price = $2`, id, price, previous, retryAt, deactivate)
+	return err
+}
+
+// ClearFailures resets the failed delivery count after a successful delivery
+func (r *PostgresRepository) ClearFailures(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE alert_subscriptions SET failed_deliveries = 0, retry_at = NULL
+		WHERE id = $1`, id)
+	return err
+}
+
+// RecordDelivery appends a delivery attempt to the notification log
+func (r *PostgresRepository) RecordDelivery(ctx context.Context, d *domain.Delivery) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO alert_notifications (subscription_id, retailer_id, price, status, error)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		d.SubscriptionID, d.RetailerID, d.Price, d.Status, d.Error).Scan(&d.ID, &d.CreatedAt)
+}
+
+// ListenPriceChanges holds a pool connection listening to the price_history
+// trigger's notifications
+func (r *PostgresRepository) ListenPriceChanges(ctx context.Context, fn func(productID string)) error {
+	pooled, err := r.db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left in LISTEN mode: take it out of the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+priceHistoryChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
+
+func scanSubscription(row pgx.Row, s *domain.Subscription, extra ...any) error {
+	return row.Scan(append([]any{
+		&s.ID, &s.ProductID, &s.VariantID, &s.TargetPrice, &s.DropPercent,
+		&s.ReferencePrice, &s.Channel, &s.Target, &s.Active,
+		&s.LastNotifiedPrice, &s.LastNotifiedAt, &s.FailedDeliveries, &s.RetryAt,
+		&s.CreatedAt, &s.UpdatedAt,
+	}, extra...)...)
+}
+
+// translateError maps pgx errors to the typed errors shared with the catalog
+func translateError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperr.NewNotFoundError("subscription")
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	var derr *apperr.Error
+	switch pgErr.Code {
+	case pgInvalidTextRepresentation:
+		// A malformed ID cannot match any subscription
+		derr = apperr.NewNotFoundError("subscription")
+	case pgForeignKeyViolation:
+		ref, ok := foreignKeyRefs[pgErr.ConstraintName]
+		if !ok {
+			ref = foreignKeyRef{Resource: pgErr.TableName, Field: pgErr.ConstraintName}
+		}
+		derr = apperr.NewInvalidReferenceError(ref.Resource, ref.Field)
+	case pgCheckViolation:
+		derr = apperr.NewValidationError("Request validation failed", nil)
+		if fe, ok := checkViolations[pgErr.ConstraintName]; ok {
+			derr.Details = validator.Errors{fe}
+		}
+	default:
+		return err
+	}
+	derr.Err = err
+	return derr
+}
+
+// Compile-time interface check
+var _ Repository = (*PostgresRepository)(nil)
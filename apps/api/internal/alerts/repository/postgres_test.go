@@ -0,0 +1,486 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// missingID is a well-formed UUID no record uses
+const missingID = "00000000-0000-0000-0000-000000000000"
+
+// insertProduct adds a product and returns its ID
+func insertProduct(t *testing.T, db *database.DB, name string) string {
+	t.Helper()
+	var id string
+	err := db.Pool.QueryRow(context.Background(), `
+		INSERT INTO products (category_id, name, slug, brand, model)
+		VALUES ($1, $2, lower($2), 'Acme', $2)
+		RETURNING id`, dbtest.SmartphonesCategoryID, name).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// insertVariant adds a variant of productID and returns its ID
+func insertVariant(t *testing.T, db *database.DB, productID, sku string) string {
+	t.Helper()
+	var id string
+	err := db.Pool.QueryRow(context.Background(), `
+		INSERT INTO variants (product_id, sku) VALUES ($1, $2) RETURNING id`, productID, sku).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// insertOffer adds a fresh in-stock offer
+func insertOffer(t *testing.T, db *database.DB, productID string, variantID *string, retailerID string, price float64) {
+	t.Helper()
+	dbtest.Exec(t, db, `
+		INSERT INTO offers (product_id, variant_id, retailer_id, price, url)
+		VALUES ($1, $2, $3, $4, 'https://shop.example/' || $3)`, productID, variantID, retailerID, price)
+}
+
+func assertError(t *testing.T, err error, kind error, code, field string) {
+	t.Helper()
+	var derr *apperr.Error
+	if !errors.As(err, &derr) {
+		t.Fatalf("err = %v, want a *apperr.Error", err)
+	}
+	if !errors.Is(err, kind) || derr.Code != code || derr.Field != field {
+		t.Errorf("err = %v (code %q, field %q), want %v (code %q, field %q)",
+			err, derr.Code, derr.Field, kind, code, field)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *pgconn.PgError
+		kind   error
+		code   string
+		field  string
+		detail string
+	}{
+		{"product reference", &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "alert_subscriptions_product_id_fkey"},
+			apperr.ErrInvalidReference, apperr.CodeInvalidReference, "productId", ""},
+		{"variant reference", &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "alert_subscriptions_variant_id_fkey"},
+			apperr.ErrInvalidReference, apperr.CodeInvalidReference, "variantId", ""},
+		// Constraint names are matched exactly, not by the words they contain
+		{"unmapped reference", &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "alert_notifications_subscription_id_fkey", TableName: "alert_notifications"},
+			apperr.ErrInvalidReference, apperr.CodeInvalidReference, "alert_notifications_subscription_id_fkey", ""},
+		{"condition check", &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "alert_subscriptions_check"},
+			apperr.ErrValidation, apperr.CodeValidationFailed, "", "targetPrice"},
+		{"reference price check", &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "alert_subscriptions_check1"},
+			apperr.ErrValidation, apperr.CodeValidationFailed, "", "referencePrice"},
+		{"unmapped check", &pgconn.PgError{Code: pgCheckViolation, ConstraintName: "alert_subscriptions_check9"},
+			apperr.ErrValidation, apperr.CodeValidationFailed, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(tt.err)
+			assertError(t, err, tt.kind, tt.code, tt.field)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want it to wrap the driver error", err)
+			}
+
+			var derr *apperr.Error
+			errors.As(err, &derr)
+			details, _ := derr.Details.(validator.Errors)
+			var fields []string
+			for _, d := range details {
+				fields = append(fields, d.Field)
+			}
+			if (tt.detail == "" && len(fields) != 0) || (tt.detail != "" && (len(fields) != 1 || fields[0] != tt.detail)) {
+				t.Errorf("details = %v, want %q", derr.Details, tt.detail)
+			}
+		})
+	}
+}
+
+func TestPostgresRepository_CRUD(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	productID := insertProduct(t, db, "Phone")
+	variantID := insertVariant(t, db, productID, "P-BLK")
+
+	created, err := repo.Create(ctx, &domain.Subscription{
+		ProductID: productID, VariantID: &variantID,
+		DropPercent: ptr(10), ReferencePrice: ptr(500.0),
+		Channel: domain.ChannelEmail, Target: "me@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || !created.Active || *created.DropPercent != 10 || created.TargetPrice != nil {
+		t.Errorf("created = %+v", created)
+	}
+
+	got, err := repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Target != "me@example.com" || *got.VariantID != variantID || *got.ReferencePrice != 500 {
+		t.Errorf("got = %+v", got)
+	}
+
+	// A new condition replaces the old one and re-arms the subscription
+	dbtest.Exec(t, db, `UPDATE alert_subscriptions SET last_notified_price = 440 WHERE id = $1`, created.ID)
+	updated, err := repo.Update(ctx, created.ID, &domain.UpdateSubscriptionRequest{TargetPrice: ptr(400.0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updated.TargetPrice != 400 || updated.DropPercent != nil || updated.LastNotifiedPrice != nil {
+		t.Errorf("updated = %+v, want target 400, no drop percent, re-armed", updated)
+	}
+
+	paused, err := repo.Update(ctx, created.ID, &domain.UpdateSubscriptionRequest{Active: ptr(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paused.Active || *paused.TargetPrice != 400 {
+		t.Errorf("paused = %+v", paused)
+	}
+
+	unchanged, err := repo.Update(ctx, created.ID, &domain.UpdateSubscriptionRequest{})
+	if err != nil || unchanged.Active {
+		t.Errorf("empty update = %+v, %v", unchanged, err)
+	}
+
+	if err := repo.Delete(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{created.ID, "not-a-uuid"} {
+		_, err = repo.GetByID(ctx, id)
+		assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+		_, err = repo.Update(ctx, id, &domain.UpdateSubscriptionRequest{Active: ptr(true)})
+		assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+		err = repo.Delete(ctx, id)
+		assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	}
+}
+
+func TestPostgresRepository_CreateErrors(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	productID := insertProduct(t, db, "Phone")
+
+	tests := []struct {
+		name     string
+		sub      domain.Subscription
+		resource string
+		field    string
+	}{
+		{"unknown product", domain.Subscription{ProductID: missingID}, "product", "productId"},
+		{"unknown variant", domain.Subscription{ProductID: productID, VariantID: ptr(missingID)}, "variant", "variantId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sub.TargetPrice = ptr(100.0)
+			tt.sub.Channel, tt.sub.Target = domain.ChannelLog, "test"
+			_, err := repo.Create(ctx, &tt.sub)
+			assertError(t, err, apperr.ErrInvalidReference, apperr.CodeInvalidReference, tt.field)
+			var derr *apperr.Error
+			if errors.As(err, &derr) && derr.Resource != tt.resource {
+				t.Errorf("resource = %s, want %s", derr.Resource, tt.resource)
+			}
+		})
+	}
+}
+
+func TestPostgresRepository_BestPrice(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	productID := insertProduct(t, db, "Phone")
+	variantID := insertVariant(t, db, productID, "P-BLK")
+	otherVariant := insertVariant(t, db, insertProduct(t, db, "Other"), "O-BLK")
+	insertOffer(t, db, productID, nil, "fnac", 300)
+	insertOffer(t, db, productID, &variantID, "darty", 320)
+	insertOffer(t, db, productID, nil, "ldlc", 250)
+	insertOffer(t, db, productID, nil, "boulanger", 200)
+	dbtest.Exec(t, db, `UPDATE offers SET in_stock = false WHERE retailer_id = 'ldlc'`)
+	dbtest.Exec(t, db, `UPDATE offers SET stale_at = NOW() WHERE retailer_id = 'boulanger'`)
+
+	price, err := repo.BestPrice(ctx, productID, nil)
+	if err != nil || price == nil || *price != 300 {
+		t.Errorf("product best price = %v, %v, want 300", price, err)
+	}
+	price, err = repo.BestPrice(ctx, productID, &variantID)
+	if err != nil || price == nil || *price != 320 {
+		t.Errorf("variant best price = %v, %v, want 320", price, err)
+	}
+
+	bare := insertProduct(t, db, "Bare")
+	price, err = repo.BestPrice(ctx, bare, nil)
+	if err != nil || price != nil {
+		t.Errorf("best price without offers = %v, %v, want none", price, err)
+	}
+
+	_, err = repo.BestPrice(ctx, missingID, nil)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	_, err = repo.BestPrice(ctx, productID, &otherVariant)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresRepository_Notifications(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	phone := insertProduct(t, db, "Phone")
+	tablet := insertProduct(t, db, "Tablet")
+	variantID := insertVariant(t, db, phone, "P-BLK")
+	insertOffer(t, db, phone, nil, "fnac", 300)
+	insertOffer(t, db, phone, &variantID, "darty", 280)
+	insertOffer(t, db, tablet, nil, "fnac", 500)
+
+	subscribe := func(productID string, variantID *string, active bool) *domain.Subscription {
+		t.Helper()
+		s, err := repo.Create(ctx, &domain.Subscription{
+			ProductID: productID, VariantID: variantID, TargetPrice: ptr(1000.0),
+			Channel: domain.ChannelLog, Target: "test",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !active {
+			if _, err := repo.Update(ctx, s.ID, &domain.UpdateSubscriptionRequest{Active: ptr(false)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s
+	}
+	anyVariant := subscribe(phone, nil, true)
+	variant := subscribe(phone, &variantID, true)
+	subscribe(phone, nil, false)
+	other := subscribe(tablet, nil, true)
+	// No offer on sale: never a candidate
+	subscribe(insertProduct(t, db, "Unsold"), nil, true)
+
+	t.Run("candidates", func(t *testing.T) {
+		all, err := repo.Candidates(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]float64{anyVariant.ID: 280, variant.ID: 280, other.ID: 500}
+		if len(all) != len(want) {
+			t.Fatalf("candidates = %+v, want %d", all, len(want))
+		}
+		for _, c := range all {
+			if price, ok := want[c.Subscription.ID]; !ok || c.Price != price {
+				t.Errorf("candidate %s at %v, want %v", c.Subscription.ID, c.Price, price)
+			}
+		}
+		if all[0].ProductName != "Phone" || all[0].RetailerID != "darty" || all[0].OfferURL != "https://shop.example/darty" {
+			t.Errorf("first candidate = %+v", all[0])
+		}
+
+		some, err := repo.Candidates(ctx, []string{tablet})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(some) != 1 || some[0].Subscription.ID != other.ID {
+			t.Errorf("tablet candidates = %+v", some)
+		}
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		won, err := repo.Claim(ctx, anyVariant.ID, 280, nil)
+		if err != nil || !won {
+			t.Fatalf("claim = %v, %v, want won", won, err)
+		}
+		// A concurrent worker read the subscription before the claim
+		won, err = repo.Claim(ctx, anyVariant.ID, 280, nil)
+		if err != nil || won {
+			t.Fatalf("second claim = %v, %v, want lost", won, err)
+		}
+
+		if err := repo.Release(ctx, anyVariant.ID, 280, nil, time.Now(), false); err != nil {
+			t.Fatal(err)
+		}
+		s, err := repo.GetByID(ctx, anyVariant.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.LastNotifiedPrice != nil {
+			t.Errorf("lastNotifiedPrice = %v after release, want none", *s.LastNotifiedPrice)
+		}
+		if s.FailedDeliveries != 1 || s.RetryAt == nil {
+			t.Errorf("failures = %d, retry at %v after release, want 1 failure", s.FailedDeliveries, s.RetryAt)
+		}
+
+		// Releasing a claim that was overtaken leaves the newer one alone
+		if won, err := repo.Claim(ctx, anyVariant.ID, 280, nil); err != nil || !won {
+			t.Fatalf("claim = %v, %v, want won", won, err)
+		}
+		if won, err := repo.Claim(ctx, anyVariant.ID, 250, ptr(280.0)); err != nil || !won {
+			t.Fatalf("claim = %v, %v, want won", won, err)
+		}
+		if err := repo.Release(ctx, anyVariant.ID, 280, nil, time.Now(), true); err != nil {
+			t.Fatal(err)
+		}
+		s, err = repo.GetByID(ctx, anyVariant.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.LastNotifiedPrice == nil || *s.LastNotifiedPrice != 250 || s.LastNotifiedAt == nil {
+			t.Errorf("subscription = %+v, want notified at 250", s)
+		}
+		if s.FailedDeliveries != 1 || !s.Active {
+			t.Errorf("failures = %d, active %v, want the overtaken release ignored", s.FailedDeliveries, s.Active)
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		isCandidate := func() bool {
+			t.Helper()
+			candidates, err := repo.Candidates(ctx, []string{tablet})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return len(candidates) == 1
+		}
+
+		if won, err := repo.Claim(ctx, other.ID, 500, nil); err != nil || !won {
+			t.Fatalf("claim = %v, %v, want won", won, err)
+		}
+		if err := repo.Release(ctx, other.ID, 500, nil, time.Now().Add(time.Hour), false); err != nil {
+			t.Fatal(err)
+		}
+		if isCandidate() {
+			t.Error("a subscription waiting to retry is a candidate")
+		}
+		if err := repo.ClearFailures(ctx, other.ID); err != nil {
+			t.Fatal(err)
+		}
+		s, err := repo.GetByID(ctx, other.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.FailedDeliveries != 0 || s.RetryAt != nil || !isCandidate() {
+			t.Errorf("subscription = %+v after clearing failures, want a candidate again", s)
+		}
+
+		if won, err := repo.Claim(ctx, other.ID, 500, nil); err != nil || !won {
+			t.Fatalf("claim = %v, %v, want won", won, err)
+		}
+		if err := repo.Release(ctx, other.ID, 500, nil, time.Now(), true); err != nil {
+			t.Fatal(err)
+		}
+		if s, err = repo.GetByID(ctx, other.ID); err != nil || s.Active {
+			t.Fatalf("subscription = %+v, %v, want it deactivated", s, err)
+		}
+
+		// Reactivating clears the failures
+		s, err = repo.Update(ctx, other.ID, &domain.UpdateSubscriptionRequest{Active: ptr(true)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !s.Active || s.FailedDeliveries != 0 || s.RetryAt != nil || !isCandidate() {
+			t.Errorf("subscription = %+v after reactivation, want a candidate without failures", s)
+		}
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		for _, status := range []string{domain.NotificationFailed, domain.NotificationSent} {
+			d := &domain.Delivery{SubscriptionID: variant.ID, RetailerID: "darty", Price: 280, Status: status}
+			if status == domain.NotificationFailed {
+				d.Error = ptr("connection refused")
+			}
+			if err := repo.RecordDelivery(ctx, d); err != nil {
+				t.Fatal(err)
+			}
+			if d.ID == 0 || d.CreatedAt.IsZero() {
+				t.Errorf("delivery = %+v, want ID and time set", d)
+			}
+		}
+
+		deliveries, err := repo.ListDeliveries(ctx, variant.ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 2 || deliveries[0].Status != domain.NotificationSent ||
+			deliveries[1].Error == nil || *deliveries[1].Error != "connection refused" {
+			t.Errorf("deliveries = %+v, want sent then failed", deliveries)
+		}
+
+		latest, err := repo.ListDeliveries(ctx, variant.ID, 1)
+		if err != nil || len(latest) != 1 {
+			t.Errorf("limited deliveries = %+v, %v", latest, err)
+		}
+		none, err := repo.ListDeliveries(ctx, other.ID, 10)
+		if err != nil || none == nil || len(none) != 0 {
+			t.Errorf("deliveries of another subscription = %+v, %v, want an empty list", none, err)
+		}
+	})
+}
+
+func TestPostgresRepository_ListenPriceChanges(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+
+	productID := insertProduct(t, db, "Phone")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listening := make(chan struct{})
+	changed := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- repo.ListenPriceChanges(ctx, func(id string) {
+			select {
+			case changed <- id:
+			default:
+			}
+		})
+	}()
+
+	// LISTEN runs before the first wait: retry the insert until it is heard
+	go func() {
+		defer close(listening)
+		for i := 0; ctx.Err() == nil; i++ {
+			_, err := db.Pool.Exec(ctx, `
+				INSERT INTO price_history (time, product_id, retailer_id, price)
+				VALUES (NOW(), $1, 'fnac', $2)`, productID, 100+i)
+			if err != nil {
+				return
+			}
+			select {
+			case id := <-changed:
+				if id != productID {
+					t.Errorf("notified product %s, want %s", id, productID)
+				}
+				cancel()
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+
+	<-listening
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("listener stopped with %v, want it to hear the insert and stop on cancel", err)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/notifier"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// maxDeliveries caps the delivery log returned for a subscription
+const maxDeliveries = 100
+
+// errChannelDisabled is recorded when a subscription's channel was disabled
+// after it subscribed
+var errChannelDisabled = errors.New("notification channel is not enabled")
+
+// AlertService provides price alert business logic
+type AlertService struct {
+	repo      repository.Repository
+	notifiers notifier.Registry
+	worker    *alertWorker
+}
+
+// NewAlertService creates a new price alert service delivering through notifiers
+func NewAlertService(repo repository.Repository, notifiers notifier.Registry) *AlertService {
+	return &AlertService{
+		repo:      repo,
+		notifiers: notifiers,
+		worker:    newAlertWorker(repo, notifiers),
+	}
+}
+
+// RunWorker evaluates subscriptions as prices change and delivers their
+// notifications until ctx is cancelled
+func (s *AlertService) RunWorker(ctx context.Context) {
+	s.worker.run(ctx)
+}
+
+// Subscribe creates a subscription. A percentage drop is measured from the
+// given reference price, or from the current best price.
+func (s *AlertService) Subscribe(ctx context.Context, req *domain.CreateSubscriptionRequest) (*domain.Subscription, error) {
+	var errs validator.Errors
+	if _, ok := s.notifiers[req.Channel]; !ok {
+		errs = append(errs, validator.FieldError{Field: "channel", Rule: "enabled", Message: "is not enabled on this server"})
+	} else if msg := domain.CheckTarget(req.Channel, req.Target); msg != "" {
+		errs = append(errs, validator.FieldError{Field: "target", Rule: "format", Message: msg})
+	}
+	errs = append(errs, conditionErrors(req.TargetPrice, req.DropPercent, true)...)
+	if len(errs) > 0 {
+		return nil, apperr.NewValidationError("Request validation failed", errs)
+	}
+
+	current, err := s.repo.BestPrice(ctx, req.ProductID, req.VariantID)
+	if err != nil {
+		// The product comes from the body: a missing one is a bad field
+		var derr *apperr.Error
+		if errors.As(err, &derr) && errors.Is(err, apperr.ErrNotFound) {
+			return nil, apperr.NewInvalidReferenceError(derr.Resource, derr.Resource+"Id")
+		}
+		return nil, err
+	}
+
+	sub := &domain.Subscription{
+		ProductID:      req.ProductID,
+		VariantID:      req.VariantID,
+		TargetPrice:    req.TargetPrice,
+		DropPercent:    req.DropPercent,
+		ReferencePrice: req.ReferencePrice,
+		Channel:        req.Channel,
+		Target:         req.Target,
+	}
+	if sub.ReferencePrice == nil {
+		sub.ReferencePrice = current
+	}
+	if sub.DropPercent != nil && sub.ReferencePrice == nil {
+		return nil, referencePriceRequired()
+	}
+	return s.repo.Create(ctx, sub)
+}
+
+// GetSubscription retrieves a subscription by ID
+func (s *AlertService) GetSubscription(ctx context.Context, id string) (*domain.Subscription, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// UpdateSubscription changes the condition of a subscription or pauses it
+func (s *AlertService) UpdateSubscription(ctx context.Context, id string, req *domain.UpdateSubscriptionRequest) (*domain.Subscription, error) {
+	if errs := conditionErrors(req.TargetPrice, req.DropPercent, false); len(errs) > 0 {
+		return nil, apperr.NewValidationError("Request validation failed", errs)
+	}
+
+	if req.DropPercent != nil && req.ReferencePrice == nil {
+		current, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if current.ReferencePrice == nil {
+			price, err := s.repo.BestPrice(ctx, current.ProductID, current.VariantID)
+			if err != nil {
+				return nil, err
+			}
+			if price == nil {
+				return nil, referencePriceRequired()
+			}
+			req.ReferencePrice = price
+		}
+	}
+	return s.repo.Update(ctx, id, req)
+}
+
+// Unsubscribe deletes a subscription
+func (s *AlertService) Unsubscribe(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// ListDeliveries returns the latest delivery attempts of a subscription
+func (s *AlertService) ListDeliveries(ctx context.Context, id string) ([]domain.Delivery, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(ctx, id, maxDeliveries)
+}
+
+// conditionErrors checks that at most one condition is set, and exactly one
+// when required
+func conditionErrors(targetPrice *float64, dropPercent *int, required bool) validator.Errors {
+	switch {
+	case targetPrice != nil && dropPercent != nil:
+		return validator.Errors{{Field: "dropPercent", Rule: "excluded_with", Message: "cannot be combined with targetPrice"}}
+	case required && targetPrice == nil && dropPercent == nil:
+		return validator.Errors{{Field: "targetPrice", Rule: "required_without", Message: "or dropPercent is required"}}
+	}
+	return nil
+}
+
+func referencePriceRequired() error {
+	return apperr.NewValidationError("Request validation failed", validator.Errors{{
+		Field:   "referencePrice",
+		Rule:    "required",
+		Message: "is required for a percentage drop while no offer is on sale",
+	}})
+}
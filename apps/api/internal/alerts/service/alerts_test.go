@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/notifier"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// memRepo is an in-memory Repository following the Postgres claim and
+// release rules. prices holds the best offer price of each product; products
+// missing from it have no offer on sale.
+type memRepo struct {
+	repository.Repository
+	products   map[string]bool
+	prices     map[string]float64
+	subs       map[string]*domain.Subscription
+	deliveries []domain.Delivery
+}
+
+func newMemRepo(products ...string) *memRepo {
+	r := &memRepo{
+		products: make(map[string]bool),
+		prices:   make(map[string]float64),
+		subs:     make(map[string]*domain.Subscription),
+	}
+	for _, id := range products {
+		r.products[id] = true
+	}
+	return r
+}
+
+// add stores a copy of s as an active subscription
+func (r *memRepo) add(s domain.Subscription) *domain.Subscription {
+	s.Active = true
+	r.subs[s.ID] = &s
+	return &s
+}
+
+func (r *memRepo) BestPrice(ctx context.Context, productID string, variantID *string) (*float64, error) {
+	if !r.products[productID] {
+		return nil, apperr.NewNotFoundError("product")
+	}
+	if variantID != nil {
+		return nil, apperr.NewNotFoundError("variant")
+	}
+	if price, ok := r.prices[productID]; ok {
+		return &price, nil
+	}
+	return nil, nil
+}
+
+func (r *memRepo) Create(ctx context.Context, s *domain.Subscription) (*domain.Subscription, error) {
+	created := *s
+	created.ID = "sub-" + s.ProductID
+	created.Active = true
+	r.subs[created.ID] = &created
+	return &created, nil
+}
+
+func (r *memRepo) Candidates(ctx context.Context, productIDs []string) ([]domain.Trigger, error) {
+	var triggers []domain.Trigger
+	for _, s := range r.subs {
+		price, onSale := r.prices[s.ProductID]
+		waiting := s.RetryAt != nil && s.RetryAt.After(time.Now())
+		if !s.Active || !onSale || waiting || (len(productIDs) > 0 && !slices.Contains(productIDs, s.ProductID)) {
+			continue
+		}
+		triggers = append(triggers, domain.Trigger{
+			Subscription: *s, ProductName: "Phone", RetailerID: "fnac",
+			OfferURL: "https://shop.example/fnac", Price: price,
+		})
+	}
+	return triggers, nil
+}
+
+func (r *memRepo) Claim(ctx context.Context, id string, price float64, previous *float64) (bool, error) {
+	s := r.subs[id]
+	if !samePrice(s.LastNotifiedPrice, previous) {
+		return false, nil
+	}
+	now := time.Now()
+	s.LastNotifiedPrice, s.LastNotifiedAt = &price, &now
+	return true, nil
+}
+
+func (r *memRepo) Release(ctx context.Context, id string, price float64, previous *float64, retryAt time.Time, deactivate bool) error {
+	s := r.subs[id]
+	if !samePrice(s.LastNotifiedPrice, &price) {
+		return nil
+	}
+	s.LastNotifiedPrice = previous
+	s.FailedDeliveries++
+	s.RetryAt = &retryAt
+	s.Active = s.Active && !deactivate
+	return nil
+}
+
+func (r *memRepo) ClearFailures(ctx context.Context, id string) error {
+	r.subs[id].FailedDeliveries, r.subs[id].RetryAt = 0, nil
+	return nil
+}
+
+func (r *memRepo) RecordDelivery(ctx context.Context, d *domain.Delivery) error {
+	r.deliveries = append(r.deliveries, *d)
+	return nil
+}
+
+func samePrice(a, b *float64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// recordingNotifier records the notifications it is given and fails with
+// err when set
+type recordingNotifier struct {
+	sent []domain.Notification
+	err  error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *domain.Notification) error {
+	n.sent = append(n.sent, *notification)
+	return n.err
+}
+
+func TestSubscribe_ReferencePrice(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	percent := 10
+
+	tests := []struct {
+		name      string
+		onSale    bool
+		target    *float64
+		reference *float64
+		want      *float64
+		wantField string
+	}{
+		{name: "defaults to the best price", onSale: true, want: ptr(300)},
+		{name: "given price kept", onSale: true, reference: ptr(350), want: ptr(350)},
+		{name: "given price without offers", reference: ptr(350), want: ptr(350)},
+		{name: "required for a drop without offers", wantField: "referencePrice"},
+		{name: "not required for a target price", target: ptr(250)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo("phone")
+			if tt.onSale {
+				repo.prices["phone"] = 300
+			}
+			s := NewAlertService(repo, notifier.Registry{domain.ChannelLog: notifier.NewLog()})
+
+			req := &domain.CreateSubscriptionRequest{
+				ProductID: "phone", TargetPrice: tt.target, ReferencePrice: tt.reference,
+				Channel: domain.ChannelLog, Target: "test",
+			}
+			if tt.target == nil {
+				req.DropPercent = &percent
+			}
+			sub, err := s.Subscribe(context.Background(), req)
+			if tt.wantField != "" {
+				assertValidation(t, err, tt.wantField)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !samePrice(sub.ReferencePrice, tt.want) {
+				t.Errorf("reference price = %v, want %v", sub.ReferencePrice, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribe_Errors(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	percent := 10
+	variantID := "black"
+
+	tests := []struct {
+		name      string
+		req       domain.CreateSubscriptionRequest
+		wantKind  error
+		wantField string
+	}{
+		{"channel not enabled", domain.CreateSubscriptionRequest{ProductID: "phone", TargetPrice: ptr(250), Channel: domain.ChannelEmail, Target: "ana@example.com"},
+			apperr.ErrValidation, "channel"},
+		{"bad target", domain.CreateSubscriptionRequest{ProductID: "phone", TargetPrice: ptr(250), Channel: domain.ChannelWebhook, Target: "http://10.0.0.5/hook"},
+			apperr.ErrValidation, "target"},
+		{"both conditions", domain.CreateSubscriptionRequest{ProductID: "phone", TargetPrice: ptr(250), DropPercent: &percent, Channel: domain.ChannelLog, Target: "test"},
+			apperr.ErrValidation, "dropPercent"},
+		{"no condition", domain.CreateSubscriptionRequest{ProductID: "phone", Channel: domain.ChannelLog, Target: "test"},
+			apperr.ErrValidation, "targetPrice"},
+		{"unknown product", domain.CreateSubscriptionRequest{ProductID: "tablet", TargetPrice: ptr(250), Channel: domain.ChannelLog, Target: "test"},
+			apperr.ErrInvalidReference, "productId"},
+		{"unknown variant", domain.CreateSubscriptionRequest{ProductID: "phone", VariantID: &variantID, TargetPrice: ptr(250), Channel: domain.ChannelLog, Target: "test"},
+			apperr.ErrInvalidReference, "variantId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAlertService(newMemRepo("phone"), notifier.Registry{
+				domain.ChannelLog:     notifier.NewLog(),
+				domain.ChannelWebhook: &recordingNotifier{},
+			})
+			_, err := s.Subscribe(context.Background(), &tt.req)
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("err = %v, want %v", err, tt.wantKind)
+			}
+			if tt.wantKind == apperr.ErrValidation {
+				assertValidation(t, err, tt.wantField)
+				return
+			}
+			var derr *apperr.Error
+			if !errors.As(err, &derr) || derr.Field != tt.wantField {
+				t.Errorf("err = %#v, want field %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+// assertValidation checks that err is a validation error on field alone
+func assertValidation(t *testing.T, err error, field string) {
+	t.Helper()
+	var derr *apperr.Error
+	if !errors.As(err, &derr) || !errors.Is(err, apperr.ErrValidation) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	errs, _ := derr.Details.(validator.Errors)
+	if len(errs) != 1 || errs[0].Field != field {
+		t.Errorf("details = %v, want one error on %s", derr.Details, field)
+	}
+}
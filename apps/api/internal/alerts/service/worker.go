@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/notifier"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/repository"
+)
+
+// Alert worker tuning
+const (
+	// alertSweepInterval re-evaluates every subscription, catching price
+	// changes whose notification was missed (restart, lost connection)
+	alertSweepInterval = 5 * time.Minute
+	// listenRetryDelay is the wait before listening again after the
+	// notification connection failed
+	listenRetryDelay = 10 * time.Second
+	// deliveryTimeout bounds a single notification delivery
+	deliveryTimeout = 30 * time.Second
+)
+
+// alertWorker evaluates subscriptions when the price history of their
+// product changes, as signalled by the price_history trigger, and delivers
+// the notifications of those that fire
+type alertWorker struct {
+	repo      repository.Repository
+	notifiers notifier.Registry
+
+	mu      sync.Mutex
+	pending map[string]bool
+	wake    chan struct{}
+}
+
+func newAlertWorker(repo repository.Repository, notifiers notifier.Registry) *alertWorker {
+	return &alertWorker{
+		repo:      repo,
+		notifiers: notifiers,
+		pending:   make(map[string]bool),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// run evaluates subscriptions until ctx is cancelled
+func (w *alertWorker) run(ctx context.Context) {
+	go w.listen(ctx)
+
+	ticker := time.NewTicker(alertSweepInterval)
+	defer ticker.Stop()
+
+	w.evaluate(ctx, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.takePending()
+			w.evaluate(ctx, nil)
+		case <-w.wake:
+			if products := w.takePending(); len(products) > 0 {
+				w.evaluate(ctx, products)
+			}
+		}
+	}
+}
+
+// listen queues the products of new price history rows, reconnecting after failures
+func (w *alertWorker) listen(ctx context.Context) {
+	for {
+		err := w.repo.ListenPriceChanges(ctx, w.enqueue)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn().Err(err).Dur("retryIn", listenRetryDelay).Msg("Price change listener stopped")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (w *alertWorker) enqueue(productID string) {
+	w.mu.Lock()
+	w.pending[productID] = true
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *alertWorker) takePending() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	products := make([]string, 0, len(w.pending))
+	for id := range w.pending {
+		products = append(products, id)
+	}
+	w.pending = make(map[string]bool)
+	return products
+}
+
+// evaluate notifies the subscriptions of productIDs (all when nil) whose
+// condition is met by the current best offer
+func (w *alertWorker) evaluate(ctx context.Context, productIDs []string) {
+	candidates, err := w.repo.Candidates(ctx, productIDs)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to load price alert subscriptions")
+		}
+		return
+	}
+
+	for i := range candidates {
+		if ctx.Err() != nil {
+			return
+		}
+		if candidates[i].Subscription.Triggered(candidates[i].Price) {
+			w.deliver(ctx, &candidates[i])
+		}
+	}
+}
+
+// deliver claims the trigger, so that concurrent workers notify it once, and
+// sends it. A failed delivery is released to be retried after a backoff, and
+// the subscription is deactivated after domain.MaxDeliveryFailures in a row.
+func (w *alertWorker) deliver(ctx context.Context, t *domain.Trigger) {
+	sub := &t.Subscription
+	logger := log.With().Str("subscription", sub.ID).Str("channel", sub.Channel).Logger()
+
+	claimed, err := w.repo.Claim(ctx, sub.ID, t.Price, sub.LastNotifiedPrice)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to claim price alert")
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	now := time.Now().UTC()
+	n := domain.NewNotification(t, now)
+	if nt, ok := w.notifiers[sub.Channel]; ok {
+		sendCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		err = nt.Notify(sendCtx, n)
+		cancel()
+	} else {
+		err = errChannelDisabled
+	}
+
+	delivery := &domain.Delivery{
+		SubscriptionID: sub.ID,
+		RetailerID:     t.RetailerID,
+		Price:          t.Price,
+		Status:         domain.NotificationSent,
+	}
+	if err != nil {
+		msg := err.Error()
+		delivery.Status, delivery.Error = domain.NotificationFailed, &msg
+		failures := sub.FailedDeliveries + 1
+		retryAt := now.Add(domain.DeliveryBackoff(failures))
+		deactivate := failures >= domain.MaxDeliveryFailures
+		logger.Warn().Err(err).Int("failures", failures).Bool("deactivated", deactivate).Msg("Price alert delivery failed")
+		if err := w.repo.Release(ctx, sub.ID, t.Price, sub.LastNotifiedPrice, retryAt, deactivate); err != nil {
+			logger.Error().Err(err).Msg("Failed to release price alert")
+		}
+	} else if sub.FailedDeliveries > 0 {
+		if err := w.repo.ClearFailures(ctx, sub.ID); err != nil {
+			logger.Error().Err(err).Msg("Failed to clear price alert failures")
+		}
+	}
+	if err := w.repo.RecordDelivery(ctx, delivery); err != nil {
+		logger.Error().Err(err).Msg("Failed to record price alert delivery")
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/alerts/domain"
+	"github.com/clumineau/pareto/apps/api/internal/alerts/notifier"
+)
+
+// newTestWorker returns a worker delivering webhook subscriptions to the
+// returned notifier, with one subscription to "phone" at 300 or below
+func newTestWorker() (*alertWorker, *memRepo, *recordingNotifier) {
+	target := 300.0
+	repo := newMemRepo("phone")
+	repo.add(domain.Subscription{
+		ID: "sub", ProductID: "phone", TargetPrice: &target,
+		Channel: domain.ChannelWebhook, Target: "https://hooks.example.com/pareto",
+	})
+	webhook := &recordingNotifier{}
+	return newAlertWorker(repo, notifier.Registry{domain.ChannelWebhook: webhook}), repo, webhook
+}
+
+func TestWorker_FiresOnlyBelowLastNotifiedPrice(t *testing.T) {
+	w, repo, webhook := newTestWorker()
+	ctx := context.Background()
+
+	steps := []struct {
+		price float64
+		fires bool
+	}{
+		{320, false},
+		{280, true},
+		// Same price again: already notified
+		{280, false},
+		{290, false},
+		{270, true},
+		{300, false},
+	}
+	sent := 0
+	for _, step := range steps {
+		repo.prices["phone"] = step.price
+		w.evaluate(ctx, []string{"phone"})
+		if step.fires {
+			sent++
+		}
+		if len(webhook.sent) != sent {
+			t.Fatalf("at %v: %d notifications, want %d", step.price, len(webhook.sent), sent)
+		}
+	}
+
+	last := webhook.sent[len(webhook.sent)-1]
+	if last.Price != 270 || last.Threshold != 300 || last.Target != "https://hooks.example.com/pareto" || last.RetailerID != "fnac" {
+		t.Errorf("notification = %+v", last)
+	}
+	if s := repo.subs["sub"]; s.LastNotifiedPrice == nil || *s.LastNotifiedPrice != 270 || s.LastNotifiedAt == nil {
+		t.Errorf("subscription = %+v, want notified at 270", s)
+	}
+	if len(repo.deliveries) != 2 || repo.deliveries[1].Status != domain.NotificationSent || repo.deliveries[1].Price != 270 {
+		t.Errorf("deliveries = %+v, want two sent", repo.deliveries)
+	}
+}
+
+func TestWorker_EvaluatesChangedProductsOnly(t *testing.T) {
+	w, repo, webhook := newTestWorker()
+	repo.prices["phone"] = 280
+
+	w.evaluate(context.Background(), []string{"tablet"})
+	if len(webhook.sent) != 0 {
+		t.Errorf("notified %d times for another product's price change", len(webhook.sent))
+	}
+}
+
+func TestWorker_LostClaim(t *testing.T) {
+	w, repo, webhook := newTestWorker()
+	repo.prices["phone"] = 280
+	triggers, _ := repo.Candidates(context.Background(), nil)
+
+	// Another worker notified the subscription after it was read
+	notified := 280.0
+	repo.subs["sub"].LastNotifiedPrice = &notified
+
+	w.deliver(context.Background(), &triggers[0])
+	if len(webhook.sent) != 0 || len(repo.deliveries) != 0 {
+		t.Errorf("sent %d notifications, recorded %d deliveries for a lost claim", len(webhook.sent), len(repo.deliveries))
+	}
+}
+
+func TestWorker_FailedDeliveryIsReleased(t *testing.T) {
+	w, repo, webhook := newTestWorker()
+	webhook.err = errors.New("webhook responded 500 Internal Server Error")
+	repo.prices["phone"] = 280
+	ctx := context.Background()
+
+	start := time.Now()
+	w.evaluate(ctx, nil)
+	s := repo.subs["sub"]
+	if s.LastNotifiedPrice != nil {
+		t.Errorf("lastNotifiedPrice = %v after a failed delivery, want it released", *s.LastNotifiedPrice)
+	}
+	if s.FailedDeliveries != 1 || s.RetryAt == nil || s.RetryAt.Before(start.Add(domain.DeliveryBackoff(1))) {
+		t.Errorf("failures = %d, retry at %v, want a retry after the backoff", s.FailedDeliveries, s.RetryAt)
+	}
+	if len(repo.deliveries) != 1 || repo.deliveries[0].Status != domain.NotificationFailed ||
+		repo.deliveries[0].Error == nil || *repo.deliveries[0].Error != webhook.err.Error() {
+		t.Errorf("deliveries = %+v, want the failure recorded", repo.deliveries)
+	}
+
+	// Not retried before the backoff expires
+	w.evaluate(ctx, nil)
+	if len(webhook.sent) != 1 {
+		t.Errorf("retried %d times during the backoff", len(webhook.sent)-1)
+	}
+
+	// Deactivated after too many consecutive failures
+	for i := 1; i < domain.MaxDeliveryFailures; i++ {
+		s.RetryAt = nil
+		w.evaluate(ctx, nil)
+	}
+	if len(webhook.sent) != domain.MaxDeliveryFailures || s.FailedDeliveries != domain.MaxDeliveryFailures || s.Active {
+		t.Errorf("sent %d, failures %d, active %v, want deactivated after %d failures",
+			len(webhook.sent), s.FailedDeliveries, s.Active, domain.MaxDeliveryFailures)
+	}
+	s.RetryAt = nil
+	w.evaluate(ctx, nil)
+	if len(webhook.sent) != domain.MaxDeliveryFailures {
+		t.Error("a deactivated subscription was retried")
+	}
+}
+
+func TestWorker_SuccessClearsFailures(t *testing.T) {
+	w, repo, webhook := newTestWorker()
+	repo.prices["phone"] = 280
+	repo.subs["sub"].FailedDeliveries = 2
+
+	w.evaluate(context.Background(), nil)
+	if s := repo.subs["sub"]; len(webhook.sent) != 1 || s.FailedDeliveries != 0 || s.RetryAt != nil {
+		t.Errorf("sent %d, subscription = %+v, want delivered with failures cleared", len(webhook.sent), s)
+	}
+}
+
+func TestWorker_DisabledChannel(t *testing.T) {
+	w, repo, _ := newTestWorker()
+	w.notifiers = notifier.Registry{}
+	repo.prices["phone"] = 280
+
+	w.evaluate(context.Background(), nil)
+	if len(repo.deliveries) != 1 || repo.deliveries[0].Error == nil || *repo.deliveries[0].Error != errChannelDisabled.Error() {
+		t.Errorf("deliveries = %+v, want a failure for the disabled channel", repo.deliveries)
+	}
+	if s := repo.subs["sub"]; s.LastNotifiedPrice != nil || s.FailedDeliveries != 1 {
+		t.Errorf("subscription = %+v, want released with one failure", s)
+	}
+}
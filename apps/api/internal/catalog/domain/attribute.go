@@ -0,0 +1,238 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attribute value types used in categories.attribute_schema
+const (
+	AttributeTypeNumber  = "number"
+	AttributeTypeString  = "string"
+	AttributeTypeBoolean = "boolean"
+)
+
+// Attribute filter operators
+const (
+	AttributeOpEq  = "eq"
+	AttributeOpGt  = "gt"
+	AttributeOpGte = "gte"
+	AttributeOpLt  = "lt"
+	AttributeOpLte = "lte"
+)
+
+// AttributeFilter is a single predicate on products.attributes,
+// e.g. attr.ram_gb[gte]=8
+type AttributeFilter struct {
+	Attribute string `json:"attribute"`
+	Operator  string `json:"operator"`
+	// Value is the raw query value until resolved against the category
+	// schema, then a float64, string or bool matching the attribute type
+	Value interface{} `json:"value"`
+}
+
+// IsRangeOperator reports whether op compares order rather than equality
+func IsRangeOperator(op string) bool {
+	switch op {
+	case AttributeOpGt, AttributeOpGte, AttributeOpLt, AttributeOpLte:
+		return true
+	}
+	return false
+}
+
+// IsAttributeOperator reports whether op is a known attribute filter operator
+func IsAttributeOperator(op string) bool {
+	return op == AttributeOpEq || IsRangeOperator(op)
+}
+
+// AttributeDef describes one attribute of a category attribute_schema
+type AttributeDef struct {
+	Type string `json:"type"`
+	// Unit is the canonical unit of number attributes, e.g. "GB" or "mAh"
+	Unit string `json:"unit,omitempty"`
+	// Enum lists the allowed values of a string attribute
+	Enum     []string `json:"enum,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	// Min and Max bound number attributes (inclusive)
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// UnmarshalJSON accepts both {"type": "number", ...} and the short form
+// "number". Definitions of any other shape are left empty rather than
+// failing, so a malformed stored schema never prevents reading a category.
+func (d *AttributeDef) UnmarshalJSON(b []byte) error {
+	var short string
+	if err := json.Unmarshal(b, &short); err == nil {
+		*d = AttributeDef{Type: short}
+		return nil
+	}
+	type plain AttributeDef
+	var def plain
+	if err := json.Unmarshal(b, &def); err != nil {
+		*d = AttributeDef{}
+		return nil
+	}
+	*d = AttributeDef(def)
+	return nil
+}
+
+// AttributeSchema maps attribute names to their definition
+type AttributeSchema map[string]AttributeDef
+
+// AttributeViolation is a single problem found in a schema or in product
+// attributes checked against one
+type AttributeViolation struct {
+	Attribute string `json:"attribute"`
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+}
+
+// Problems reports definitions that are inconsistent: unknown types, enums
+// on non-string attributes, bounds or units on non-number attributes and
+// inverted bounds
+func (s AttributeSchema) Problems() []AttributeViolation {
+	var out []AttributeViolation
+	add := func(name, rule, msg string) {
+		out = append(out, AttributeViolation{Attribute: name, Rule: rule, Message: msg})
+	}
+	for _, name := range s.names() {
+		def := s[name]
+		switch def.Type {
+		case AttributeTypeNumber, AttributeTypeString, AttributeTypeBoolean:
+		default:
+			add(name, "type", "type must be one of: number, string, boolean")
+			continue
+		}
+		if len(def.Enum) > 0 && def.Type != AttributeTypeString {
+			add(name, "enum", "enum only applies to string attributes")
+		}
+		if (def.Min != nil || def.Max != nil) && def.Type != AttributeTypeNumber {
+			add(name, "range", "min and max only apply to number attributes")
+		}
+		if def.Unit != "" && def.Type != AttributeTypeNumber {
+			add(name, "unit", "unit only applies to number attributes")
+		}
+		if def.Min != nil && def.Max != nil && *def.Min > *def.Max {
+			add(name, "range", "min must not exceed max")
+		}
+	}
+	return out
+}
+
+// Check reports how attrs fail to conform to the schema: missing required
+// attributes, attributes the schema does not declare, wrong value types,
+// values outside the enum and numbers outside min/max
+func (s AttributeSchema) Check(attrs map[string]interface{}) []AttributeViolation {
+	var out []AttributeViolation
+	add := func(name, rule, msg string) {
+		out = append(out, AttributeViolation{Attribute: name, Rule: rule, Message: msg})
+	}
+
+	for _, name := range s.names() {
+		if v, ok := attrs[name]; s[name].Required && (!ok || v == nil) {
+			add(name, "required", "is required")
+		}
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := attrs[name]
+		def, ok := s[name]
+		if !ok {
+			add(name, "unknown", "is not declared in the category schema")
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		switch def.Type {
+		case AttributeTypeNumber:
+			n, ok := v.(float64)
+			if !ok {
+				add(name, "type", "must be a number")
+				continue
+			}
+			if def.Min != nil && n < *def.Min {
+				add(name, "min", fmt.Sprintf("must be at least %g", *def.Min))
+			}
+			if def.Max != nil && n > *def.Max {
+				add(name, "max", fmt.Sprintf("must be at most %g", *def.Max))
+			}
+		case AttributeTypeString:
+			str, ok := v.(string)
+			if !ok {
+				add(name, "type", "must be a string")
+				continue
+			}
+			if len(def.Enum) > 0 && !slices.Contains(def.Enum, str) {
+				add(name, "enum", "must be one of: "+strings.Join(def.Enum, ", "))
+			}
+		case AttributeTypeBoolean:
+			if _, ok := v.(bool); !ok {
+				add(name, "type", "must be true or false")
+			}
+		}
+	}
+	return out
+}
+
+// names returns the attribute names in a stable order
+func (s AttributeSchema) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AttributeType returns the declared type of an attribute of the category
+func (c *Category) AttributeType(name string) (string, bool) {
+	def, ok := c.AttributeSchema[name]
+	if !ok || def.Type == "" {
+		return "", false
+	}
+	return def.Type, true
+}
+
+// Schema validation statuses of a CategorySchemaVersion
+const (
+	SchemaValidationPending   = "pending"
+	SchemaValidationRunning   = "running"
+	SchemaValidationCompleted = "completed"
+	SchemaValidationFailed    = "failed"
+)
+
+// CategorySchemaVersion is one revision of a category attribute_schema along
+// with the result of re-validating the category's products against it
+type CategorySchemaVersion struct {
+	CategoryID      string          `json:"categoryId"`
+	Version         int             `json:"version"`
+	AttributeSchema AttributeSchema `json:"attributeSchema"`
+	Status          string          `json:"status"`
+	CheckedCount    int             `json:"checkedCount"`
+	// NonConformingCount counts every failing product; NonConforming may be
+	// truncated and is omitted from version listings
+	NonConformingCount int                    `json:"nonConformingCount"`
+	NonConforming      []NonConformingProduct `json:"nonConforming,omitempty"`
+	Error              *string                `json:"error,omitempty"`
+	CreatedAt          time.Time              `json:"createdAt"`
+	ValidatedAt        *time.Time             `json:"validatedAt,omitempty"`
+}
+
+// NonConformingProduct lists why a product does not match a schema version
+type NonConformingProduct struct {
+	ProductID  string               `json:"productId"`
+	Name       string               `json:"name"`
+	Violations []AttributeViolation `json:"violations"`
+}
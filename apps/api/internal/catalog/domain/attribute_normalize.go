@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unitFactors maps a canonical schema unit (lower-cased) to the spellings it
+// accepts, each with the factor converting a value into the canonical unit.
+// French spellings (Go, To, pouces) are common in retailer listings.
+var unitFactors = map[string]map[string]float64{
+	"mah": {"mah": 1, "ah": 1000},
+	"gb":  {"gb": 1, "go": 1, "tb": 1024, "to": 1024, "mb": 1.0 / 1024, "mo": 1.0 / 1024},
+	"mb":  {"mb": 1, "mo": 1, "gb": 1024, "go": 1024},
+	"tb":  {"tb": 1, "to": 1, "gb": 1.0 / 1024, "go": 1.0 / 1024},
+	"w":   {"w": 1, "kw": 1000},
+	"g":   {"g": 1, "kg": 1000},
+	"hz":  {"hz": 1, "khz": 1000},
+	"mp":  {"mp": 1, "mpx": 1},
+	"inches": {
+		"inches": 1, "inch": 1, "in": 1, `"`: 1, "pouces": 1, "pouce": 1,
+		"cm": 1 / 2.54, "mm": 1 / 25.4,
+	},
+}
+
+// quantityPattern splits "6,1 pouces", "5000mAh" or "1,234,567 mAh" into
+// number and unit. Commas group thousands (the second group) only when a
+// second group or a decimal point confirms it, as in "1,234,567" or
+// "4,500.5": a lone comma is a decimal separator, so "6,123" reads 6.123.
+var quantityPattern = regexp.MustCompile(`^([-+]?(?:(\d{1,3}(?:(?:,\d{3}){2,}(?:\.\d+)?|,\d{3}\.\d+))|\d+(?:[.,]\d+)?))\s*([^\d\s].*)?$`)
+
+// parseQuantity reads a number with an optional unit and converts it into
+// canonical. Values without a unit are assumed to be in canonical already.
+func parseQuantity(s, canonical string) (float64, error) {
+	m := quantityPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("must be a number")
+	}
+	number := strings.Replace(m[1], ",", ".", 1)
+	if m[2] != "" {
+		number = strings.ReplaceAll(m[1], ",", "")
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("must be a number")
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(m[3]))
+	if unit == "" || unit == strings.ToLower(canonical) {
+		return n, nil
+	}
+	factor, ok := unitFactors[strings.ToLower(canonical)][unit]
+	if !ok {
+		if canonical == "" {
+			return 0, fmt.Errorf("must be a plain number")
+		}
+		return 0, fmt.Errorf("unit %q cannot be converted to %s", m[3], canonical)
+	}
+	// Round away floating point noise from the conversion
+	return math.Round(n*factor*1e6) / 1e6, nil
+}
+
+// UnknownAttributes policies: what product writes do with attributes that
+// the category schema does not declare
+const (
+	UnknownAttributesReject     = "reject"
+	UnknownAttributesQuarantine = "quarantine"
+)
+
+// Normalize coerces attrs to the schema: numbers given as strings ("6.1",
+// "5000 mAh", "1 To") are parsed and converted to the declared unit,
+// booleans are read from "true"/"yes"/"oui"/1 and similar, and enum values
+// take their declared spelling. Attributes the schema does not declare are
+// returned separately in unknown. Remaining problems, including missing
+// required attributes and out-of-range numbers, are reported as violations.
+func (s AttributeSchema) Normalize(attrs map[string]interface{}) (clean, unknown map[string]interface{}, violations []AttributeViolation) {
+	clean = make(map[string]interface{}, len(attrs))
+	unknown = make(map[string]interface{})
+
+	for name, v := range attrs {
+		def, ok := s[name]
+		if !ok {
+			unknown[name] = v
+			continue
+		}
+		if v == nil {
+			clean[name] = nil
+			continue
+		}
+		coerced, err := def.coerce(v)
+		if err != nil {
+			violations = append(violations, AttributeViolation{Attribute: name, Rule: "type", Message: err.Error()})
+			continue
+		}
+		clean[name] = coerced
+	}
+
+	// Type problems are already reported; Check adds required, enum and range
+	for _, v := range s.Check(clean) {
+		if v.Rule != "type" {
+			violations = append(violations, v)
+		}
+	}
+	return clean, unknown, violations
+}
+
+// coerce converts v to the Go type matching the attribute type
+func (d AttributeDef) coerce(v interface{}) (interface{}, error) {
+	switch d.Type {
+	case AttributeTypeNumber:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		case string:
+			return parseQuantity(n, d.Unit)
+		}
+		return nil, fmt.Errorf("must be a number")
+
+	case AttributeTypeBoolean:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case float64:
+			if b == 0 || b == 1 {
+				return b == 1, nil
+			}
+		case string:
+			switch strings.ToLower(strings.TrimSpace(b)) {
+			case "true", "yes", "oui", "1":
+				return true, nil
+			case "false", "no", "non", "0":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("must be true or false")
+
+	case AttributeTypeString:
+		var str string
+		switch x := v.(type) {
+		case string:
+			str = strings.TrimSpace(x)
+		case float64:
+			str = strconv.FormatFloat(x, 'f', -1, 64)
+		case bool:
+			str = strconv.FormatBool(x)
+		default:
+			return nil, fmt.Errorf("must be a string")
+		}
+		for _, e := range d.Enum {
+			if strings.EqualFold(e, str) {
+				return e, nil
+			}
+		}
+		return str, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "testing"
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in, unit string
+		want     float64
+		wantErr  bool
+	}{
+		{in: "5000mAh", unit: "mAh", want: 5000},
+		{in: "5000", unit: "mAh", want: 5000},
+		{in: "6.1", unit: "inches", want: 6.1},
+		{in: "6,1 pouces", unit: "inches", want: 6.1},
+		{in: "1 To", unit: "GB", want: 1024},
+		{in: "2,5 Ah", unit: "mAh", want: 2500},
+
+		// Commas group thousands when a second group or a decimal point
+		// confirms it
+		{in: "1,234,567", unit: "", want: 1234567},
+		{in: "1,234,567 mAh", unit: "mAh", want: 1234567},
+		{in: "1,024.5 Go", unit: "GB", want: 1024.5},
+		{in: "4,500.5mAh", unit: "mAh", want: 4500.5},
+		{in: "-1,000,000 g", unit: "g", want: -1000000},
+
+		// A lone comma is a decimal separator, even before three digits
+		{in: "6,123", unit: "", want: 6.123},
+		{in: "1,500 kg", unit: "g", want: 1500},
+		{in: "4,500 mAh", unit: "mAh", want: 4.5},
+		{in: "4,50 mAh", unit: "mAh", want: 4.5},
+		{in: "4,5000 mAh", unit: "mAh", want: 4.5},
+		{in: "1234,567", unit: "", want: 1234.567},
+
+		{in: "4,500,5", unit: "mAh", wantErr: true},
+		{in: "4,500.5.5", unit: "mAh", wantErr: true},
+		{in: "big", unit: "mAh", wantErr: true},
+		{in: "5 kg", unit: "mAh", wantErr: true},
+		{in: "5 kg", unit: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseQuantity(tt.in, tt.unit)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseQuantity(%q, %q) = %v, want an error", tt.in, tt.unit, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseQuantity(%q, %q) = %v, %v, want %v", tt.in, tt.unit, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package domain
+
+import "time"
+
+// DealScoreWindows are the look-back periods, in days, of a deal score
+var DealScoreWindows = []int{30, 90, 365}
+
+// dealRatingWindow is the look-back period, in days, the rating is based on
+const dealRatingWindow = 90
+
+// minDealSamples is the number of recorded prices below which a window is
+// too thin to rate the current price
+const minDealSamples = 3
+
+// inflatedWasPriceMargin is how far above the historical median a was_price
+// may be before the advertised discount is flagged as inflated
+const inflatedWasPriceMargin = 0.10
+
+// Deal ratings, from best to worst
+const (
+	// DealGreat means the current price matches or beats the lowest price
+	// of the rating window
+	DealGreat   = "great"
+	DealGood    = "good"
+	DealFair    = "fair"
+	DealHigh    = "high"
+	DealUnknown = "unknown"
+)
+
+// PriceWindowStats summarizes the in-stock prices recorded over the last
+// Days days. PercentileRank is the share of those prices, in percent, below
+// the current price: 0 means it was never cheaper.
+type PriceWindowStats struct {
+	Days           int      `json:"days"`
+	Low            *float64 `json:"low"`
+	Median         *float64 `json:"median"`
+	PercentileRank *float64 `json:"percentileRank"`
+	Samples        int      `json:"samples"`
+}
+
+// DiscountCheck compares an offer's advertised discount, based on its
+// was_price, with the discount against the historical median price
+type DiscountCheck struct {
+	OfferID        string  `json:"offerId"`
+	RetailerID     string  `json:"retailerId"`
+	WasPrice       float64 `json:"wasPrice"`
+	ClaimedPercent *int    `json:"claimedPercent"`
+	RealPercent    *int    `json:"realPercent"`
+	Inflated       bool    `json:"inflated"`
+}
+
+// DealScore tells whether the current best price of a product, or of one of
+// its variants, is a good deal compared with its price history. VariantID is
+// the variant rated, whose offers and history alone are considered.
+type DealScore struct {
+	ProductID    string             `json:"productId"`
+	VariantID    *string            `json:"variantId,omitempty"`
+	CurrentPrice *float64           `json:"currentPrice"`
+	RetailerID   *string            `json:"retailerId,omitempty"`
+	Rating       string             `json:"rating"`
+	Windows      []PriceWindowStats `json:"windows"`
+	Discounts    []DiscountCheck    `json:"discounts"`
+	ComputedAt   time.Time          `json:"computedAt"`
+}
+
+// NewDealScore rates the cheapest fresh in-stock offer against the window
+// statistics and checks the advertised discounts of the offers
+func NewDealScore(productID string, variantID *string, offers []Offer, windows []PriceWindowStats) *DealScore {
+	d := &DealScore{
+		ProductID:  productID,
+		VariantID:  variantID,
+		Rating:     DealUnknown,
+		Windows:    windows,
+		Discounts:  []DiscountCheck{},
+		ComputedAt: time.Now().UTC(),
+	}
+
+	if best := BestOffer(offers); best != nil {
+		price, retailerID := best.Price, best.RetailerID
+		d.CurrentPrice, d.RetailerID = &price, &retailerID
+	}
+
+	var rated *PriceWindowStats
+	for i := range windows {
+		if windows[i].Days == dealRatingWindow {
+			rated = &windows[i]
+		}
+	}
+	if rated == nil {
+		return d
+	}
+
+	if d.CurrentPrice != nil {
+		d.Rating = rateDeal(*d.CurrentPrice, rated)
+	}
+
+	if rated.Median == nil {
+		return d
+	}
+	for _, o := range offers {
+		if o.WasPrice == nil || !o.InStock || o.IsStale() {
+			continue
+		}
+		claimed := DiscountPercent(o.Price, o.WasPrice)
+		if claimed == nil {
+			continue
+		}
+		d.Discounts = append(d.Discounts, DiscountCheck{
+			OfferID:        o.ID,
+			RetailerID:     o.RetailerID,
+			WasPrice:       *o.WasPrice,
+			ClaimedPercent: claimed,
+			RealPercent:    DiscountPercent(o.Price, rated.Median),
+			Inflated:       *o.WasPrice > *rated.Median*(1+inflatedWasPriceMargin),
+		})
+	}
+	return d
+}
+
+// rateDeal places price within the window's recorded prices
+func rateDeal(price float64, w *PriceWindowStats) string {
+	switch {
+	case w.Samples < minDealSamples || w.Low == nil || w.PercentileRank == nil:
+		return DealUnknown
+	case price <= *w.Low:
+		return DealGreat
+	case *w.PercentileRank <= 25:
+		return DealGood
+	case *w.PercentileRank <= 75:
+		return DealFair
+	default:
+		return DealHigh
+	}
+}
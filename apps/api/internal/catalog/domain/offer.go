@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"math"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+)
+
+// Offer freshness
+const (
+	FreshnessFresh = "fresh"
+	// FreshnessStale offers were not scraped again within their retailer's
+	// freshness window; they are out of stock and left out of best prices
+	FreshnessStale = "stale"
+)
+
+// IsStale reports whether the offer outlived its retailer's freshness window
+func (o *Offer) IsStale() bool {
+	return o.StaleAt != nil
+}
+
+// MaxOfferBatchSize caps the number of offers ingested in one request
+const MaxOfferBatchSize = 500
+
+// Offer upsert outcomes
+const (
+	OfferCreated = "created"
+	// OfferUpdated means the price or stock changed and was recorded in the price history
+	OfferUpdated = "updated"
+	// OfferUnchanged means only the offer details and scrape time were refreshed
+	OfferUnchanged = "unchanged"
+	OfferFailed    = "failed"
+)
+
+// IngestOffersRequest is a batch of scraped offers
+type IngestOffersRequest struct {
+	Offers []CreateOfferRequest `json:"offers" validate:"required,min=1,max=500"`
+}
+
+// OfferIngestResult is the outcome of one offer of a batch. Index is its
+// position in the request.
+type OfferIngestResult struct {
+	Index   int           `json:"index"`
+	Status  string        `json:"status"`
+	OfferID *string       `json:"offerId,omitempty"`
+	Error   *apperr.Error `json:"error,omitempty"`
+}
+
+// OfferIngestReport summarizes an offer batch. Items are processed
+// independently: a failed item does not prevent the others from being stored.
+type OfferIngestReport struct {
+	Results   []OfferIngestResult `json:"results"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Failed    int                 `json:"failed"`
+}
+
+// Add records result and updates the counters
+func (r *OfferIngestReport) Add(result OfferIngestResult) {
+	r.Results = append(r.Results, result)
+	switch result.Status {
+	case OfferCreated:
+		r.Created++
+	case OfferUpdated:
+		r.Updated++
+	case OfferUnchanged:
+		r.Unchanged++
+	case OfferFailed:
+		r.Failed++
+	}
+}
+
+// Offer converts the request into an offer, deriving the discount from the
+// former price
+func (req *CreateOfferRequest) Offer() *Offer {
+	return &Offer{
+		ProductID:       req.ProductID,
+		VariantID:       req.VariantID,
+		RetailerID:      req.RetailerID,
+		Price:           req.Price,
+		Shipping:        req.Shipping,
+		Currency:        "EUR",
+		WasPrice:        req.WasPrice,
+		DiscountPercent: DiscountPercent(req.Price, req.WasPrice),
+		URL:             req.URL,
+		AffiliateURL:    req.AffiliateURL,
+		InStock:         req.InStock,
+		StockQuantity:   req.StockQuantity,
+		DeliveryDays:    req.DeliveryDays,
+		SellerName:      req.SellerName,
+		IsMarketplace:   req.IsMarketplace,
+	}
+}
+
+// DiscountPercent returns the rounded discount of price over wasPrice, or nil
+// when there is no former price above the current one
+func DiscountPercent(price float64, wasPrice *float64) *int {
+	if wasPrice == nil || *wasPrice <= 0 || *wasPrice <= price {
+		return nil
+	}
+	pct := int(math.Round((*wasPrice - price) / *wasPrice * 100))
+	return &pct
+}
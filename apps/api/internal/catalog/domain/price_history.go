@@ -0,0 +1,192 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// Price history bucket intervals
+const (
+	HistoryIntervalHour = "hour"
+	HistoryIntervalDay  = "day"
+	HistoryIntervalWeek = "week"
+)
+
+// HistoryIntervals lists the valid PriceHistoryQuery.Interval values
+var HistoryIntervals = []string{HistoryIntervalHour, HistoryIntervalDay, HistoryIntervalWeek}
+
+// ValidHistoryInterval reports whether interval is one of the HistoryInterval* constants
+func ValidHistoryInterval(interval string) bool {
+	for _, i := range HistoryIntervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxHistoryBuckets caps the number of buckets per retailer in one response
+const MaxHistoryBuckets = 1000
+
+// defaultHistorySpan is the range covered when the query has no from
+var defaultHistorySpan = map[string]time.Duration{
+	HistoryIntervalHour: 48 * time.Hour,
+	HistoryIntervalDay:  90 * 24 * time.Hour,
+	HistoryIntervalWeek: 52 * 7 * 24 * time.Hour,
+}
+
+// PriceHistoryQuery selects the price history to aggregate: prices recorded
+// in [From, To), bucketed by Interval, optionally for a single retailer or
+// variant
+type PriceHistoryQuery struct {
+	RetailerID *string
+	VariantID  *string
+	From       time.Time
+	To         time.Time
+	Interval   string
+}
+
+// DefaultHistoryFrom returns the start of the default range ending at to
+func DefaultHistoryFrom(to time.Time, interval string) time.Time {
+	return to.Add(-defaultHistorySpan[interval])
+}
+
+// PriceBucket aggregates the in-stock prices recorded during one interval.
+// Min, Avg and Max are null when no in-stock price was recorded; Last and
+// InStock are the latest recorded state, carried over from earlier buckets
+// when the interval has no sample (Samples is 0, Filled is true).
+type PriceBucket struct {
+	Time    time.Time `json:"time"`
+	Min     *float64  `json:"min"`
+	Avg     *float64  `json:"avg"`
+	Max     *float64  `json:"max"`
+	Last    *float64  `json:"last"`
+	InStock bool      `json:"inStock"`
+	Samples int       `json:"samples"`
+	Filled  bool      `json:"filled,omitempty"`
+}
+
+// RetailerPriceSeries is the bucketed price history of one variant at one
+// retailer. VariantID is nil for the offers not tied to a variant.
+type RetailerPriceSeries struct {
+	RetailerID string        `json:"retailerId"`
+	VariantID  *string       `json:"variantId,omitempty"`
+	Buckets    []PriceBucket `json:"buckets"`
+}
+
+// PriceHistorySeries is the aggregated price history of a product
+type PriceHistorySeries struct {
+	ProductID string                `json:"productId"`
+	Interval  string                `json:"interval"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Series    []RetailerPriceSeries `json:"series"`
+}
+
+// TruncateToInterval returns the start of the bucket containing t. Buckets
+// are aligned in UTC; weeks start on Monday, like date_trunc and time_bucket.
+func TruncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case HistoryIntervalHour:
+		return t.Truncate(time.Hour)
+	case HistoryIntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextBucket returns the start of the bucket following start
+func nextBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case HistoryIntervalHour:
+		return start.Add(time.Hour)
+	case HistoryIntervalWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// HistoryBucketCount returns the number of buckets between from and to
+func HistoryBucketCount(from, to time.Time, interval string) int {
+	n := 0
+	for t := TruncateToInterval(from, interval); t.Before(to); t = nextBucket(t, interval) {
+		n++
+		if n > MaxHistoryBuckets {
+			break
+		}
+	}
+	return n
+}
+
+// FillPriceGaps returns one bucket per interval between from and to, taking
+// the aggregated buckets (sorted by time) as they are and carrying the latest
+// known state into the empty ones. previous is the last state recorded before
+// from, if any; leading buckets before any known state have no price.
+// Buckets are matched by the interval their time falls in, so ones whose
+// origin is offset (e.g. time_bucket with another origin) still line up, and
+// ones falling in the same interval are merged.
+func FillPriceGaps(buckets []PriceBucket, previous *PriceBucket, interval string, from, to time.Time) []PriceBucket {
+	filled := make([]PriceBucket, 0, HistoryBucketCount(from, to, interval))
+	last := previous
+	next := 0
+	for t := TruncateToInterval(from, interval); t.Before(to); t = nextBucket(t, interval) {
+		var merged bucketMerge
+		for ; next < len(buckets) && !TruncateToInterval(buckets[next].Time, interval).After(t); next++ {
+			merged.add(buckets[next])
+		}
+		if b := merged.bucket; b != nil {
+			b.Time = t
+			filled = append(filled, *b)
+			last = b
+			continue
+		}
+
+		gap := PriceBucket{Time: t, Filled: true}
+		if last != nil {
+			gap.Last, gap.InStock = last.Last, last.InStock
+		}
+		filled = append(filled, gap)
+	}
+	return filled
+}
+
+// bucketMerge folds the buckets falling in one interval into one
+type bucketMerge struct {
+	bucket *PriceBucket
+	// priced is the sample count of the buckets behind bucket.Avg
+	priced int
+}
+
+// add folds b, which follows the buckets added so far. Averages are
+// weighted by sample count; the latest state is b's.
+func (m *bucketMerge) add(b PriceBucket) {
+	if b.Avg != nil {
+		if m.bucket != nil && m.bucket.Avg != nil {
+			avg := (*m.bucket.Avg*float64(m.priced) + *b.Avg*float64(b.Samples)) / float64(m.priced+b.Samples)
+			avg = math.Round(avg*100) / 100
+			b.Avg = &avg
+		}
+		m.priced += b.Samples
+	}
+	if m.bucket == nil {
+		m.bucket = &b
+		return
+	}
+
+	acc := m.bucket
+	if b.Avg != nil {
+		acc.Avg = b.Avg
+	}
+	if b.Min != nil && (acc.Min == nil || *b.Min < *acc.Min) {
+		acc.Min = b.Min
+	}
+	if b.Max != nil && (acc.Max == nil || *b.Max > *acc.Max) {
+		acc.Max = b.Max
+	}
+	acc.Last, acc.InStock = b.Last, b.InStock
+	acc.Samples += b.Samples
+}
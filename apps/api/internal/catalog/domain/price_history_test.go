@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFillPriceGaps(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC) }
+	price := func(v float64) *float64 { return &v }
+	sample := func(at time.Time, min, avg, max, last float64, samples int) PriceBucket {
+		return PriceBucket{Time: at, Min: price(min), Avg: price(avg), Max: price(max), Last: price(last), InStock: true, Samples: samples}
+	}
+	stockOut := func(at time.Time, last float64) PriceBucket {
+		return PriceBucket{Time: at, Last: price(last), Samples: 1}
+	}
+
+	tests := []struct {
+		name     string
+		buckets  []PriceBucket
+		previous *PriceBucket
+		from     time.Time
+		want     []string
+	}{
+		{
+			name: "no samples and no previous state",
+			from: day(1, 0),
+			want: []string{"10-01 filled last=- out", "10-02 filled last=- out", "10-03 filled last=- out"},
+		},
+		{
+			name:     "previous state carried into gaps",
+			buckets:  []PriceBucket{sample(day(2, 0), 90, 95, 100, 90, 2)},
+			previous: &PriceBucket{Last: price(120), InStock: true},
+			from:     day(1, 12),
+			want:     []string{"10-01 filled last=120 in", "10-02 90/95/100 last=90 in n=2", "10-03 filled last=90 in"},
+		},
+		{
+			name:    "stock-out carried",
+			buckets: []PriceBucket{stockOut(day(1, 0), 80)},
+			from:    day(1, 0),
+			want:    []string{"10-01 -/-/- last=80 out n=1", "10-02 filled last=80 out", "10-03 filled last=80 out"},
+		},
+		{
+			name:    "buckets with an offset origin",
+			buckets: []PriceBucket{sample(day(1, 2), 100, 100, 100, 100, 1), sample(day(3, 2), 80, 80, 80, 80, 1)},
+			from:    day(1, 0),
+			want:    []string{"10-01 100/100/100 last=100 in n=1", "10-02 filled last=100 in", "10-03 80/80/80 last=80 in n=1"},
+		},
+		{
+			name: "buckets in the same interval merged",
+			buckets: []PriceBucket{
+				sample(day(2, 1), 90, 100, 110, 110, 3),
+				stockOut(day(2, 13), 70),
+				sample(day(2, 20), 60, 60, 60, 60, 1),
+			},
+			from: day(1, 0),
+			want: []string{"10-01 filled last=- out", "10-02 60/90/110 last=60 in n=5", "10-03 filled last=60 in"},
+		},
+		{
+			name:    "bucket starting before from",
+			buckets: []PriceBucket{sample(day(1, 0), 50, 50, 50, 50, 1)},
+			from:    day(1, 6),
+			want:    []string{"10-01 50/50/50 last=50 in n=1", "10-02 filled last=50 in", "10-03 filled last=50 in"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FillPriceGaps(tt.buckets, tt.previous, HistoryIntervalDay, tt.from, day(4, 0))
+			var summary []string
+			for _, b := range got {
+				summary = append(summary, formatBucket(b))
+			}
+			if strings.Join(summary, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("buckets:\n%s\nwant:\n%s", strings.Join(summary, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func formatBucket(b PriceBucket) string {
+	f := func(p *float64) string {
+		if p == nil {
+			return "-"
+		}
+		return fmt.Sprint(*p)
+	}
+	stock := "out"
+	if b.InStock {
+		stock = "in"
+	}
+	if b.Filled {
+		return fmt.Sprintf("%s filled last=%s %s", b.Time.Format("01-02"), f(b.Last), stock)
+	}
+	return fmt.Sprintf("%s %s/%s/%s last=%s %s n=%d", b.Time.Format("01-02"), f(b.Min), f(b.Avg), f(b.Max), f(b.Last), stock, b.Samples)
+}
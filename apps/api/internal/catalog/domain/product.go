@@ -24,6 +24,10 @@ type Product struct {
 	CreatedAt   time.Time              `json:"createdAt" db:"created_at"`
 	UpdatedAt   time.Time              `json:"updatedAt" db:"updated_at"`
 	ScrapedAt   *time.Time             `json:"scrapedAt,omitempty" db:"scraped_at"`
+
+	// QuarantinedAttributes holds attributes the category schema does not
+	// declare, kept aside when the quarantine policy is configured
+	QuarantinedAttributes map[string]interface{} `json:"quarantinedAttributes,omitempty" db:"quarantined_attributes"`
 }
 
 // Variant represents a product variant (color, storage combinations)
@@ -66,21 +70,27 @@ type Offer struct {
 	ScrapedAt       time.Time  `json:"scrapedAt" db:"scraped_at"`
 	CreatedAt       time.Time  `json:"createdAt" db:"created_at"`
 	UpdatedAt       time.Time  `json:"updatedAt" db:"updated_at"`
+
+	// StaleAt is set when the offer outlived its retailer's freshness window
+	// without being scraped again; Freshness is one of the Freshness* values
+	StaleAt   *time.Time `json:"staleAt,omitempty" db:"stale_at"`
+	Freshness string     `json:"freshness" db:"-"`
 }
 
 // Category represents a product category
 type Category struct {
-	ID              string                 `json:"id" db:"id"`
-	Name            string                 `json:"name" db:"name"`
-	Slug            string                 `json:"slug" db:"slug"`
-	ParentID        *string                `json:"parentId,omitempty" db:"parent_id"`
-	Description     *string                `json:"description,omitempty" db:"description"`
-	ImageURL        *string                `json:"imageUrl,omitempty" db:"image_url"`
-	AttributeSchema map[string]interface{} `json:"attributeSchema" db:"attribute_schema"`
-	SortOrder       int                    `json:"sortOrder" db:"sort_order"`
-	Active          bool                   `json:"active" db:"active"`
-	CreatedAt       time.Time              `json:"createdAt" db:"created_at"`
-	UpdatedAt       time.Time              `json:"updatedAt" db:"updated_at"`
+	ID              string          `json:"id" db:"id"`
+	Name            string          `json:"name" db:"name"`
+	Slug            string          `json:"slug" db:"slug"`
+	ParentID        *string         `json:"parentId,omitempty" db:"parent_id"`
+	Description     *string         `json:"description,omitempty" db:"description"`
+	ImageURL        *string         `json:"imageUrl,omitempty" db:"image_url"`
+	AttributeSchema AttributeSchema `json:"attributeSchema" db:"attribute_schema"`
+	SchemaVersion   int             `json:"schemaVersion" db:"schema_version"`
+	SortOrder       int             `json:"sortOrder" db:"sort_order"`
+	Active          bool            `json:"active" db:"active"`
+	CreatedAt       time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time       `json:"updatedAt" db:"updated_at"`
 }
 
 // Retailer represents a store/merchant
@@ -99,6 +109,10 @@ type Retailer struct {
 	Priority             int       `json:"priority" db:"priority"`
 	CreatedAt            time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt            time.Time `json:"updatedAt" db:"updated_at"`
+
+	// FreshnessWindowHours overrides the freshness window derived from the
+	// rate limit and priority (see FreshnessWindow)
+	FreshnessWindowHours *int `json:"freshnessWindowHours,omitempty" db:"freshness_window_hours"`
 }
 
 // PriceHistory represents a historical price record
@@ -155,6 +169,9 @@ type ProductWithOffers struct {
 	Offers     []Offer   `json:"offers"`
 	BestPrice  *float64  `json:"bestPrice,omitempty"`
 	OfferCount int       `json:"offerCount"`
+
+	// DealScore is only computed for product details
+	DealScore *DealScore `json:"dealScore,omitempty"`
 }
 
 // ProductWithVariants includes product with its variants
@@ -179,7 +196,7 @@ type CreateProductRequest struct {
 	Name        string                 `json:"name" validate:"required,min=1,max=500"`
 	Brand       string                 `json:"brand" validate:"required,min=1,max=100"`
 	Model       string                 `json:"model" validate:"required,min=1,max=200"`
-	EAN         *string                `json:"ean,omitempty" validate:"omitempty,len=13"`
+	EAN         *string                `json:"ean,omitempty" validate:"omitempty,len=13,ean13"`
 	SKU         *string                `json:"sku,omitempty" validate:"omitempty,max=100"`
 	ImageURL    *string                `json:"imageUrl,omitempty" validate:"omitempty,url"`
 	Images      []string               `json:"images,omitempty" validate:"omitempty,dive,url"`
@@ -187,6 +204,9 @@ type CreateProductRequest struct {
 	Source      string                 `json:"source" validate:"required,oneof=brand manual api"`
 	SourceURL   *string                `json:"sourceUrl,omitempty" validate:"omitempty,url"`
 	Description *string                `json:"description,omitempty" validate:"omitempty,max=5000"`
+
+	// QuarantinedAttributes is filled by the catalog service, never by clients
+	QuarantinedAttributes map[string]interface{} `json:"-"`
 }
 
 // UpdateProductRequest represents a request to update a product
@@ -195,20 +215,64 @@ type UpdateProductRequest struct {
 	Name        *string                `json:"name,omitempty" validate:"omitempty,min=1,max=500"`
 	Brand       *string                `json:"brand,omitempty" validate:"omitempty,min=1,max=100"`
 	Model       *string                `json:"model,omitempty" validate:"omitempty,min=1,max=200"`
-	EAN         *string                `json:"ean,omitempty" validate:"omitempty,len=13"`
+	EAN         *string                `json:"ean,omitempty" validate:"omitempty,len=13,ean13"`
 	SKU         *string                `json:"sku,omitempty" validate:"omitempty,max=100"`
 	ImageURL    *string                `json:"imageUrl,omitempty" validate:"omitempty,url"`
 	Images      []string               `json:"images,omitempty" validate:"omitempty,dive,url"`
 	Attributes  map[string]interface{} `json:"attributes,omitempty"`
 	Description *string                `json:"description,omitempty" validate:"omitempty,max=5000"`
 	Active      *bool                  `json:"active,omitempty"`
+
+	// QuarantinedAttributes is filled by the catalog service, never by clients
+	QuarantinedAttributes map[string]interface{} `json:"-"`
+}
+
+// CreateCategoryRequest represents a request to create a category
+type CreateCategoryRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=200"`
+	// Slug defaults to a unique slug derived from Name
+	Slug            *string         `json:"slug,omitempty" validate:"omitempty,min=1,max=200"`
+	ParentID        *string         `json:"parentId,omitempty" validate:"omitempty,uuid"`
+	Description     *string         `json:"description,omitempty" validate:"omitempty,max=5000"`
+	ImageURL        *string         `json:"imageUrl,omitempty" validate:"omitempty,url"`
+	AttributeSchema AttributeSchema `json:"attributeSchema,omitempty"`
+	// SortOrder places the category among its siblings; defaults to last
+	SortOrder *int  `json:"sortOrder,omitempty" validate:"omitempty,min=0"`
+	Active    *bool `json:"active,omitempty"`
+}
+
+// UpdateCategoryRequest represents a request to update a category.
+// Its parent changes through MoveCategoryRequest and its attribute schema
+// through UpdateAttributeSchemaRequest.
+type UpdateCategoryRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	Slug        *string `json:"slug,omitempty" validate:"omitempty,min=1,max=200"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=5000"`
+	ImageURL    *string `json:"imageUrl,omitempty" validate:"omitempty,url"`
+	SortOrder   *int    `json:"sortOrder,omitempty" validate:"omitempty,min=0"`
+	Active      *bool   `json:"active,omitempty"`
+}
+
+// MoveCategoryRequest re-parents a category; a nil ParentID makes it a root
+type MoveCategoryRequest struct {
+	ParentID *string `json:"parentId" validate:"omitempty,uuid"`
+	// SortOrder places the category among its new siblings; defaults to last
+	SortOrder *int `json:"sortOrder,omitempty" validate:"omitempty,min=0"`
+}
+
+// UpdateAttributeSchemaRequest replaces the attribute schema of a category,
+// creating a new schema version
+type UpdateAttributeSchemaRequest struct {
+	AttributeSchema AttributeSchema `json:"attributeSchema" validate:"required"`
+	// BaseVersion, when set, must match the current version (optimistic locking)
+	BaseVersion *int `json:"baseVersion,omitempty" validate:"omitempty,min=1"`
 }
 
 // CreateVariantRequest represents a request to create a variant
 type CreateVariantRequest struct {
-	ProductID string                 `json:"productId" validate:"required,uuid"`
+	ProductID string                 `json:"-"` // taken from the URL
 	SKU       string                 `json:"sku" validate:"required,min=1,max=100"`
-	EAN       *string                `json:"ean,omitempty" validate:"omitempty,len=13"`
+	EAN       *string                `json:"ean,omitempty" validate:"omitempty,len=13,ean13"`
 	Color     *string                `json:"color,omitempty" validate:"omitempty,max=50"`
 	ColorHex  *string                `json:"colorHex,omitempty" validate:"omitempty,hexcolor"`
 	StorageGB *int                   `json:"storageGb,omitempty" validate:"omitempty,min=1"`
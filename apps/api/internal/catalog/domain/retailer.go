@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Freshness window bounds. Without an explicit window, offers of a default
+// retailer (2s rate limit) expire after a day; slower rate limits stretch the
+// window proportionally and low-priority retailers, scraped less often, get
+// twice as long.
+const (
+	baseFreshnessWindow  = 24 * time.Hour
+	maxFreshnessWindow   = 7 * 24 * time.Hour
+	defaultRateLimitMs   = 2000
+	lowPriorityThreshold = 50
+)
+
+// FreshnessWindow returns how long an offer of the retailer stays fresh
+// after its last scrape
+func (r *Retailer) FreshnessWindow() time.Duration {
+	if r.FreshnessWindowHours != nil && *r.FreshnessWindowHours > 0 {
+		return time.Duration(*r.FreshnessWindowHours) * time.Hour
+	}
+
+	window := baseFreshnessWindow
+	if r.RateLimitMs > defaultRateLimitMs {
+		window = window * time.Duration(r.RateLimitMs) / defaultRateLimitMs
+	}
+	if r.Priority < lowPriorityThreshold {
+		window *= 2
+	}
+	return min(window, maxFreshnessWindow)
+}
+
+// AntiBotLevels lists the valid Retailer.AntiBotLevel values, least protected first
+var AntiBotLevels = []string{AntiBotNone, AntiBotLight, AntiBotMedium, AntiBotHeavy}
+
+// ValidAntiBotLevel reports whether level is one of the AntiBot* constants
+func ValidAntiBotLevel(level string) bool {
+	for _, l := range AntiBotLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Affiliate URL template placeholders. {product_url} is mandatory and receives
+// the query-escaped retailer product URL.
+const (
+	AffiliatePlaceholderProductURL  = "{product_url}"
+	AffiliatePlaceholderAffiliateID = "{affiliate_id}"
+)
+
+// retailerIDPattern matches retailer identifiers such as "amazon_fr"
+var retailerIDPattern = regexp.MustCompile(`^[a-z0-9]+(?:_[a-z0-9]+)*$`)
+
+// ValidRetailerID reports whether id is a lowercase, underscore-separated identifier
+func ValidRetailerID(id string) bool {
+	return retailerIDPattern.MatchString(id)
+}
+
+// CheckAffiliateURLTemplate reports what is wrong with an affiliate URL
+// template: it must contain {product_url}, use no other placeholder than
+// {affiliate_id} (which requires an affiliate ID) and expand to an absolute
+// http(s) URL. It returns an empty string when the template is valid.
+func CheckAffiliateURLTemplate(template string, affiliateID *string) string {
+	if !strings.Contains(template, AffiliatePlaceholderProductURL) {
+		return "must contain the " + AffiliatePlaceholderProductURL + " placeholder"
+	}
+
+	rest := template
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			break
+		}
+		if rest[open] == '}' {
+			return "has an unmatched '}'"
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "has an unmatched '{'"
+		}
+		placeholder := rest[open : open+end+1]
+		switch placeholder {
+		case AffiliatePlaceholderProductURL:
+		case AffiliatePlaceholderAffiliateID:
+			if affiliateID == nil || *affiliateID == "" {
+				return "uses " + placeholder + " but the retailer has no affiliate ID"
+			}
+		default:
+			return fmt.Sprintf("has unknown placeholder %s; allowed: %s, %s",
+				placeholder, AffiliatePlaceholderProductURL, AffiliatePlaceholderAffiliateID)
+		}
+		rest = rest[open+end+1:]
+	}
+
+	u, err := url.Parse(expandAffiliateTemplate(template, "https://example.com/p", "id"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be an absolute http(s) URL"
+	}
+	return ""
+}
+
+// AffiliateURL returns the affiliate link to productURL, or productURL itself
+// when the retailer has no affiliate URL template
+func (r *Retailer) AffiliateURL(productURL string) string {
+	if r.AffiliateURLTemplate == nil || *r.AffiliateURLTemplate == "" {
+		return productURL
+	}
+	affiliateID := ""
+	if r.AffiliateID != nil {
+		affiliateID = *r.AffiliateID
+	}
+	return expandAffiliateTemplate(*r.AffiliateURLTemplate, productURL, affiliateID)
+}
+
+func expandAffiliateTemplate(template, productURL, affiliateID string) string {
+	return strings.NewReplacer(
+		AffiliatePlaceholderProductURL, url.QueryEscape(productURL),
+		AffiliatePlaceholderAffiliateID, url.QueryEscape(affiliateID),
+	).Replace(template)
+}
+
+// CreateRetailerRequest represents a request to create a retailer
+type CreateRetailerRequest struct {
+	ID                   string  `json:"id" validate:"required,min=2,max=50"`
+	Name                 string  `json:"name" validate:"required,min=1,max=200"`
+	Slug                 *string `json:"slug,omitempty" validate:"omitempty,min=1,max=100"`
+	WebsiteURL           string  `json:"websiteUrl" validate:"required,url"`
+	LogoURL              *string `json:"logoUrl,omitempty" validate:"omitempty,url"`
+	AffiliateNetwork     *string `json:"affiliateNetwork,omitempty" validate:"omitempty,min=1,max=50"`
+	AffiliateID          *string `json:"affiliateId,omitempty" validate:"omitempty,min=1,max=200"`
+	AffiliateURLTemplate *string `json:"affiliateUrlTemplate,omitempty" validate:"omitempty,max=2000"`
+	RateLimitMs          *int    `json:"rateLimitMs,omitempty" validate:"omitempty,gte=0,lte=600000"`
+	AntiBotLevel         *string `json:"antiBotLevel,omitempty"`
+	Active               *bool   `json:"active,omitempty"`
+	Priority             *int    `json:"priority,omitempty" validate:"omitempty,gte=0,lte=1000"`
+	FreshnessWindowHours *int    `json:"freshnessWindowHours,omitempty" validate:"omitempty,gte=1,lte=720"`
+}
+
+// UpdateRetailerRequest represents a request to update a retailer
+type UpdateRetailerRequest struct {
+	Name                 *string `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	Slug                 *string `json:"slug,omitempty" validate:"omitempty,min=1,max=100"`
+	WebsiteURL           *string `json:"websiteUrl,omitempty" validate:"omitempty,url"`
+	LogoURL              *string `json:"logoUrl,omitempty" validate:"omitempty,url"`
+	AffiliateNetwork     *string `json:"affiliateNetwork,omitempty" validate:"omitempty,min=1,max=50"`
+	AffiliateID          *string `json:"affiliateId,omitempty" validate:"omitempty,min=1,max=200"`
+	AffiliateURLTemplate *string `json:"affiliateUrlTemplate,omitempty" validate:"omitempty,max=2000"`
+	RateLimitMs          *int    `json:"rateLimitMs,omitempty" validate:"omitempty,gte=0,lte=600000"`
+	AntiBotLevel         *string `json:"antiBotLevel,omitempty"`
+	Active               *bool   `json:"active,omitempty"`
+	Priority             *int    `json:"priority,omitempty" validate:"omitempty,gte=0,lte=1000"`
+	// FreshnessWindowHours 0 reverts to the window derived from rate limit and priority
+	FreshnessWindowHours *int `json:"freshnessWindowHours,omitempty" validate:"omitempty,gte=0,lte=720"`
+}
+
+// UpdateRetailerStatusRequest toggles whether a retailer is used and how it ranks
+type UpdateRetailerStatusRequest struct {
+	Active   *bool `json:"active,omitempty"`
+	Priority *int  `json:"priority,omitempty" validate:"omitempty,gte=0,lte=1000"`
+}
+
+// Retailer audit actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditInfo identifies who made a change, for the audit log
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+// FieldChange is the before and after value of a changed field
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RetailerAuditEntry records one change made to a retailer. Changes is keyed
+// by the JSON field name; on create From is null, on delete To is null.
+type RetailerAuditEntry struct {
+	ID         int64                  `json:"id" db:"id"`
+	RetailerID string                 `json:"retailerId" db:"retailer_id"`
+	Action     string                 `json:"action" db:"action"`
+	Changes    map[string]FieldChange `json:"changes" db:"changes"`
+	Actor      *string                `json:"actor,omitempty" db:"actor"`
+	RequestID  *string                `json:"requestId,omitempty" db:"request_id"`
+	CreatedAt  time.Time              `json:"createdAt" db:"created_at"`
+}
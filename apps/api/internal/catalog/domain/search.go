@@ -0,0 +1,36 @@
+package domain
+
+import "strings"
+
+// ProductSearchHit is a product search result ranked by relevance
+type ProductSearchHit struct {
+	ProductWithOffers
+	// Score is the relevance of the hit in [0, 1]; exact EAN/SKU matches score 1
+	Score float64 `json:"score"`
+	// Highlights holds the matched fields (name, brand, model, ean, sku) with
+	// matching fragments wrapped in <mark> tags; other text is HTML-escaped
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Suggestion kinds
+const (
+	SuggestionProduct  = "product"
+	SuggestionBrand    = "brand"
+	SuggestionCategory = "category"
+)
+
+// Suggestion is a type-ahead entry: a product, brand or category name
+type Suggestion struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	// ID and Slug identify products and categories; brands have neither
+	ID    *string `json:"id,omitempty"`
+	Slug  *string `json:"slug,omitempty"`
+	Score float64 `json:"score"`
+}
+
+// NormalizeQuery trims a search query and collapses its runs of whitespace
+// into single spaces
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
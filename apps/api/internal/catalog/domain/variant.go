@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+)
+
+// UpdateVariantRequest represents a request to update a variant
+type UpdateVariantRequest struct {
+	SKU        *string                `json:"sku,omitempty" validate:"omitempty,min=1,max=100"`
+	EAN        *string                `json:"ean,omitempty" validate:"omitempty,len=13,ean13"`
+	Color      *string                `json:"color,omitempty" validate:"omitempty,max=50"`
+	ColorHex   *string                `json:"colorHex,omitempty" validate:"omitempty,hexcolor"`
+	StorageGB  *int                   `json:"storageGb,omitempty" validate:"omitempty,min=1"`
+	RAMGB      *int                   `json:"ramGb,omitempty" validate:"omitempty,min=1"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	ImageURL   *string                `json:"imageUrl,omitempty" validate:"omitempty,url"`
+	MSRP       *float64               `json:"msrp,omitempty" validate:"omitempty,min=0"`
+	Active     *bool                  `json:"active,omitempty"`
+}
+
+// VariantMatrix lays a product's variants out by color (rows) and storage
+// (columns). Every row has one cell per entry of Storages; a cell without
+// variants is a combination the product is not sold in.
+type VariantMatrix struct {
+	ProductID string             `json:"productId"`
+	Storages  []*int             `json:"storages"`
+	Rows      []VariantMatrixRow `json:"rows"`
+}
+
+// VariantMatrixRow holds the variants of one color. Color is null for
+// variants without a color.
+type VariantMatrixRow struct {
+	Color    *string             `json:"color"`
+	ColorHex *string             `json:"colorHex,omitempty"`
+	Cells    []VariantMatrixCell `json:"cells"`
+}
+
+// VariantMatrixCell holds the variants of one color and storage combination,
+// usually one; more when variants also differ in RAM or other attributes.
+// BestPrice is the lowest fresh in-stock price across them.
+type VariantMatrixCell struct {
+	StorageGB *int                `json:"storageGb"`
+	Variants  []VariantWithOffers `json:"variants"`
+	BestPrice *float64            `json:"bestPrice,omitempty"`
+}
+
+// VariantWithOffers is a variant with its cheapest fresh in-stock offer
+type VariantWithOffers struct {
+	Variant
+	BestOffer  *Offer `json:"bestOffer,omitempty"`
+	OfferCount int    `json:"offerCount"`
+}
+
+// NewVariantMatrix builds the color × storage matrix of a product from its
+// variants and offers. Colors keep the order of their first variant, storages
+// are sorted ascending with variants lacking storage last.
+func NewVariantMatrix(productID string, variants []Variant, offers []Offer) *VariantMatrix {
+	m := &VariantMatrix{ProductID: productID, Storages: []*int{}, Rows: []VariantMatrixRow{}}
+
+	byVariant := make(map[string][]Offer)
+	for _, o := range offers {
+		if o.VariantID != nil {
+			byVariant[*o.VariantID] = append(byVariant[*o.VariantID], o)
+		}
+	}
+
+	// Column and row keys; colors compare case-insensitively
+	storageSet := make(map[int]bool)
+	hasNoStorage := false
+	rowIndex := make(map[string]int)
+	for _, v := range variants {
+		if v.StorageGB == nil {
+			hasNoStorage = true
+		} else {
+			storageSet[*v.StorageGB] = true
+		}
+		key := colorKey(v.Color)
+		if _, ok := rowIndex[key]; !ok {
+			rowIndex[key] = len(m.Rows)
+			m.Rows = append(m.Rows, VariantMatrixRow{Color: v.Color, ColorHex: v.ColorHex})
+		}
+	}
+
+	storages := make([]int, 0, len(storageSet))
+	for gb := range storageSet {
+		storages = append(storages, gb)
+	}
+	sort.Ints(storages)
+	storageCol := make(map[int]int, len(storages))
+	for i, gb := range storages {
+		storageCol[gb] = i
+		m.Storages = append(m.Storages, &storages[i])
+	}
+	noStorage := len(m.Storages)
+	if hasNoStorage {
+		m.Storages = append(m.Storages, nil)
+	}
+
+	for i := range m.Rows {
+		m.Rows[i].Cells = make([]VariantMatrixCell, len(m.Storages))
+		for j, gb := range m.Storages {
+			m.Rows[i].Cells[j] = VariantMatrixCell{StorageGB: gb, Variants: []VariantWithOffers{}}
+		}
+	}
+
+	for _, v := range variants {
+		col := noStorage
+		if v.StorageGB != nil {
+			col = storageCol[*v.StorageGB]
+		}
+		cell := &m.Rows[rowIndex[colorKey(v.Color)]].Cells[col]
+
+		vo := VariantWithOffers{Variant: v, OfferCount: len(byVariant[v.ID])}
+		vo.BestOffer = BestOffer(byVariant[v.ID])
+		if vo.BestOffer != nil && (cell.BestPrice == nil || vo.BestOffer.Price < *cell.BestPrice) {
+			price := vo.BestOffer.Price
+			cell.BestPrice = &price
+		}
+		cell.Variants = append(cell.Variants, vo)
+	}
+	return m
+}
+
+// BestOffer returns the cheapest fresh in-stock offer, or nil if there is none
+func BestOffer(offers []Offer) *Offer {
+	var best *Offer
+	for i := range offers {
+		if !offers[i].InStock || offers[i].IsStale() {
+			continue
+		}
+		if best == nil || offers[i].Price < best.Price {
+			best = &offers[i]
+		}
+	}
+	return best
+}
+
+func colorKey(color *string) string {
+	if color == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(*color))
+}
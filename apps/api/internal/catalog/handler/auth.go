@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/httpx"
+)
+
+type apiKeyContextKey struct{}
+
+// requireAPIKey only lets through requests carrying one of keys as a bearer
+// token. With no keys configured every request is rejected. The identity of
+// the accepted key is available to handlers through apiKeyID.
+func requireAPIKey(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				httpx.RespondError(w, http.StatusUnauthorized, "A bearer API key is required")
+				return
+			}
+			if !validAPIKey(keys, token) {
+				httpx.RespondError(w, http.StatusForbidden, "Invalid API key")
+				return
+			}
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, keyFingerprint(token))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// validAPIKey compares token against every key in constant time
+func validAPIKey(keys []string, token string) bool {
+	valid := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// apiKeyID returns the identity of the API key that authenticated the
+// request, or "" when it went through no requireAPIKey
+func apiKeyID(ctx context.Context) string {
+	id, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return id
+}
+
+// keyFingerprint identifies a key without revealing it: the first 12 hex
+// digits of its SHA-256
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "apikey:" + hex.EncodeToString(sum[:])[:12]
+}
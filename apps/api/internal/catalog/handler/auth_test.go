@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+)
+
+func TestCategoryRouter_WritesRequireAdminKey(t *testing.T) {
+	testRequireAdminKey(t, NewCategoryRouter(nil, []string{"admin-key"}), []route{
+		{http.MethodPost, "/"},
+		{http.MethodPut, "/a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{http.MethodDelete, "/a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{http.MethodPost, "/a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11/move"},
+		{http.MethodPut, "/a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11/schema"},
+	})
+}
+
+func TestRetailerRouter_AdminRoutesRequireAdminKey(t *testing.T) {
+	testRequireAdminKey(t, NewRetailerRouter(nil, []string{"admin-key"}), []route{
+		{http.MethodPost, "/"},
+		{http.MethodPut, "/fnac"},
+		{http.MethodDelete, "/fnac"},
+		{http.MethodPut, "/fnac/status"},
+		{http.MethodGet, "/fnac/audit"},
+	})
+}
+
+func TestAuditInfo_RecordsKeyIdentity(t *testing.T) {
+	var got domain.AuditInfo
+	h := middleware.RequestID(requireAPIKey([]string{"first-key", "second-key"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = auditInfo(r) }),
+	))
+
+	actors := map[string]bool{}
+	for _, key := range []string{"first-key", "second-key"} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !strings.HasPrefix(got.Actor, "apikey:") || strings.Contains(got.Actor, key) || got.RequestID == "" {
+			t.Errorf("audit info for %s = %+v, want a key fingerprint and a request ID", key, got)
+		}
+		actors[got.Actor] = true
+	}
+	if len(actors) != 2 {
+		t.Errorf("actors = %v, want one per key", actors)
+	}
+}
+
+type route struct{ method, path string }
+
+// testRequireAdminKey checks that each route rejects requests without
+// the admin key. Requests that get past the key check fail decoding the
+// empty body, before reaching the (nil) service.
+func testRequireAdminKey(t *testing.T, router http.Handler, routes []route) {
+	t.Helper()
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no key", "", http.StatusUnauthorized},
+		{"not a bearer token", "admin-key", http.StatusUnauthorized},
+		{"wrong key", "Bearer other-key", http.StatusForbidden},
+	}
+
+	for _, route := range routes {
+		for _, tt := range tests {
+			t.Run(route.method+" "+route.path+" "+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, route.path, strings.NewReader(""))
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != tt.want {
+					t.Errorf("status = %d, want %d", rec.Code, tt.want)
+				}
+			})
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+	req.Header.Set("Authorization", "Bearer admin-key")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("authorized create with an empty body = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
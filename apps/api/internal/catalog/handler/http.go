@@ -2,242 +2,874 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
 
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
 	"github.com/clumineau/pareto/apps/api/internal/shared/cache"
-	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/httpx"
 )
 
+// suggestCacheTTL bounds how stale type-ahead suggestions may get
+const suggestCacheTTL = time.Minute
+
 // NewRouter creates a new product router
-func NewRouter(db *database.DB, redis *cache.Client) http.Handler {
+func NewRouter(svc ProductService, redis *cache.Client) http.Handler {
 	r := chi.NewRouter()
 
-	h := &ProductHandler{db: db, cache: redis}
+	h := &ProductHandler{svc: svc, cache: redis}
 
 	r.Get("/", h.List)
 	r.Post("/", h.Create)
 	r.Get("/search", h.Search)
+	r.Get("/suggest", h.Suggest)
 	r.Get("/{id}", h.GetByID)
 	r.Put("/{id}", h.Update)
 	r.Delete("/{id}", h.Delete)
 	r.Get("/{id}/prices", h.GetPrices)
 	r.Get("/{id}/prices/history", h.GetPriceHistory)
+	r.Get("/{id}/deal-score", h.GetDealScore)
+	r.Get("/{id}/variants", h.ListVariants)
+	r.Post("/{id}/variants", h.CreateVariant)
+	r.Get("/{id}/variants/matrix", h.GetVariantMatrix)
+	r.Get("/{id}/variants/{variantId}", h.GetVariant)
+	r.Put("/{id}/variants/{variantId}", h.UpdateVariant)
+	r.Delete("/{id}/variants/{variantId}", h.DeleteVariant)
 
 	return r
 }
 
 // ProductHandler handles product requests
 type ProductHandler struct {
-	db    *database.DB
+	svc   ProductService
 	cache *cache.Client
 }
 
 // List returns a paginated list of products
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"items":      []interface{}{},
-		"page":       1,
-		"perPage":    20,
-		"total":      0,
-		"totalPages": 0,
-	})
+	params, err := productListParams(r)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	result, err := h.svc.ListProducts(r.Context(), params)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, result)
 }
 
-// GetByID returns a product by ID
+// GetByID returns a product by ID, falling back to a slug lookup
 func (h *ProductHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"id":   id,
-		"name": "Sample Product",
-	})
+	var (
+		product *domain.ProductWithOffers
+		err     error
+	)
+	if isUUID(id) {
+		product, err = h.svc.GetProduct(r.Context(), id)
+	} else {
+		product, err = h.svc.GetProductBySlug(r.Context(), id)
+	}
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, product)
 }
 
 // Search searches for products
 func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
+	if query == "" {
+		httpx.RespondError(w, http.StatusBadRequest, "q is required")
+		return
+	}
 
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"query":      query,
-		"items":      []interface{}{},
-		"page":       1,
-		"perPage":    20,
-		"total":      0,
-		"totalPages": 0,
-	})
+	params, err := productListParams(r)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	result, err := h.svc.SearchProducts(r.Context(), query, params)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, result)
+}
+
+// suggestCacheKey returns the cache key of the suggestions for query. The
+// query is normalized as the service does, so that queries differing only in
+// case or spacing share an entry.
+func suggestCacheKey(query string, limit int) string {
+	return fmt.Sprintf("suggest:%d:%s", limit, strings.ToLower(domain.NormalizeQuery(query)))
+}
+
+// Suggest returns type-ahead suggestions, served from cache when possible
+func (h *ProductHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	p := newQueryParser(r)
+	query := p.string("q")
+	if query == "" {
+		httpx.RespondError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := p.int("limit", repository.DefaultSuggestLimit)
+	if limit < 1 {
+		p.fail("limit", "min", "must be at least 1")
+	}
+	if err := p.err(); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+	limit = min(limit, repository.MaxSuggestLimit)
+
+	key := suggestCacheKey(query, limit)
+	if cached, err := h.cache.Get(r.Context(), key); err == nil {
+		httpx.RespondJSON(w, http.StatusOK, json.RawMessage(cached))
+		return
+	}
+
+	suggestions, err := h.svc.SuggestProducts(r.Context(), query, limit)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	if data, err := json.Marshal(suggestions); err == nil {
+		if err := h.cache.Set(r.Context(), key, data, suggestCacheTTL); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to cache suggestions")
+		}
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, suggestions)
 }
 
 // Create creates a new product
 func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
-		"id":      "new-id",
-		"message": "Product created",
-	})
+	var req domain.CreateProductRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	product, err := h.svc.CreateProduct(r.Context(), &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusCreated, product)
 }
 
 // Update updates an existing product
 func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"id":      id,
-		"message": "Product updated",
-	})
+	var req domain.UpdateProductRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	product, err := h.svc.UpdateProduct(r.Context(), id, &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, product)
 }
 
 // Delete deletes a product
 func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	if err := h.svc.DeleteProduct(r.Context(), id); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
 		"id":      id,
 		"message": "Product deleted",
 	})
 }
 
-// GetPrices returns prices for a product
+// GetPrices returns prices for a product, only those of one variant with
+// ?variantId=. Passing cursor/limit switches to keyset pagination.
 func (h *ProductHandler) GetPrices(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"productId": id,
-		"prices":    []interface{}{},
+	p := newQueryParser(r)
+	variantID := p.uuid("variantId")
+	var params repository.ListParams
+	p.cursor(&params)
+	if err := p.err(); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	if !params.UsesCursor() {
+		offers, err := h.svc.GetOffers(r.Context(), id, variantID)
+		if err != nil {
+			httpx.RespondServiceError(w, r, err)
+			return
+		}
+
+		httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"productId": id,
+			"prices":    offers,
+		})
+		return
+	}
+
+	page, err := h.svc.ListOffers(r.Context(), id, variantID, params)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"productId":  id,
+		"prices":     page.Items,
+		"limit":      page.PerPage,
+		"nextCursor": page.NextCursor,
+		"prevCursor": page.PrevCursor,
 	})
 }
 
-// GetPriceHistory returns price history for a product
+// GetPriceHistory returns price history for a product.
+// Passing from, to or interval (hour, day, week) returns min/avg/max/last
+// prices per time bucket, retailer and variant instead of the raw records,
+// optionally for the variant given by ?variantId=.
+// Passing cursor/limit switches to keyset pagination.
 func (h *ProductHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	retailerID := r.URL.Query().Get("retailerId")
 
-	// TODO: Implement with repository
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	var retailerFilter *string
+	if retailerID != "" {
+		retailerFilter = &retailerID
+	}
+
+	query := r.URL.Query()
+	if query.Has("from") || query.Has("to") || query.Has("interval") {
+		h.aggregatePriceHistory(w, r, id, retailerFilter)
+		return
+	}
+
+	params, err := cursorParams(r)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	if !params.UsesCursor() {
+		history, err := h.svc.GetPriceHistory(r.Context(), id, retailerFilter)
+		if err != nil {
+			httpx.RespondServiceError(w, r, err)
+			return
+		}
+
+		httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"productId":  id,
+			"retailerId": retailerID,
+			"history":    history,
+		})
+		return
+	}
+
+	page, err := h.svc.ListPriceHistory(r.Context(), id, retailerFilter, params)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
 		"productId":  id,
 		"retailerId": retailerID,
-		"history":    []interface{}{},
+		"history":    page.Items,
+		"limit":      page.PerPage,
+		"nextCursor": page.NextCursor,
+		"prevCursor": page.PrevCursor,
 	})
 }
 
-// NewCategoryRouter creates a new category router
-func NewCategoryRouter(db *database.DB) http.Handler {
+// GetDealScore tells whether the current best price of a product is a good
+// deal for the variant offering it, or for the one given by ?variantId=
+func (h *ProductHandler) GetDealScore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !isUUID(id) {
+		httpx.RespondServiceError(w, r, apperr.NewNotFoundError("product"))
+		return
+	}
+
+	p := newQueryParser(r)
+	variantID := p.uuid("variantId")
+	if err := p.err(); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	score, err := h.svc.GetDealScore(r.Context(), id, variantID)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, score)
+}
+
+func (h *ProductHandler) aggregatePriceHistory(w http.ResponseWriter, r *http.Request, id string, retailerID *string) {
+	p := newQueryParser(r)
+	q := domain.PriceHistoryQuery{
+		RetailerID: retailerID,
+		VariantID:  p.uuid("variantId"),
+		From:       p.time("from"),
+		To:         p.time("to"),
+		Interval:   p.oneOf("interval", domain.HistoryIntervalDay, domain.HistoryIntervals...),
+	}
+	if err := p.err(); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	series, err := h.svc.AggregatePriceHistory(r.Context(), id, q)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, series)
+}
+
+// ListVariants returns the variants of a product
+func (h *ProductHandler) ListVariants(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	variants, err := h.svc.ListVariants(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, variants)
+}
+
+// GetVariantMatrix returns the color × storage matrix of a product's
+// variants with the best offer of each
+func (h *ProductHandler) GetVariantMatrix(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	matrix, err := h.svc.GetVariantMatrix(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, matrix)
+}
+
+// GetVariant returns a variant of a product
+func (h *ProductHandler) GetVariant(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	variantID := chi.URLParam(r, "variantId")
+
+	variant, err := h.svc.GetVariant(r.Context(), id, variantID)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, variant)
+}
+
+// CreateVariant creates a variant of a product
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !isUUID(id) {
+		httpx.RespondServiceError(w, r, apperr.NewNotFoundError("product"))
+		return
+	}
+
+	var req domain.CreateVariantRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	req.ProductID = id
+
+	variant, err := h.svc.CreateVariant(r.Context(), &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusCreated, variant)
+}
+
+// UpdateVariant updates a variant of a product
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	variantID := chi.URLParam(r, "variantId")
+
+	var req domain.UpdateVariantRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	variant, err := h.svc.UpdateVariant(r.Context(), id, variantID, &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, variant)
+}
+
+// DeleteVariant deletes a variant of a product. Its offers and price history
+// are deleted with it.
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	variantID := chi.URLParam(r, "variantId")
+
+	if err := h.svc.DeleteVariant(r.Context(), id, variantID); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      variantID,
+		"message": "Variant deleted",
+	})
+}
+
+// NewCategoryRouter creates a new category router. Writes must carry one of
+// adminKeys as a bearer token.
+func NewCategoryRouter(svc CategoryService, adminKeys []string) http.Handler {
 	r := chi.NewRouter()
 
-	h := &CategoryHandler{db: db}
+	h := &CategoryHandler{svc: svc}
 
 	r.Get("/", h.List)
 	r.Get("/tree", h.GetTree)
 	r.Get("/{id}", h.GetByID)
+	r.Get("/{id}/schema", h.GetSchema)
+	r.Get("/{id}/schema/versions", h.ListSchemaVersions)
+	r.Get("/{id}/schema/versions/{version}", h.GetSchemaVersion)
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireAPIKey(adminKeys))
+		r.Post("/", h.Create)
+		r.Put("/{id}", h.Update)
+		r.Delete("/{id}", h.Delete)
+		r.Post("/{id}/move", h.Move)
+		r.Put("/{id}/schema", h.UpdateSchema)
+	})
 
 	return r
 }
 
 // CategoryHandler handles category requests
 type CategoryHandler struct {
-	db *database.DB
+	svc CategoryService
 }
 
 // List returns a paginated list of categories
 func (h *CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"items":      []interface{}{},
-		"page":       1,
-		"perPage":    20,
-		"total":      0,
-		"totalPages": 0,
-	})
+	params, err := listParams(r)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	result, err := h.svc.ListCategories(r.Context(), params)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, result)
 }
 
 // GetByID returns a category by ID
 func (h *CategoryHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"id":   id,
-		"name": "Sample Category",
-	})
+
+	category, err := h.svc.GetCategory(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, category)
 }
 
 // GetTree returns the category tree
 func (h *CategoryHandler) GetTree(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, []interface{}{})
+	tree, err := h.svc.GetCategoryTree(r.Context())
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, tree)
+}
+
+// Create creates a new category
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateCategoryRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	category, err := h.svc.CreateCategory(r.Context(), &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusCreated, category)
+}
+
+// Update updates an existing category
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req domain.UpdateCategoryRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	category, err := h.svc.UpdateCategory(r.Context(), id, &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, category)
+}
+
+// Move re-parents a category and/or changes its position among its siblings
+func (h *CategoryHandler) Move(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req domain.MoveCategoryRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	category, err := h.svc.MoveCategory(r.Context(), id, &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, category)
+}
+
+// Delete deletes a category. Products must be moved elsewhere first, or
+// reassigned in the same call with ?reassignTo=<categoryId>.
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	p := newQueryParser(r)
+	reassignTo := p.uuid("reassignTo")
+	if err := p.err(); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	if err := h.svc.DeleteCategory(r.Context(), id, reassignTo); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"message": "Category deleted",
+	})
+}
+
+// GetSchema returns the current attribute schema version of a category
+func (h *CategoryHandler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	version, err := h.svc.GetAttributeSchema(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, version)
+}
+
+// UpdateSchema stores a new attribute schema version. Products are
+// re-validated in the background; poll the returned version for the report.
+func (h *CategoryHandler) UpdateSchema(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req domain.UpdateAttributeSchemaRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	version, err := h.svc.UpdateAttributeSchema(r.Context(), id, &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusAccepted, version)
+}
+
+// ListSchemaVersions returns the attribute schema history of a category
+func (h *CategoryHandler) ListSchemaVersions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	versions, err := h.svc.ListAttributeSchemaVersions(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, versions)
+}
+
+// GetSchemaVersion returns one attribute schema version with the list of
+// products that do not conform to it
+func (h *CategoryHandler) GetSchemaVersion(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version < 1 {
+		httpx.RespondServiceError(w, r, apperr.NewNotFoundError("schema version"))
+		return
+	}
+
+	v, err := h.svc.GetAttributeSchemaVersion(r.Context(), id, version)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, v)
 }
 
-// NewRetailerRouter creates a new retailer router
-func NewRetailerRouter(db *database.DB) http.Handler {
+// NewRetailerRouter creates a new retailer router. Writes and the audit log,
+// which exposes key fingerprints and affiliate settings, must carry one of
+// adminKeys as a bearer token; the audit log records it as the actor.
+func NewRetailerRouter(svc RetailerService, adminKeys []string) http.Handler {
 	r := chi.NewRouter()
 
-	h := &RetailerHandler{db: db}
+	h := &RetailerHandler{svc: svc}
 
 	r.Get("/", h.List)
 	r.Get("/{id}", h.GetByID)
 
+	r.Group(func(r chi.Router) {
+		r.Use(requireAPIKey(adminKeys))
+		r.Get("/{id}/audit", h.ListAudit)
+		r.Post("/", h.Create)
+		r.Put("/{id}", h.Update)
+		r.Delete("/{id}", h.Delete)
+		r.Put("/{id}/status", h.UpdateStatus)
+	})
+
 	return r
 }
 
 // RetailerHandler handles retailer requests
 type RetailerHandler struct {
-	db *database.DB
+	svc RetailerService
 }
 
 // List returns a paginated list of retailers
 func (h *RetailerHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"items":      []interface{}{},
-		"page":       1,
-		"perPage":    20,
-		"total":      0,
-		"totalPages": 0,
-	})
+	params, err := listParams(r)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	result, err := h.svc.ListRetailers(r.Context(), params)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, result)
 }
 
 // GetByID returns a retailer by ID
 func (h *RetailerHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"id":   id,
-		"name": "Sample Retailer",
-	})
+
+	retailer, err := h.svc.GetRetailer(r.Context(), id)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, retailer)
 }
 
-// Helper functions
+// Create creates a new retailer
+func (h *RetailerHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateRetailerRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
 
-func respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    data,
-	})
+	retailer, err := h.svc.CreateRetailer(r.Context(), &req, auditInfo(r))
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusCreated, retailer)
+}
+
+// Update updates a retailer's details, affiliate and scraping configuration
+func (h *RetailerHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req domain.UpdateRetailerRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	retailer, err := h.svc.UpdateRetailer(r.Context(), id, &req, auditInfo(r))
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, retailer)
+}
+
+// UpdateStatus activates or deactivates a retailer and sets its priority
+func (h *RetailerHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req domain.UpdateRetailerStatusRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	retailer, err := h.svc.UpdateRetailerStatus(r.Context(), id, &req, auditInfo(r))
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, retailer)
 }
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": false,
-		"error": map[string]string{
-			"message": message,
-		},
+// Delete deletes a retailer. Retailers with offers can only be deactivated.
+func (h *RetailerHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.DeleteRetailer(r.Context(), id, auditInfo(r)); err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"message": "Retailer deleted",
 	})
 }
 
-func getIntParam(r *http.Request, key string, defaultVal int) int {
-	val := r.URL.Query().Get(key)
-	if val == "" {
-		return defaultVal
+// ListAudit returns the change history of a retailer, newest first
+func (h *RetailerHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	params, err := listParams(r)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
+		return
 	}
-	i, err := strconv.Atoi(val)
+
+	result, err := h.svc.ListRetailerAudit(r.Context(), id, params)
 	if err != nil {
-		return defaultVal
+		httpx.RespondServiceError(w, r, err)
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, result)
+}
+
+// NewOfferRouter creates the offer ingestion router used by the scrapers.
+// Requests must carry one of apiKeys as a bearer token.
+func NewOfferRouter(svc OfferService, apiKeys []string) http.Handler {
+	r := chi.NewRouter()
+
+	h := &OfferHandler{svc: svc}
+
+	r.Use(requireAPIKey(apiKeys))
+	r.Post("/", h.Ingest)
+
+	return r
+}
+
+// OfferHandler handles offer ingestion requests
+type OfferHandler struct {
+	svc OfferService
+}
+
+// Ingest upserts a batch of scraped offers and reports the outcome of each.
+// Invalid items are reported as failed without rejecting the batch.
+func (h *OfferHandler) Ingest(w http.ResponseWriter, r *http.Request) {
+	var req domain.IngestOffersRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	httpx.RespondJSON(w, http.StatusOK, h.svc.IngestOffers(r.Context(), req.Offers))
+}
+
+// Helper functions
+
+// auditInfo identifies the client making a change by the API key it
+// authenticated with
+func auditInfo(r *http.Request) domain.AuditInfo {
+	return domain.AuditInfo{
+		Actor:     apiKeyID(r.Context()),
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// isUUID reports whether s looks like a canonical UUID
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
 	}
-	return i
+	return true
 }
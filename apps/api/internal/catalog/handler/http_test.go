@@ -0,0 +1,23 @@
+package handler
+
+import "testing"
+
+func TestSuggestCacheKey(t *testing.T) {
+	key := suggestCacheKey("ipho", 8)
+	if key != "suggest:8:ipho" {
+		t.Errorf("key = %q, want suggest:8:ipho", key)
+	}
+	for _, query := range []string{"ipho ", " iPHO", "ipho\t"} {
+		if got := suggestCacheKey(query, 8); got != key {
+			t.Errorf("suggestCacheKey(%q) = %q, want %q", query, got, key)
+		}
+	}
+
+	spaced := suggestCacheKey("galaxy s24", 8)
+	if got := suggestCacheKey("Galaxy   S24", 8); got != spaced {
+		t.Errorf("runs of spaces give %q, want %q", got, spaced)
+	}
+	if got := suggestCacheKey("ipho", 5); got == key {
+		t.Errorf("limits share the key %q", got)
+	}
+}
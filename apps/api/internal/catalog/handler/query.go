@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// queryParser reads typed query string parameters and collects a
+// validation error per malformed parameter
+type queryParser struct {
+	values url.Values
+	errs   validator.Errors
+}
+
+func newQueryParser(r *http.Request) *queryParser {
+	return &queryParser{values: r.URL.Query()}
+}
+
+// fail records a validation error for a query parameter
+func (p *queryParser) fail(field, rule, message string) {
+	p.errs = append(p.errs, validator.FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// err returns the collected errors as a domain validation error, or nil
+func (p *queryParser) err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return apperr.NewValidationError("Invalid query parameters", p.errs)
+}
+
+func (p *queryParser) string(key string) string {
+	return strings.TrimSpace(p.values.Get(key))
+}
+
+// list returns all values of a repeated and/or comma-separated parameter
+func (p *queryParser) list(key string) []string {
+	var out []string
+	for _, raw := range p.values[key] {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+func (p *queryParser) int(key string, defaultVal int) int {
+	val := p.string(key)
+	if val == "" {
+		return defaultVal
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		p.fail(key, "type", "must be an integer")
+		return defaultVal
+	}
+	return i
+}
+
+func (p *queryParser) float(key string) *float64 {
+	val := p.string(key)
+	if val == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		p.fail(key, "type", "must be a number")
+		return nil
+	}
+	return &f
+}
+
+func (p *queryParser) bool(key string) *bool {
+	val := p.string(key)
+	if val == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		p.fail(key, "type", "must be true or false")
+		return nil
+	}
+	return &b
+}
+
+func (p *queryParser) uuid(key string) *string {
+	val := p.string(key)
+	if val == "" {
+		return nil
+	}
+	if !isUUID(val) {
+		p.fail(key, "uuid", "must be a valid UUID")
+		return nil
+	}
+	return &val
+}
+
+// time reads an RFC 3339 timestamp or a YYYY-MM-DD date (midnight UTC);
+// the zero time when absent
+func (p *queryParser) time(key string) time.Time {
+	val := p.string(key)
+	if val == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.DateOnly, val); err == nil {
+		return t
+	}
+	p.fail(key, "datetime", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	return time.Time{}
+}
+
+// oneOf returns the parameter if it is one of allowed, defaultVal if absent
+func (p *queryParser) oneOf(key, defaultVal string, allowed ...string) string {
+	val := p.string(key)
+	if val == "" {
+		return defaultVal
+	}
+	for _, a := range allowed {
+		if val == a {
+			return val
+		}
+	}
+	p.fail(key, "oneof", "must be one of: "+strings.Join(allowed, ", "))
+	return defaultVal
+}
+
+// pagination reads page and perPage; perPage is capped at repository.MaxPerPage
+func (p *queryParser) pagination() (page, perPage int) {
+	page = p.int("page", 1)
+	if page < 1 {
+		p.fail("page", "min", "must be at least 1")
+		page = 1
+	}
+	perPage = p.int("perPage", repository.DefaultPerPage)
+	if perPage < 1 {
+		p.fail("perPage", "min", "must be at least 1")
+		perPage = repository.DefaultPerPage
+	}
+	if perPage > repository.MaxPerPage {
+		perPage = repository.MaxPerPage
+	}
+	return page, perPage
+}
+
+// cursor reads the keyset pagination parameters (cursor, limit) into params
+func (p *queryParser) cursor(params *repository.ListParams) {
+	params.Cursor = p.string("cursor")
+	if p.string("limit") == "" {
+		return
+	}
+	params.Limit = p.int("limit", repository.DefaultPerPage)
+	if params.Limit < 1 {
+		p.fail("limit", "min", "must be at least 1")
+		params.Limit = repository.DefaultPerPage
+	}
+	if params.Limit > repository.MaxPerPage {
+		params.Limit = repository.MaxPerPage
+	}
+}
+
+// cursorParams reads cursor pagination parameters for listings that are
+// otherwise returned in full
+func cursorParams(r *http.Request) (repository.ListParams, error) {
+	p := newQueryParser(r)
+	var params repository.ListParams
+	p.cursor(&params)
+	return params, p.err()
+}
+
+// listParams reads the common pagination query parameters
+func listParams(r *http.Request) (repository.ListParams, error) {
+	p := newQueryParser(r)
+	page, perPage := p.pagination()
+	return repository.ListParams{Page: page, PerPage: perPage}, p.err()
+}
+
+// productListParams reads pagination, sorting and domain.ProductFilter
+// fields from the query string
+func productListParams(r *http.Request) (repository.ListParams, error) {
+	p := newQueryParser(r)
+	page, perPage := p.pagination()
+
+	params := repository.ListParams{
+		Page:      page,
+		PerPage:   perPage,
+		SortBy:    p.oneOf("sortBy", "", repository.SortByPrice, repository.SortByName, repository.SortByCreatedAt, repository.SortByBrand),
+		SortOrder: p.oneOf("sortOrder", repository.SortAsc, repository.SortAsc, repository.SortDesc),
+		Filters:   productFilters(p),
+	}
+	p.cursor(&params)
+	return params, p.err()
+}
+
+// productFilters parses the domain.ProductFilter query parameters into
+// repository filter values
+func productFilters(p *queryParser) map[string]interface{} {
+	var f domain.ProductFilter
+	f.CategoryID = p.uuid("categoryId")
+	f.MinPrice = p.float("minPrice")
+	f.MaxPrice = p.float("maxPrice")
+	f.InStock = p.bool("inStock")
+	f.Active = p.bool("active")
+	brands := p.list("brand")
+
+	if f.MinPrice != nil && *f.MinPrice < 0 {
+		p.fail("minPrice", "min", "must be at least 0")
+	}
+	if f.MaxPrice != nil && *f.MaxPrice < 0 {
+		p.fail("maxPrice", "min", "must be at least 0")
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		p.fail("maxPrice", "gtefield", "must be greater than or equal to minPrice")
+	}
+
+	filters := make(map[string]interface{})
+	if f.CategoryID != nil {
+		filters[repository.FilterCategoryID] = *f.CategoryID
+	}
+	if len(brands) > 0 {
+		filters[repository.FilterBrands] = brands
+	}
+	if f.MinPrice != nil {
+		filters[repository.FilterMinPrice] = *f.MinPrice
+	}
+	if f.MaxPrice != nil {
+		filters[repository.FilterMaxPrice] = *f.MaxPrice
+	}
+	if f.InStock != nil {
+		filters[repository.FilterInStock] = *f.InStock
+	}
+	if f.Active != nil {
+		filters[repository.FilterActive] = *f.Active
+	}
+	if attrs := attributeFilters(p); len(attrs) > 0 {
+		filters[repository.FilterAttributes] = attrs
+	}
+	return filters
+}
+
+// attributeFilters parses attr.<name>[<op>]=<value> parameters, e.g.
+// attr.ram_gb[gte]=8 or attr.5g=true. Values stay raw strings: typing them
+// requires the category attribute_schema, which the service resolves.
+func attributeFilters(p *queryParser) []domain.AttributeFilter {
+	keys := make([]string, 0, len(p.values))
+	for key := range p.values {
+		if strings.HasPrefix(key, "attr.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var filters []domain.AttributeFilter
+	for _, key := range keys {
+		name, op := strings.TrimPrefix(key, "attr."), domain.AttributeOpEq
+		if i := strings.IndexByte(name, '['); i >= 0 && strings.HasSuffix(name, "]") {
+			name, op = name[:i], name[i+1:len(name)-1]
+		}
+		if name == "" {
+			p.fail(key, "attribute", "must name an attribute")
+			continue
+		}
+		if !domain.IsAttributeOperator(op) {
+			p.fail(key, "operator", "operator must be one of: eq, gt, gte, lt, lte")
+			continue
+		}
+		for _, v := range p.values[key] {
+			filters = append(filters, domain.AttributeFilter{Attribute: name, Operator: op, Value: strings.TrimSpace(v)})
+		}
+	}
+	return filters
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+)
+
+// ProductService is the catalog behaviour needed by ProductHandler
+type ProductService interface {
+	GetProduct(ctx context.Context, id string) (*domain.ProductWithOffers, error)
+	GetProductBySlug(ctx context.Context, slug string) (*domain.ProductWithOffers, error)
+	ListProducts(ctx context.Context, params repository.ListParams) (*repository.PaginatedResult[domain.ProductWithOffers], error)
+	SearchProducts(ctx context.Context, query string, params repository.ListParams) (*repository.PaginatedResult[domain.ProductSearchHit], error)
+	SuggestProducts(ctx context.Context, query string, limit int) ([]domain.Suggestion, error)
+	CreateProduct(ctx context.Context, req *domain.CreateProductRequest) (*domain.Product, error)
+	UpdateProduct(ctx context.Context, id string, req *domain.UpdateProductRequest) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, id string) error
+	GetOffers(ctx context.Context, productID string, variantID *string) ([]domain.Offer, error)
+	ListOffers(ctx context.Context, productID string, variantID *string, params repository.ListParams) (*repository.PaginatedResult[domain.Offer], error)
+	GetPriceHistory(ctx context.Context, productID string, retailerID *string) ([]domain.PriceHistory, error)
+	ListPriceHistory(ctx context.Context, productID string, retailerID *string, params repository.ListParams) (*repository.PaginatedResult[domain.PriceHistory], error)
+	AggregatePriceHistory(ctx context.Context, productID string, q domain.PriceHistoryQuery) (*domain.PriceHistorySeries, error)
+	GetDealScore(ctx context.Context, productID string, variantID *string) (*domain.DealScore, error)
+	ListVariants(ctx context.Context, productID string) ([]domain.Variant, error)
+	GetVariant(ctx context.Context, productID, id string) (*domain.Variant, error)
+	CreateVariant(ctx context.Context, req *domain.CreateVariantRequest) (*domain.Variant, error)
+	UpdateVariant(ctx context.Context, productID, id string, req *domain.UpdateVariantRequest) (*domain.Variant, error)
+	DeleteVariant(ctx context.Context, productID, id string) error
+	GetVariantMatrix(ctx context.Context, productID string) (*domain.VariantMatrix, error)
+}
+
+// CategoryService is the catalog behaviour needed by CategoryHandler
+type CategoryService interface {
+	GetCategory(ctx context.Context, id string) (*domain.Category, error)
+	ListCategories(ctx context.Context, params repository.ListParams) (*repository.PaginatedResult[domain.Category], error)
+	GetCategoryTree(ctx context.Context) ([]repository.CategoryNode, error)
+	CreateCategory(ctx context.Context, req *domain.CreateCategoryRequest) (*domain.Category, error)
+	UpdateCategory(ctx context.Context, id string, req *domain.UpdateCategoryRequest) (*domain.Category, error)
+	MoveCategory(ctx context.Context, id string, req *domain.MoveCategoryRequest) (*domain.Category, error)
+	DeleteCategory(ctx context.Context, id string, reassignTo *string) error
+	GetAttributeSchema(ctx context.Context, categoryID string) (*domain.CategorySchemaVersion, error)
+	UpdateAttributeSchema(ctx context.Context, categoryID string, req *domain.UpdateAttributeSchemaRequest) (*domain.CategorySchemaVersion, error)
+	ListAttributeSchemaVersions(ctx context.Context, categoryID string) ([]domain.CategorySchemaVersion, error)
+	GetAttributeSchemaVersion(ctx context.Context, categoryID string, version int) (*domain.CategorySchemaVersion, error)
+}
+
+// RetailerService is the catalog behaviour needed by RetailerHandler
+type RetailerService interface {
+	GetRetailer(ctx context.Context, id string) (*domain.Retailer, error)
+	ListRetailers(ctx context.Context, params repository.ListParams) (*repository.PaginatedResult[domain.Retailer], error)
+	CreateRetailer(ctx context.Context, req *domain.CreateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error)
+	UpdateRetailer(ctx context.Context, id string, req *domain.UpdateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error)
+	UpdateRetailerStatus(ctx context.Context, id string, req *domain.UpdateRetailerStatusRequest, audit domain.AuditInfo) (*domain.Retailer, error)
+	DeleteRetailer(ctx context.Context, id string, audit domain.AuditInfo) error
+	ListRetailerAudit(ctx context.Context, id string, params repository.ListParams) (*repository.PaginatedResult[domain.RetailerAuditEntry], error)
+}
+
+// OfferService is the catalog behaviour needed by OfferHandler
+type OfferService interface {
+	IngestOffers(ctx context.Context, offers []domain.CreateOfferRequest) *domain.OfferIngestReport
+}
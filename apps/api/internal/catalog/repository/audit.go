@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+)
+
+// auditIgnoredFields are maintained by the database and never audited
+var auditIgnoredFields = map[string]bool{"createdAt": true, "updatedAt": true}
+
+// auditChanges returns the JSON fields whose value differs between before and
+// after. Either may be nil, for creations and deletions.
+func auditChanges(before, after any) (map[string]domain.FieldChange, error) {
+	from, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]domain.FieldChange)
+	for _, fields := range []map[string]interface{}{from, to} {
+		for name := range fields {
+			if auditIgnoredFields[name] {
+				continue
+			}
+			if _, seen := changes[name]; seen || reflect.DeepEqual(from[name], to[name]) {
+				continue
+			}
+			changes[name] = domain.FieldChange{From: from[name], To: to[name]}
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields returns v as it is rendered by the API, keyed by field name
+func jsonFields(v any) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	return fields, json.Unmarshal(b, &fields)
+}
+
+// recordRetailerAudit appends a retailer change to the audit log inside the
+// transaction making the change. Updates that change nothing are skipped.
+func recordRetailerAudit(ctx context.Context, db querier, retailerID, action string, before, after *domain.Retailer, audit domain.AuditInfo) error {
+	// Keep nil pointers out of the interfaces so they read as "no record"
+	var b, a any
+	if before != nil {
+		b = before
+	}
+	if after != nil {
+		a = after
+	}
+	changes, err := auditChanges(b, a)
+	if err != nil {
+		return err
+	}
+	if action == domain.AuditActionUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO retailer_audit_log (retailer_id, action, changes, actor, request_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))`,
+		retailerID, action, changes, audit.Actor, audit.RequestID)
+	return err
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+)
+
+// Cursor is the decoded form of an opaque keyset pagination cursor
+type Cursor struct {
+	// Key is the text form of the sort expression of the boundary row
+	Key string `json:"k"`
+	// ID is the unique tie-breaker of the boundary row
+	ID string `json:"i"`
+	// Sort identifies the ordering the cursor was issued for, e.g. "price:asc"
+	Sort string `json:"s"`
+	// Prev is set on cursors that page backwards
+	Prev bool `json:"p,omitempty"`
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses an opaque cursor string
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalidCursorError()
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" || c.Sort == "" {
+		return nil, invalidCursorError()
+	}
+	return &c, nil
+}
+
+func invalidCursorError() error {
+	err := apperr.NewValidationError("Invalid cursor", nil)
+	err.Field = "cursor"
+	return err
+}
+
+// keyset describes a total ordering usable for cursor pagination
+type keyset struct {
+	sort    string // identifies the ordering inside cursors
+	keyExpr string // SQL sort expression, must never be NULL
+	keyType string // SQL type cursor keys are cast back to
+	idExpr  string // unique tie-breaker
+	idType  string // SQL type cursor IDs are cast back to
+	desc    bool
+}
+
+// orderBy returns the ORDER BY clause, reversed when paging backwards
+func (k keyset) orderBy(backward bool) string {
+	dir := "ASC"
+	if k.desc != backward {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s %s", k.keyExpr, dir, k.idExpr, dir)
+}
+
+// selectKeys returns the select-list fragment that yields the cursor key and ID
+func (k keyset) selectKeys() string {
+	return fmt.Sprintf("(%s)::text, (%s)::text", k.keyExpr, k.idExpr)
+}
+
+// seek returns the predicate positioning a query past the cursor.
+// arg registers a query argument and returns its placeholder.
+func (k keyset) seek(c *Cursor, arg func(any) string) (string, error) {
+	if c.Sort != k.sort {
+		err := apperr.NewValidationError("Cursor was issued for a different sort order", nil)
+		err.Field = "cursor"
+		return "", err
+	}
+	op := ">"
+	if k.desc != c.Prev {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s (%s::%s, %s::%s)",
+		k.keyExpr, k.idExpr, op, arg(c.Key), k.keyType, arg(c.ID), k.idType), nil
+}
+
+// keysetRow is a fetched item along with its cursor key and ID
+type keysetRow[T any] struct {
+	item T
+	key  string
+	id   string
+}
+
+// keysetResult builds a cursor page from up to limit+1 fetched rows: the extra
+// row only signals that another page exists. Rows fetched backwards are put
+// back in display order.
+func keysetResult[T any](rows []keysetRow[T], limit int, k keyset, c *Cursor) *PaginatedResult[T] {
+	backward := c != nil && c.Prev
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	items := make([]T, len(rows))
+	for i, row := range rows {
+		items[i] = row.item
+	}
+	result := &PaginatedResult[T]{Items: items, PerPage: limit}
+	if len(rows) == 0 {
+		return result
+	}
+
+	first, last := rows[0], rows[len(rows)-1]
+	// Forward: more rows ahead if we over-fetched; behind us if we came from a cursor.
+	// Backward: the reverse.
+	if (!backward && hasMore) || backward {
+		next := Cursor{Key: last.key, ID: last.id, Sort: k.sort}.Encode()
+		result.NextCursor = &next
+	}
+	if (backward && hasMore) || (!backward && c != nil) {
+		prev := Cursor{Key: first.key, ID: first.id, Sort: k.sort, Prev: true}.Encode()
+		result.PrevCursor = &prev
+	}
+	return result
+}
+
+// normalizeLimit returns a sane cursor page size
+func normalizeLimit(limit int) int {
+	if limit < 1 {
+		return DefaultPerPage
+	}
+	if limit > MaxPerPage {
+		return MaxPerPage
+	}
+	return limit
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+)
+
+// Product listing filter keys for ListParams.Filters, with the expected value type
+const (
+	FilterCategoryID = "categoryId" // string (UUID)
+	FilterBrands     = "brand"      // []string, matched case-insensitively
+	FilterMinPrice   = "minPrice"   // float64, against the best in-stock offer
+	FilterMaxPrice   = "maxPrice"   // float64, against the best in-stock offer
+	FilterInStock    = "inStock"    // bool, whether any offer is in stock
+	FilterActive     = "active"     // bool
+	FilterAttributes = "attributes" // []domain.AttributeFilter, resolved to typed values
+)
+
+// Product listing sort fields for ListParams.SortBy
+const (
+	SortByPrice     = "price"
+	SortByName      = "name"
+	SortByCreatedAt = "createdAt"
+	SortByBrand     = "brand"
+)
+
+// Sort orders for ListParams.SortOrder
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// IsProductSortField reports whether field can be used as a product SortBy
+func IsProductSortField(field string) bool {
+	switch field {
+	case SortByPrice, SortByName, SortByCreatedAt, SortByBrand:
+		return true
+	}
+	return false
+}
+
+// applyProductFilters translates product ListParams.Filters into predicates.
+// Values of an unexpected type are ignored.
+func applyProductFilters(q *listQuery, filters map[string]interface{}) {
+	if v, ok := filters[FilterCategoryID].(string); ok {
+		q.where("p.category_id = " + q.arg(v))
+	}
+	if v, ok := filters[FilterBrands].([]string); ok && len(v) > 0 {
+		brands := make([]string, len(v))
+		for i, b := range v {
+			brands[i] = strings.ToLower(b)
+		}
+		q.where("LOWER(p.brand) = ANY(" + q.arg(brands) + ")")
+	}
+	if v, ok := filters[FilterMinPrice].(float64); ok {
+		q.where("os.best_price >= " + q.arg(v))
+		q.needsOffers = true
+	}
+	if v, ok := filters[FilterMaxPrice].(float64); ok {
+		q.where("os.best_price <= " + q.arg(v))
+		q.needsOffers = true
+	}
+	if v, ok := filters[FilterInStock].(bool); ok {
+		if v {
+			q.where("os.best_price IS NOT NULL")
+		} else {
+			q.where("os.best_price IS NULL")
+		}
+		q.needsOffers = true
+	}
+	if v, ok := filters[FilterActive].(bool); ok {
+		q.where("COALESCE(p.active, true) = " + q.arg(v))
+	}
+	if v, ok := filters[FilterAttributes].([]domain.AttributeFilter); ok {
+		for _, f := range v {
+			applyAttributeFilter(q, f)
+		}
+	}
+}
+
+// attributeRangeOps maps range operators to SQL
+var attributeRangeOps = map[string]string{
+	domain.AttributeOpGt:  ">",
+	domain.AttributeOpGte: ">=",
+	domain.AttributeOpLt:  "<",
+	domain.AttributeOpLte: "<=",
+}
+
+// applyAttributeFilter compiles an attribute filter: equality becomes a JSONB
+// containment test (served by the GIN index), ranges a guarded numeric cast
+func applyAttributeFilter(q *listQuery, f domain.AttributeFilter) {
+	if op, ok := attributeRangeOps[f.Operator]; ok {
+		key := q.arg(f.Attribute)
+		q.where(fmt.Sprintf(
+			"CASE WHEN jsonb_typeof(p.attributes -> %[1]s::text) = 'number' THEN (p.attributes ->> %[1]s::text)::numeric END %[2]s %[3]s",
+			key, op, q.arg(f.Value)))
+		return
+	}
+	q.where("p.attributes @> " + q.arg(map[string]interface{}{f.Attribute: f.Value}) + "::jsonb")
+}
+
+// productKeyset returns the ordering for the requested sort, newest first by
+// default. The same ordering backs both offset and cursor pagination.
+func productKeyset(params ListParams) keyset {
+	field := params.SortBy
+	desc := strings.EqualFold(params.SortOrder, SortDesc)
+	if !IsProductSortField(field) {
+		field, desc = SortByCreatedAt, true
+	}
+
+	// Nullable sort keys fall back to the infinity that sorts last in either
+	// direction, which keeps the key comparable for keyset seeks
+	last := "'infinity'"
+	order := SortAsc
+	if desc {
+		last, order = "'-infinity'", SortDesc
+	}
+
+	k := keyset{sort: field + ":" + order, idExpr: "p.id", idType: "uuid", desc: desc}
+	switch field {
+	case SortByPrice:
+		k.keyExpr, k.keyType = "COALESCE(os.best_price, "+last+"::numeric)", "numeric"
+	case SortByName:
+		k.keyExpr, k.keyType = "p.name", "text"
+	case SortByBrand:
+		k.keyExpr, k.keyType = "p.brand", "text"
+	default:
+		k.keyExpr, k.keyType = "COALESCE(p.created_at, "+last+"::timestamptz)", "timestamptz"
+	}
+	return k
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
 )
@@ -12,17 +13,34 @@ type ProductRepository interface {
 	GetByID(ctx context.Context, id string) (*domain.ProductWithOffers, error)
 	GetBySlug(ctx context.Context, slug string) (*domain.ProductWithOffers, error)
 	List(ctx context.Context, params ListParams) (*PaginatedResult[domain.ProductWithOffers], error)
-	Search(ctx context.Context, query string, params ListParams) (*PaginatedResult[domain.ProductWithOffers], error)
+	Search(ctx context.Context, query string, params ListParams) (*PaginatedResult[domain.ProductSearchHit], error)
+	Suggest(ctx context.Context, query string, limit int) ([]domain.Suggestion, error)
 	Create(ctx context.Context, req *domain.CreateProductRequest) (*domain.Product, error)
 	Update(ctx context.Context, id string, req *domain.UpdateProductRequest) (*domain.Product, error)
 	Delete(ctx context.Context, id string) error
+	ListByCategoryAfter(ctx context.Context, categoryID, afterID string, limit int) ([]domain.Product, error)
+}
+
+// VariantRepository defines the interface for variant data access.
+// Variants are always addressed through their product.
+type VariantRepository interface {
+	GetByID(ctx context.Context, productID, id string) (*domain.Variant, error)
+	ListByProductID(ctx context.Context, productID string) ([]domain.Variant, error)
+	Create(ctx context.Context, req *domain.CreateVariantRequest) (*domain.Variant, error)
+	Update(ctx context.Context, productID, id string, req *domain.UpdateVariantRequest) (*domain.Variant, error)
+	Delete(ctx context.Context, productID, id string) error
 }
 
 // OfferRepository defines the interface for offer/price data access
 type OfferRepository interface {
-	GetByProductID(ctx context.Context, productID string) ([]domain.Offer, error)
+	GetByProductID(ctx context.Context, productID string, variantID *string) ([]domain.Offer, error)
+	ListByProductID(ctx context.Context, productID string, variantID *string, params ListParams) (*PaginatedResult[domain.Offer], error)
 	GetHistory(ctx context.Context, productID string, retailerID *string) ([]domain.PriceHistory, error)
-	Upsert(ctx context.Context, offer *domain.Offer) error
+	ListHistory(ctx context.Context, productID string, retailerID *string, params ListParams) (*PaginatedResult[domain.PriceHistory], error)
+	AggregateHistory(ctx context.Context, productID string, q domain.PriceHistoryQuery) ([]domain.RetailerPriceSeries, error)
+	PriceStats(ctx context.Context, productID string, variantID *string, current *float64, windows []int) ([]domain.PriceWindowStats, error)
+	Upsert(ctx context.Context, offer *domain.Offer) (string, error)
+	MarkStale(ctx context.Context, retailerID string, olderThan time.Duration) (int64, error)
 }
 
 // CategoryRepository defines the interface for category data access
@@ -31,6 +49,18 @@ type CategoryRepository interface {
 	GetBySlug(ctx context.Context, slug string) (*domain.Category, error)
 	List(ctx context.Context, params ListParams) (*PaginatedResult[domain.Category], error)
 	GetTree(ctx context.Context) ([]CategoryNode, error)
+	Create(ctx context.Context, req *domain.CreateCategoryRequest) (*domain.Category, error)
+	Update(ctx context.Context, id string, req *domain.UpdateCategoryRequest) (*domain.Category, error)
+	Move(ctx context.Context, id string, parentID *string, sortOrder *int) (*domain.Category, error)
+	Delete(ctx context.Context, id string, reassignTo *string) error
+
+	// Attribute schema versions
+	UpdateSchema(ctx context.Context, id string, schema domain.AttributeSchema, baseVersion *int) (*domain.CategorySchemaVersion, error)
+	ListSchemaVersions(ctx context.Context, id string) ([]domain.CategorySchemaVersion, error)
+	GetSchemaVersion(ctx context.Context, id string, version int) (*domain.CategorySchemaVersion, error)
+	PendingSchemaVersions(ctx context.Context) ([]domain.CategorySchemaVersion, error)
+	ClaimSchemaVersion(ctx context.Context, id string, version int) (bool, error)
+	SaveSchemaValidation(ctx context.Context, v *domain.CategorySchemaVersion) error
 }
 
 // RetailerRepository defines the interface for retailer data access
@@ -39,6 +69,10 @@ type RetailerRepository interface {
 	GetBySlug(ctx context.Context, slug string) (*domain.Retailer, error)
 	List(ctx context.Context, params ListParams) (*PaginatedResult[domain.Retailer], error)
 	GetActive(ctx context.Context) ([]domain.Retailer, error)
+	Create(ctx context.Context, req *domain.CreateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error)
+	Update(ctx context.Context, id string, req *domain.UpdateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error)
+	Delete(ctx context.Context, id string, audit domain.AuditInfo) error
+	ListAudit(ctx context.Context, id string, params ListParams) (*PaginatedResult[domain.RetailerAuditEntry], error)
 }
 
 // ListParams defines common list parameters
@@ -48,22 +82,38 @@ type ListParams struct {
 	SortBy    string
 	SortOrder string
 	Filters   map[string]interface{}
+
+	// Cursor and Limit select keyset pagination instead of Page/PerPage
+	Cursor string
+	Limit  int
+}
+
+// UsesCursor reports whether keyset (cursor) pagination was requested
+func (p ListParams) UsesCursor() bool {
+	return p.Cursor != "" || p.Limit > 0
 }
 
-// PaginatedResult wraps paginated data
+// PaginatedResult wraps paginated data.
+// In cursor mode Page, Total and TotalPages are not computed (left at 0),
+// PerPage holds the limit and NextCursor/PrevCursor link the adjacent pages.
 type PaginatedResult[T any] struct {
-	Items      []T  `json:"items"`
-	Page       int  `json:"page"`
-	PerPage    int  `json:"perPage"`
-	Total      int  `json:"total"`
-	TotalPages int  `json:"totalPages"`
+	Items      []T     `json:"items"`
+	Page       int     `json:"page"`
+	PerPage    int     `json:"perPage"`
+	Total      int     `json:"total"`
+	TotalPages int     `json:"totalPages"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+	PrevCursor *string `json:"prevCursor,omitempty"`
 }
 
 // Note: PriceHistory type is defined in domain package
 
-// CategoryNode represents a category in a tree structure
+// CategoryNode represents a category in a tree structure.
+// ProductCount includes the active products of all descendants;
+// DirectProductCount only those attached to the category itself.
 type CategoryNode struct {
 	domain.Category
-	Children     []CategoryNode `json:"children"`
-	ProductCount int            `json:"productCount"`
+	Children           []CategoryNode `json:"children"`
+	ProductCount       int            `json:"productCount"`
+	DirectProductCount int            `json:"directProductCount"`
 }
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// Postgres error codes (see https://www.postgresql.org/docs/current/errcodes-appendix.html)
+const (
+	pgUniqueViolation           = "23505"
+	pgForeignKeyViolation       = "23503"
+	pgNotNullViolation          = "23502"
+	pgCheckViolation            = "23514"
+	pgInvalidTextRepresentation = "22P02"
+)
+
+// uniqueConstraintFields maps unique constraints to the API field they protect
+var uniqueConstraintFields = map[string]string{
+	"products_slug_key":        "slug",
+	"products_ean_key":         "ean",
+	"variants_ean_key":         "ean",
+	"idx_variants_product_sku": "sku",
+	"categories_slug_key":      "slug",
+	"retailers_pkey":           "id",
+	"retailers_slug_key":       "slug",
+}
+
+// foreignKeyRef describes the target of a foreign key constraint
+type foreignKeyRef struct {
+	Resource string
+	Field    string
+}
+
+// foreignKeyRefs maps foreign key constraints to the referenced resource and API field
+var foreignKeyRefs = map[string]foreignKeyRef{
+	"products_category_id_fkey":      {Resource: "category", Field: "categoryId"},
+	"categories_parent_id_fkey":      {Resource: "category", Field: "parentId"},
+	"variants_product_id_fkey":       {Resource: "product", Field: "productId"},
+	"offers_product_id_fkey":         {Resource: "product", Field: "productId"},
+	"offers_variant_id_fkey":         {Resource: "variant", Field: "variantId"},
+	"offers_retailer_id_fkey":        {Resource: "retailer", Field: "retailerId"},
+	"price_history_product_id_fkey":  {Resource: "product", Field: "productId"},
+	"price_history_variant_id_fkey":  {Resource: "variant", Field: "variantId"},
+	"price_history_retailer_id_fkey": {Resource: "retailer", Field: "retailerId"},
+}
+
+// Pagination defaults
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// scanner is satisfied by both pgx.Row and pgx.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// normalizePage returns sane page/perPage values and the matching SQL offset
+func normalizePage(params ListParams) (page, perPage, offset int) {
+	page, perPage = params.Page, params.PerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	return page, perPage, (page - 1) * perPage
+}
+
+// listQuery accumulates the WHERE clause and arguments of a listing query
+type listQuery struct {
+	conds []string
+	args  []any
+	// needsOffers is set when a predicate references the offer summary join
+	needsOffers bool
+}
+
+// arg registers a query argument and returns its placeholder
+func (q *listQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where adds a predicate; use arg() to reference values
+func (q *listQuery) where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+// whereClause returns the combined WHERE clause, or an empty string
+func (q *listQuery) whereClause() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conds, " AND ")
+}
+
+// newPaginatedResult builds a PaginatedResult and computes TotalPages
+func newPaginatedResult[T any](items []T, page, perPage, total int) *PaginatedResult[T] {
+	if items == nil {
+		items = []T{}
+	}
+	totalPages := 0
+	if perPage > 0 {
+		totalPages = (total + perPage - 1) / perPage
+	}
+	return &PaginatedResult[T]{
+		Items:      items,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+}
+
+// listing describes a paginated query run by listPage
+type listing[T any] struct {
+	from      string // FROM clause, e.g. "offers o"
+	countFrom string // FROM clause of the total count; defaults to from
+	columns   string
+	resource  string
+	keyset    keyset
+	scan      func(row scanner, item *T, extra ...any) error
+}
+
+// listPage runs a listing with offset pagination or, when requested, keyset
+// pagination along l.keyset
+func listPage[T any](ctx context.Context, db *database.DB, q *listQuery, params ListParams, l listing[T]) (*PaginatedResult[T], error) {
+	k := l.keyset
+	var (
+		cursor *Cursor
+		total  int
+		page   int
+		limit  int
+		offset int
+	)
+
+	if params.UsesCursor() {
+		limit = normalizeLimit(params.Limit)
+		if params.Cursor != "" {
+			c, err := DecodeCursor(params.Cursor)
+			if err != nil {
+				return nil, err
+			}
+			seek, err := k.seek(c, q.arg)
+			if err != nil {
+				return nil, err
+			}
+			q.where(seek)
+			cursor = c
+		}
+	} else {
+		page, limit, offset = normalizePage(params)
+		countFrom := l.countFrom
+		if countFrom == "" {
+			countFrom = l.from
+		}
+		if err := db.Pool.QueryRow(ctx,
+			`SELECT COUNT(*) FROM `+countFrom+` `+q.whereClause(), q.args...,
+		).Scan(&total); err != nil {
+			return nil, translateLookupError(err, l.resource)
+		}
+	}
+
+	// Keyset pages fetch one extra row to detect whether another page exists
+	fetch := limit
+	if params.UsesCursor() {
+		fetch++
+	}
+
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s %s %s LIMIT %s OFFSET %s`,
+		l.columns, k.selectKeys(), l.from, q.whereClause(),
+		k.orderBy(cursor != nil && cursor.Prev), q.arg(fetch), q.arg(offset))
+
+	rows, err := db.Pool.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, translateLookupError(err, l.resource)
+	}
+	defer rows.Close()
+
+	var fetched []keysetRow[T]
+	for rows.Next() {
+		var row keysetRow[T]
+		if err := l.scan(rows, &row.item, &row.key, &row.id); err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateLookupError(err, l.resource)
+	}
+
+	if params.UsesCursor() {
+		return keysetResult(fetched, limit, k, cursor), nil
+	}
+
+	items := make([]T, len(fetched))
+	for i, row := range fetched {
+		items[i] = row.item
+	}
+	return newPaginatedResult(items, page, limit, total), nil
+}
+
+// translateError maps low-level pgx errors for resource to typed apperr errors.
+// It is meant for inserts and updates, where a foreign key violation means the
+// written row points at a missing record; deletes use translateDeleteError.
+func translateError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperr.NewNotFoundError(resource)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var derr *apperr.Error
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		field, ok := uniqueConstraintFields[pgErr.ConstraintName]
+		if !ok {
+			field = pgErr.ConstraintName
+		}
+		derr = apperr.NewConflictError(resource, field)
+	case pgForeignKeyViolation:
+		ref, ok := foreignKeyRefs[pgErr.ConstraintName]
+		if !ok {
+			ref = foreignKeyRef{Resource: pgErr.TableName, Field: pgErr.ConstraintName}
+		}
+		derr = apperr.NewInvalidReferenceError(ref.Resource, ref.Field)
+	case pgNotNullViolation:
+		derr = apperr.NewValidationError(pgErr.ColumnName+" is required", nil)
+		derr.Field = pgErr.ColumnName
+	case pgCheckViolation, pgInvalidTextRepresentation:
+		derr = apperr.NewValidationError("invalid value for "+resource, nil)
+	default:
+		return err
+	}
+
+	derr.Err = err
+	return derr
+}
+
+// translateLookupError is translateError for single-record lookups, where a
+// malformed identifier simply means the record does not exist
+func translateLookupError(err error, resource string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgInvalidTextRepresentation {
+		return apperr.NewNotFoundError(resource)
+	}
+	return translateError(err, resource)
+}
+
+// translateDeleteError is translateLookupError for deletes, where a foreign
+// key violation means other records still reference resource. The operation
+// tells it apart from a dangling reference: the constraint and table alone do
+// not, e.g. for the self-referencing categories_parent_id_fkey.
+func translateDeleteError(err error, resource string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		derr := apperr.NewInUseError(resource)
+		derr.Err = err
+		return derr
+	}
+	return translateLookupError(err, resource)
+}
+
+// isUniqueViolation reports whether err violates the given unique constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == constraint
+}
@@ -0,0 +1,639 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// categoryColumns is the column list used to scan a domain.Category (table alias "c")
+const categoryColumns = `c.id, c.name, c.slug, c.parent_id, c.description, c.image_url,
+	COALESCE(c.attribute_schema, '{}'::jsonb), c.schema_version,
+	COALESCE(c.sort_order, 0), COALESCE(c.active, true),
+	COALESCE(c.created_at, NOW()), COALESCE(c.updated_at, NOW())`
+
+// schemaVersionColumns is the column list used to scan a
+// domain.CategorySchemaVersion without its report (table alias "sv")
+const schemaVersionColumns = `sv.category_id, sv.version, sv.attribute_schema,
+	sv.status, sv.checked_count, sv.nonconforming_count, sv.error,
+	COALESCE(sv.created_at, NOW()), sv.validated_at`
+
+// schemaRevalidationTimeout is how long a running schema validation may go
+// without finishing before another worker takes it over
+const schemaRevalidationTimeout = "10 minutes"
+
+// PostgresCategoryRepository implements CategoryRepository on top of pgx
+type PostgresCategoryRepository struct {
+	db *database.DB
+}
+
+// NewPostgresCategoryRepository creates a new Postgres-backed category repository
+func NewPostgresCategoryRepository(db *database.DB) *PostgresCategoryRepository {
+	return &PostgresCategoryRepository{db: db}
+}
+
+// GetByID retrieves a category by ID
+func (r *PostgresCategoryRepository) GetByID(ctx context.Context, id string) (*domain.Category, error) {
+	return r.get(ctx, "c.id = $1", id)
+}
+
+// GetBySlug retrieves a category by slug
+func (r *PostgresCategoryRepository) GetBySlug(ctx context.Context, slug string) (*domain.Category, error) {
+	return r.get(ctx, "c.slug = $1", slug)
+}
+
+// List retrieves a paginated list of categories in display order
+func (r *PostgresCategoryRepository) List(ctx context.Context, params ListParams) (*PaginatedResult[domain.Category], error) {
+	page, perPage, offset := normalizePage(params)
+
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM categories`).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+categoryColumns+`
+		FROM categories c
+		ORDER BY COALESCE(c.sort_order, 0), c.name
+		LIMIT $1 OFFSET $2`, perPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.Category
+	for rows.Next() {
+		var c domain.Category
+		if err := scanCategory(rows, &c); err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPaginatedResult(items, page, perPage, total), nil
+}
+
+// categoryTreeQuery walks the active categories top-down from the roots (no
+// parent, or an inactive one). Each row carries its path from the root, the
+// active products attached directly and those rolled up from the whole
+// subtree. Categories caught in a parent_id cycle are unreachable from any
+// root and come back with a NULL path.
+const categoryTreeQuery = `
+	WITH RECURSIVE tree AS (
+		SELECT c.id, ARRAY[c.id] AS path
+		FROM categories c
+		WHERE COALESCE(c.active, true)
+		  AND NOT EXISTS (
+			SELECT 1 FROM categories pc
+			WHERE pc.id = c.parent_id AND COALESCE(pc.active, true))
+		UNION ALL
+		SELECT c.id, t.path || c.id
+		FROM tree t
+		JOIN categories c ON c.parent_id = t.id
+		WHERE COALESCE(c.active, true) AND c.id <> ALL(t.path)
+	),
+	direct AS (
+		SELECT p.category_id AS id, COUNT(*) AS n
+		FROM products p
+		WHERE COALESCE(p.active, true)
+		GROUP BY p.category_id
+	),
+	rolled AS (
+		SELECT a.id, SUM(d.n) AS n
+		FROM tree t
+		JOIN direct d ON d.id = t.id
+		CROSS JOIN LATERAL unnest(t.path) AS a(id)
+		GROUP BY a.id
+	)
+	SELECT ` + categoryColumns + `,
+	       t.path::text[], COALESCE(d.n, 0), COALESCE(r.n, 0)::bigint
+	FROM categories c
+	LEFT JOIN tree t ON t.id = c.id
+	LEFT JOIN direct d ON d.id = c.id
+	LEFT JOIN rolled r ON r.id = c.id
+	WHERE COALESCE(c.active, true)
+	ORDER BY COALESCE(c.sort_order, 0), c.name`
+
+// GetTree returns active categories nested under their parents. ProductCount
+// includes the active products of every descendant. Categories whose parent
+// chain loops back on itself are left out of the tree and logged.
+func (r *PostgresCategoryRepository) GetTree(ctx context.Context) ([]CategoryNode, error) {
+	rows, err := r.db.Pool.Query(ctx, categoryTreeQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		nodes    []CategoryNode
+		parents  []string
+		children = make(map[string][]int)
+		roots    []int
+		cyclic   []string
+	)
+	for rows.Next() {
+		var (
+			n    CategoryNode
+			path []string
+		)
+		if err := scanCategory(rows, &n.Category, &path, &n.DirectProductCount, &n.ProductCount); err != nil {
+			return nil, err
+		}
+		if path == nil {
+			cyclic = append(cyclic, n.ID)
+			continue
+		}
+		parent := ""
+		if len(path) > 1 {
+			parent = path[len(path)-2]
+		}
+		nodes = append(nodes, n)
+		parents = append(parents, parent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cyclic) > 0 {
+		log.Warn().Strs("categories", cyclic).Msg("Category parent cycle detected, categories left out of the tree")
+	}
+
+	for i, parent := range parents {
+		if parent == "" {
+			roots = append(roots, i)
+		} else {
+			children[parent] = append(children[parent], i)
+		}
+	}
+
+	var build func(i int) CategoryNode
+	build = func(i int) CategoryNode {
+		n := nodes[i]
+		n.Children = []CategoryNode{}
+		for _, c := range children[n.ID] {
+			n.Children = append(n.Children, build(c))
+		}
+		return n
+	}
+
+	tree := make([]CategoryNode, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree, nil
+}
+
+// categoryTreeLock serializes category writes so sibling ordering and cycle
+// checks are not raced by concurrent moves
+const categoryTreeLock = `SELECT pg_advisory_xact_lock(hashtext('categories'))`
+
+// Create inserts a category, deriving a unique slug from its name when none
+// is given and placing it among its siblings
+func (r *PostgresCategoryRepository) Create(ctx context.Context, req *domain.CreateCategoryRequest) (*domain.Category, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, categoryTreeLock); err != nil {
+		return nil, err
+	}
+
+	var slug string
+	if req.Slug != nil {
+		slug = *req.Slug
+	} else {
+		base := Slugify(req.Name)
+		if base == "" {
+			base = "category"
+		}
+		if slug, err = uniqueSlug(ctx, tx, "categories", base); err != nil {
+			return nil, err
+		}
+	}
+
+	sortOrder, err := placeCategory(ctx, tx, req.ParentID, req.SortOrder, nil)
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+
+	schema := req.AttributeSchema
+	if schema == nil {
+		schema = domain.AttributeSchema{}
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	var c domain.Category
+	err = scanCategory(tx.QueryRow(ctx, `
+		INSERT INTO categories AS c (
+			name, slug, parent_id, description, image_url,
+			attribute_schema, sort_order, active
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+categoryColumns,
+		req.Name, slug, req.ParentID, req.Description, req.ImageURL,
+		schema, sortOrder, active,
+	), &c)
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+
+	// A new category has no products to re-validate
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO category_schema_versions (
+			category_id, version, attribute_schema, status, validated_at
+		) VALUES ($1, $2, $3, $4, NOW())`,
+		c.ID, c.SchemaVersion, c.AttributeSchema, domain.SchemaValidationCompleted); err != nil {
+		return nil, translateError(err, "category")
+	}
+	return &c, tx.Commit(ctx)
+}
+
+// Update applies the non-nil fields of req to the category. A new sort_order
+// shifts the following siblings down.
+func (r *PostgresCategoryRepository) Update(ctx context.Context, id string, req *domain.UpdateCategoryRequest) (*domain.Category, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, categoryTreeLock); err != nil {
+		return nil, err
+	}
+
+	var parentID *string
+	err = tx.QueryRow(ctx, `SELECT parent_id FROM categories WHERE id = $1`, id).Scan(&parentID)
+	if err != nil {
+		return nil, translateLookupError(err, "category")
+	}
+
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Slug != nil {
+		set("slug", *req.Slug)
+	}
+	if req.Description != nil {
+		set("description", *req.Description)
+	}
+	if req.ImageURL != nil {
+		set("image_url", *req.ImageURL)
+	}
+	if req.SortOrder != nil {
+		sortOrder, err := placeCategory(ctx, tx, parentID, req.SortOrder, &id)
+		if err != nil {
+			return nil, translateError(err, "category")
+		}
+		set("sort_order", sortOrder)
+	}
+	if req.Active != nil {
+		set("active", *req.Active)
+	}
+
+	var c domain.Category
+	if len(sets) == 0 {
+		err = scanCategory(tx.QueryRow(ctx,
+			`SELECT `+categoryColumns+` FROM categories c WHERE c.id = $1`, id), &c)
+	} else {
+		args = append(args, id)
+		err = scanCategory(tx.QueryRow(ctx, fmt.Sprintf(
+			`UPDATE categories AS c SET %s WHERE c.id = $%d RETURNING %s`,
+			strings.Join(sets, ", "), len(args), categoryColumns), args...), &c)
+	}
+	if err != nil {
+		return nil, translateLookupError(err, "category")
+	}
+	return &c, tx.Commit(ctx)
+}
+
+// Move re-parents a category (nil parentID makes it a root). Moving a
+// category under itself or one of its descendants is rejected.
+func (r *PostgresCategoryRepository) Move(ctx context.Context, id string, parentID *string, sortOrder *int) (*domain.Category, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, categoryTreeLock); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, translateLookupError(err, "category")
+	}
+	if !exists {
+		return nil, apperr.NewNotFoundError("category")
+	}
+
+	if parentID != nil {
+		// Walk up from the new parent: finding the moved category means a cycle
+		var cycle bool
+		err := tx.QueryRow(ctx, `
+			WITH RECURSIVE ancestors AS (
+				SELECT c.id, c.parent_id, ARRAY[c.id] AS path
+				FROM categories c
+				WHERE c.id = $1
+				UNION ALL
+				SELECT c.id, c.parent_id, a.path || c.id
+				FROM ancestors a
+				JOIN categories c ON c.id = a.parent_id
+				WHERE c.id <> ALL(a.path)
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`, *parentID, id).Scan(&cycle)
+		if err != nil {
+			return nil, translateError(err, "category")
+		}
+		if cycle {
+			verr := apperr.NewValidationError("A category cannot be moved under itself or one of its descendants", nil)
+			verr.Field = "parentId"
+			return nil, verr
+		}
+	}
+
+	position, err := placeCategory(ctx, tx, parentID, sortOrder, &id)
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+
+	var c domain.Category
+	err = scanCategory(tx.QueryRow(ctx, `
+		UPDATE categories AS c SET parent_id = $1, sort_order = $2
+		WHERE c.id = $3
+		RETURNING `+categoryColumns, parentID, position, id), &c)
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+	return &c, tx.Commit(ctx)
+}
+
+// Delete removes a category. Its products are first moved to reassignTo when
+// given; otherwise a category that still has products cannot be deleted.
+// Child categories are re-attached to the deleted category's parent.
+func (r *PostgresCategoryRepository) Delete(ctx context.Context, id string, reassignTo *string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, categoryTreeLock); err != nil {
+		return err
+	}
+
+	var parentID *string
+	err = tx.QueryRow(ctx, `SELECT parent_id FROM categories WHERE id = $1`, id).Scan(&parentID)
+	if err != nil {
+		return translateLookupError(err, "category")
+	}
+
+	if reassignTo != nil {
+		if *reassignTo == id {
+			verr := apperr.NewValidationError("Products cannot be reassigned to the category being deleted", nil)
+			verr.Field = "reassignTo"
+			return verr
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, *reassignTo).Scan(&exists); err != nil {
+			return translateError(err, "category")
+		}
+		if !exists {
+			return apperr.NewInvalidReferenceError("category", "reassignTo")
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE products SET category_id = $1 WHERE category_id = $2`, *reassignTo, id); err != nil {
+			return translateError(err, "product")
+		}
+	} else {
+		var products int
+		if err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM products WHERE category_id = $1`, id).Scan(&products); err != nil {
+			return err
+		}
+		if products > 0 {
+			derr := apperr.NewInUseError("category")
+			derr.Message = fmt.Sprintf("category still has %d products; reassign them with reassignTo", products)
+			return derr
+		}
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE categories SET parent_id = $1 WHERE parent_id = $2`, parentID, id); err != nil {
+		return translateError(err, "category")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM categories WHERE id = $1`, id); err != nil {
+		return translateDeleteError(err, "category")
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateSchema stores schema as the next version of the category attribute
+// schema. When baseVersion is set it must still be the current version.
+// The new version awaits re-validation of the category's products.
+func (r *PostgresCategoryRepository) UpdateSchema(ctx context.Context, id string, schema domain.AttributeSchema, baseVersion *int) (*domain.CategorySchemaVersion, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	err = tx.QueryRow(ctx,
+		`SELECT schema_version FROM categories WHERE id = $1 FOR UPDATE`, id).Scan(&current)
+	if err != nil {
+		return nil, translateLookupError(err, "category")
+	}
+	if baseVersion != nil && *baseVersion != current {
+		derr := apperr.NewConflictError("category", "baseVersion")
+		derr.Message = fmt.Sprintf("attribute schema is at version %d, not %d", current, *baseVersion)
+		return nil, derr
+	}
+
+	var v domain.CategorySchemaVersion
+	err = scanSchemaVersion(tx.QueryRow(ctx, `
+		INSERT INTO category_schema_versions AS sv (category_id, version, attribute_schema)
+		VALUES ($1, $2, $3)
+		RETURNING `+schemaVersionColumns, id, current+1, schema), &v)
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE categories SET attribute_schema = $1, schema_version = $2 WHERE id = $3`,
+		schema, v.Version, id); err != nil {
+		return nil, translateError(err, "category")
+	}
+	return &v, tx.Commit(ctx)
+}
+
+// ListSchemaVersions returns the schema history of a category, newest first,
+// without the per-product reports
+func (r *PostgresCategoryRepository) ListSchemaVersions(ctx context.Context, id string) ([]domain.CategorySchemaVersion, error) {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+schemaVersionColumns+`
+		FROM category_schema_versions sv
+		WHERE sv.category_id = $1
+		ORDER BY sv.version DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []domain.CategorySchemaVersion{}
+	for rows.Next() {
+		var v domain.CategorySchemaVersion
+		if err := scanSchemaVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetSchemaVersion returns one schema version with its validation report
+func (r *PostgresCategoryRepository) GetSchemaVersion(ctx context.Context, id string, version int) (*domain.CategorySchemaVersion, error) {
+	var v domain.CategorySchemaVersion
+	err := scanSchemaVersion(r.db.Pool.QueryRow(ctx, `
+		SELECT `+schemaVersionColumns+`, sv.nonconforming
+		FROM category_schema_versions sv
+		WHERE sv.category_id = $1 AND sv.version = $2`, id, version), &v, &v.NonConforming)
+	if err != nil {
+		return nil, translateLookupError(err, "schema version")
+	}
+	return &v, nil
+}
+
+// PendingSchemaVersions returns the versions awaiting re-validation, oldest
+// first, including runs abandoned for longer than schemaRevalidationTimeout
+func (r *PostgresCategoryRepository) PendingSchemaVersions(ctx context.Context) ([]domain.CategorySchemaVersion, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+schemaVersionColumns+`
+		FROM category_schema_versions sv
+		WHERE sv.status = 'pending'
+		   OR (sv.status = 'running' AND sv.started_at < NOW() - $1::interval)
+		ORDER BY sv.created_at, sv.version`, schemaRevalidationTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []domain.CategorySchemaVersion
+	for rows.Next() {
+		var v domain.CategorySchemaVersion
+		if err := scanSchemaVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// ClaimSchemaVersion marks a pending (or abandoned) version as running and
+// reports whether this caller won it
+func (r *PostgresCategoryRepository) ClaimSchemaVersion(ctx context.Context, id string, version int) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE category_schema_versions
+		SET status = 'running', started_at = NOW(), error = NULL
+		WHERE category_id = $1 AND version = $2
+		  AND (status = 'pending'
+		       OR (status = 'running' AND started_at < NOW() - $3::interval))`,
+		id, version, schemaRevalidationTimeout)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// SaveSchemaValidation stores the outcome of a re-validation run
+func (r *PostgresCategoryRepository) SaveSchemaValidation(ctx context.Context, v *domain.CategorySchemaVersion) error {
+	report := v.NonConforming
+	if report == nil {
+		report = []domain.NonConformingProduct{}
+	}
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE category_schema_versions
+		SET status = $3, checked_count = $4, nonconforming_count = $5,
+		    nonconforming = $6, error = $7, validated_at = NOW()
+		WHERE category_id = $1 AND version = $2`,
+		v.CategoryID, v.Version, v.Status, v.CheckedCount, v.NonConformingCount, report, v.Error)
+	return err
+}
+
+// placeCategory returns the sort_order for a category among the children of
+// parentID (roots when nil), ignoring the category self. Without an explicit
+// position it goes last; otherwise siblings at or after it shift down one.
+func placeCategory(ctx context.Context, db querier, parentID *string, sortOrder *int, self *string) (int, error) {
+	if sortOrder == nil {
+		var next int
+		err := db.QueryRow(ctx, `
+			SELECT COALESCE(MAX(sort_order) + 1, 0) FROM categories
+			WHERE parent_id IS NOT DISTINCT FROM $1::uuid
+			  AND ($2::uuid IS NULL OR id <> $2::uuid)`, parentID, self).Scan(&next)
+		return next, err
+	}
+
+	_, err := db.Exec(ctx, `
+		UPDATE categories SET sort_order = sort_order + 1
+		WHERE parent_id IS NOT DISTINCT FROM $1::uuid
+		  AND ($2::uuid IS NULL OR id <> $2::uuid)
+		  AND sort_order >= $3`, parentID, self, *sortOrder)
+	return *sortOrder, err
+}
+
+func (r *PostgresCategoryRepository) get(ctx context.Context, where string, arg any) (*domain.Category, error) {
+	var c domain.Category
+	err := scanCategory(r.db.Pool.QueryRow(ctx,
+		`SELECT `+categoryColumns+` FROM categories c WHERE `+where, arg,
+	), &c)
+	if err != nil {
+		return nil, translateLookupError(err, "category")
+	}
+	return &c, nil
+}
+
+// scanCategory scans categoryColumns followed by any extra destinations
+func scanCategory(row scanner, c *domain.Category, extra ...any) error {
+	return row.Scan(append([]any{
+		&c.ID, &c.Name, &c.Slug, &c.ParentID, &c.Description, &c.ImageURL,
+		&c.AttributeSchema, &c.SchemaVersion, &c.SortOrder, &c.Active,
+		&c.CreatedAt, &c.UpdatedAt,
+	}, extra...)...)
+}
+
+// scanSchemaVersion scans schemaVersionColumns followed by any extra destinations
+func scanSchemaVersion(row scanner, v *domain.CategorySchemaVersion, extra ...any) error {
+	return row.Scan(append([]any{
+		&v.CategoryID, &v.Version, &v.AttributeSchema,
+		&v.Status, &v.CheckedCount, &v.NonConformingCount, &v.Error,
+		&v.CreatedAt, &v.ValidatedAt,
+	}, extra...)...)
+}
+
+// Compile-time interface check
+var _ CategoryRepository = (*PostgresCategoryRepository)(nil)
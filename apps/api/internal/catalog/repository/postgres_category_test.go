@@ -0,0 +1,452 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+func createCategory(t *testing.T, db *database.DB, name string, parentID *string) *domain.Category {
+	t.Helper()
+	c, err := NewPostgresCategoryRepository(db).Create(context.Background(), &domain.CreateCategoryRequest{
+		Name:     name,
+		ParentID: parentID,
+	})
+	if err != nil {
+		t.Fatalf("create category %s: %v", name, err)
+	}
+	return c
+}
+
+func TestPostgresCategoryRepository_List(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	createCategory(t, db, "Laptops", nil)
+	createCategory(t, db, "Audio", nil)
+
+	first, err := repo.List(ctx, ListParams{Page: 1, PerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.List(ctx, ListParams{Page: 2, PerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total != 3 || first.TotalPages != 2 {
+		t.Errorf("total = %d, totalPages = %d, want 3 and 2", first.Total, first.TotalPages)
+	}
+
+	var names []string
+	for _, c := range append(first.Items, second.Items...) {
+		names = append(names, c.Name)
+	}
+	// New roots go after the seeded Smartphones category
+	if want := []string{"Smartphones", "Laptops", "Audio"}; !slices.Equal(names, want) {
+		t.Errorf("categories = %v, want %v", names, want)
+	}
+}
+
+func TestPostgresCategoryRepository_GetTree(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	top := createCategory(t, db, "Top", nil)
+	mid := createCategory(t, db, "Mid", &top.ID)
+	hidden := createCategory(t, db, "Hidden", &top.ID)
+	loopA := createCategory(t, db, "Loop A", nil)
+	loopB := createCategory(t, db, "Loop B", &loopA.ID)
+	dbtest.Exec(t, db, `UPDATE categories SET active = false WHERE id = $1`, hidden.ID)
+	dbtest.Exec(t, db, `UPDATE categories SET parent_id = $1 WHERE id = $2`, loopB.ID, loopA.ID)
+
+	for _, name := range []string{"One", "Two"} {
+		p := createProduct(t, db, "Acme", name)
+		dbtest.Exec(t, db, `UPDATE products SET category_id = $1 WHERE id = $2`, mid.ID, p.ID)
+	}
+	createProduct(t, db, "Acme", "Three")
+
+	tree, err := repo.GetTree(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roots []string
+	for _, n := range tree {
+		roots = append(roots, n.Name)
+	}
+	if !slices.Equal(roots, []string{"Smartphones", "Top"}) {
+		t.Fatalf("roots = %v, want [Smartphones Top] without the cycle", roots)
+	}
+	if n := tree[0]; n.ProductCount != 1 || n.DirectProductCount != 1 {
+		t.Errorf("Smartphones counts = %d/%d, want 1/1", n.ProductCount, n.DirectProductCount)
+	}
+	topNode := tree[1]
+	if topNode.ProductCount != 2 || topNode.DirectProductCount != 0 {
+		t.Errorf("Top counts = %d/%d, want 2/0", topNode.ProductCount, topNode.DirectProductCount)
+	}
+	if len(topNode.Children) != 1 || topNode.Children[0].ID != mid.ID {
+		t.Fatalf("Top children = %+v, want only Mid", topNode.Children)
+	}
+	if n := topNode.Children[0]; n.ProductCount != 2 || n.DirectProductCount != 2 || len(n.Children) != 0 {
+		t.Errorf("Mid = %+v, want 2/2 and no children", n)
+	}
+}
+
+func TestPostgresCategoryRepository_Create(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	audio := createCategory(t, db, "Téléphones & Audio", nil)
+	again := createCategory(t, db, "Téléphones & Audio", nil)
+	if audio.Slug != "telephones-audio" || again.Slug != "telephones-audio-2" {
+		t.Errorf("slugs = %q, %q, want telephones-audio, telephones-audio-2", audio.Slug, again.Slug)
+	}
+	if !audio.Active || audio.SchemaVersion != 1 {
+		t.Errorf("created = %+v, want active at schema version 1", audio)
+	}
+
+	versions, err := repo.ListSchemaVersions(ctx, audio.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Status != domain.SchemaValidationCompleted {
+		t.Errorf("versions = %+v, want version 1 already validated", versions)
+	}
+
+	for _, get := range []func() (*domain.Category, error){
+		func() (*domain.Category, error) { return repo.GetByID(ctx, audio.ID) },
+		func() (*domain.Category, error) { return repo.GetBySlug(ctx, audio.Slug) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != audio.ID || got.Name != "Téléphones & Audio" {
+			t.Errorf("got %+v, want %+v", got, audio)
+		}
+	}
+
+	_, err = repo.Create(ctx, &domain.CreateCategoryRequest{Name: "Duplicate", Slug: ptr(audio.Slug)})
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "slug")
+
+	_, err = repo.Create(ctx, &domain.CreateCategoryRequest{Name: "Orphan", ParentID: ptr(missingID)})
+	assertError(t, err, apperr.ErrInvalidReference, apperr.CodeInvalidReference, "parentId")
+
+	for _, id := range []string{missingID, "not-a-uuid"} {
+		_, err = repo.GetByID(ctx, id)
+		assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	}
+}
+
+func TestPostgresCategoryRepository_SortOrder(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	parent := createCategory(t, db, "Parent", nil)
+	a := createCategory(t, db, "A", &parent.ID)
+	b := createCategory(t, db, "B", &parent.ID)
+	if a.SortOrder != 0 || b.SortOrder != 1 {
+		t.Errorf("sort orders = %d, %d, want 0 and 1", a.SortOrder, b.SortOrder)
+	}
+
+	if _, err := repo.Create(ctx, &domain.CreateCategoryRequest{Name: "C", ParentID: &parent.ID, SortOrder: ptr(0)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := childOrder(t, repo, parent.ID); !slices.Equal(got, []string{"C", "A", "B"}) {
+		t.Errorf("children = %v, want [C A B]", got)
+	}
+
+	if _, err := repo.Update(ctx, b.ID, &domain.UpdateCategoryRequest{SortOrder: ptr(0)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := childOrder(t, repo, parent.ID); !slices.Equal(got, []string{"B", "C", "A"}) {
+		t.Errorf("children = %v, want [B C A]", got)
+	}
+
+	// Roots are siblings of their own
+	root, err := repo.GetByID(ctx, dbtest.SmartphonesCategoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.SortOrder != 0 || parent.SortOrder != 1 {
+		t.Errorf("root sort orders = %d, %d, want 0 and 1", root.SortOrder, parent.SortOrder)
+	}
+}
+
+func TestPostgresCategoryRepository_Update(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	c := createCategory(t, db, "Tablets", nil)
+
+	updated, err := repo.Update(ctx, c.ID, &domain.UpdateCategoryRequest{
+		Name:        ptr("Tablets & e-readers"),
+		Description: ptr("Large screens"),
+		Active:      ptr(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Tablets & e-readers" || updated.Active || *updated.Description != "Large screens" {
+		t.Errorf("updated = %+v", updated)
+	}
+	if updated.Slug != c.Slug {
+		t.Errorf("slug = %q, want %q", updated.Slug, c.Slug)
+	}
+
+	unchanged, err := repo.Update(ctx, c.ID, &domain.UpdateCategoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.Name != updated.Name {
+		t.Errorf("empty update returned %+v", unchanged)
+	}
+
+	_, err = repo.Update(ctx, c.ID, &domain.UpdateCategoryRequest{Slug: ptr("smartphones")})
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "slug")
+
+	_, err = repo.Update(ctx, missingID, &domain.UpdateCategoryRequest{Name: ptr("x")})
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresCategoryRepository_Move(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	top := createCategory(t, db, "Top", nil)
+	mid := createCategory(t, db, "Mid", &top.ID)
+	leaf := createCategory(t, db, "Leaf", &mid.ID)
+
+	for _, target := range []string{top.ID, leaf.ID} {
+		_, err := repo.Move(ctx, top.ID, &target, nil)
+		assertError(t, err, apperr.ErrValidation, apperr.CodeValidationFailed, "parentId")
+	}
+
+	moved, err := repo.Move(ctx, leaf.ID, &top.ID, ptr(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ParentID == nil || *moved.ParentID != top.ID || moved.SortOrder != 0 {
+		t.Errorf("moved = %+v, want first child of Top", moved)
+	}
+	if got := childOrder(t, repo, top.ID); !slices.Equal(got, []string{"Leaf", "Mid"}) {
+		t.Errorf("children of Top = %v, want [Leaf Mid]", got)
+	}
+
+	root, err := repo.Move(ctx, mid.ID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.ParentID != nil {
+		t.Errorf("parentId = %v, want a root", *root.ParentID)
+	}
+
+	_, err = repo.Move(ctx, missingID, nil, nil)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	_, err = repo.Move(ctx, mid.ID, ptr(missingID), nil)
+	assertError(t, err, apperr.ErrInvalidReference, apperr.CodeInvalidReference, "parentId")
+}
+
+func TestPostgresCategoryRepository_Delete(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	top := createCategory(t, db, "Top", nil)
+	doomed := createCategory(t, db, "Doomed", &top.ID)
+	child := createCategory(t, db, "Child", &doomed.ID)
+	p := createProduct(t, db, "Acme", "Phone")
+	dbtest.Exec(t, db, `UPDATE products SET category_id = $1 WHERE id = $2`, doomed.ID, p.ID)
+
+	err := repo.Delete(ctx, doomed.ID, nil)
+	assertError(t, err, apperr.ErrConflict, apperr.CodeInUse, "")
+
+	err = repo.Delete(ctx, doomed.ID, &doomed.ID)
+	assertError(t, err, apperr.ErrValidation, apperr.CodeValidationFailed, "reassignTo")
+
+	err = repo.Delete(ctx, doomed.ID, ptr(missingID))
+	assertError(t, err, apperr.ErrInvalidReference, apperr.CodeInvalidReference, "reassignTo")
+
+	if err := repo.Delete(ctx, doomed.ID, &top.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetByID(ctx, doomed.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	moved, err := NewPostgresProductRepository(db).GetByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.CategoryID != top.ID {
+		t.Errorf("product category = %s, want %s", moved.CategoryID, top.ID)
+	}
+	reattached, err := repo.GetByID(ctx, child.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reattached.ParentID == nil || *reattached.ParentID != top.ID {
+		t.Errorf("child parentId = %v, want %s", reattached.ParentID, top.ID)
+	}
+
+	err = repo.Delete(ctx, doomed.ID, nil)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresCategoryRepository_DeleteRacingChild(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+
+	parent := createCategory(t, db, "Parent", nil)
+	// A child created after the children were re-attached, right before
+	// the delete, trips the self-referencing categories_parent_id_fkey
+	dbtest.Exec(t, db, `
+		CREATE FUNCTION add_child() RETURNS trigger AS $$
+		BEGIN
+			INSERT INTO categories (name, slug, parent_id) VALUES ('Late child', 'late-child', OLD.id);
+			RETURN OLD;
+		END $$ LANGUAGE plpgsql`)
+	dbtest.Exec(t, db, `CREATE TRIGGER trg_add_child BEFORE DELETE ON categories
+		FOR EACH ROW EXECUTE FUNCTION add_child()`)
+
+	err := repo.Delete(context.Background(), parent.ID, nil)
+	assertError(t, err, apperr.ErrConflict, apperr.CodeInUse, "")
+}
+
+func TestPostgresCategoryRepository_SchemaVersions(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresCategoryRepository(db)
+	ctx := context.Background()
+
+	c := createCategory(t, db, "Watches", nil)
+	schema := domain.AttributeSchema{"battery_mah": {Type: "number"}}
+
+	v, err := repo.UpdateSchema(ctx, c.ID, schema, ptr(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 2 || v.Status != domain.SchemaValidationPending || v.AttributeSchema["battery_mah"].Type != "number" {
+		t.Errorf("version = %+v, want pending version 2", v)
+	}
+	got, err := repo.GetByID(ctx, c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaVersion != 2 || got.AttributeSchema["battery_mah"].Type != "number" {
+		t.Errorf("category = %+v, want schema version 2", got)
+	}
+
+	_, err = repo.UpdateSchema(ctx, c.ID, schema, ptr(1))
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "baseVersion")
+
+	_, err = repo.UpdateSchema(ctx, missingID, schema, nil)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	versions, err := repo.ListSchemaVersions(ctx, c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Errorf("versions = %+v, want 2 then 1", versions)
+	}
+
+	_, err = repo.ListSchemaVersions(ctx, missingID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	_, err = repo.GetSchemaVersion(ctx, c.ID, 3)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	t.Run("revalidation", func(t *testing.T) {
+		pending, err := repo.PendingSchemaVersions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, p := range pending {
+			found = found || (p.CategoryID == c.ID && p.Version == 2)
+		}
+		if !found {
+			t.Fatalf("pending = %+v, want version 2 of %s", pending, c.ID)
+		}
+
+		won, err := repo.ClaimSchemaVersion(ctx, c.ID, 2)
+		if err != nil || !won {
+			t.Fatalf("first claim = %v, %v, want won", won, err)
+		}
+		won, err = repo.ClaimSchemaVersion(ctx, c.ID, 2)
+		if err != nil || won {
+			t.Fatalf("second claim = %v, %v, want lost", won, err)
+		}
+
+		v.Status = domain.SchemaValidationCompleted
+		v.CheckedCount, v.NonConformingCount = 3, 1
+		v.NonConforming = []domain.NonConformingProduct{{
+			ProductID: missingID,
+			Name:      "Watch",
+			Violations: []domain.AttributeViolation{
+				{Attribute: "battery_mah", Rule: "type", Message: "must be a number"},
+			},
+		}}
+		if err := repo.SaveSchemaValidation(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := repo.GetSchemaVersion(ctx, c.ID, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Status != domain.SchemaValidationCompleted || saved.CheckedCount != 3 ||
+			saved.NonConformingCount != 1 || saved.ValidatedAt == nil {
+			t.Errorf("saved = %+v", saved)
+		}
+		if len(saved.NonConforming) != 1 || saved.NonConforming[0].Violations[0].Rule != "type" {
+			t.Errorf("report = %+v", saved.NonConforming)
+		}
+
+		pending, err = repo.PendingSchemaVersions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range pending {
+			if p.CategoryID == c.ID {
+				t.Errorf("version %d still pending", p.Version)
+			}
+		}
+	})
+}
+
+// childOrder returns the names of the children of parentID in display order
+func childOrder(t *testing.T, repo *PostgresCategoryRepository, parentID string) []string {
+	t.Helper()
+	tree, err := repo.GetTree(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var find func(nodes []CategoryNode) []string
+	find = func(nodes []CategoryNode) []string {
+		for _, n := range nodes {
+			if n.ID == parentID {
+				names := []string{}
+				for _, c := range n.Children {
+					names = append(names, c.Name)
+				}
+				return names
+			}
+			if names := find(n.Children); names != nil {
+				return names
+			}
+		}
+		return nil
+	}
+	return find(tree)
+}
@@ -0,0 +1,501 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// offerColumns is the column list used to scan a domain.Offer (table alias "o")
+const offerColumns = `o.id, o.product_id, o.variant_id, o.retailer_id, o.price,
+	COALESCE(o.shipping, 0), COALESCE(o.currency, 'EUR'), o.was_price, o.discount_percent,
+	o.url, o.affiliate_url, COALESCE(o.in_stock, true), o.stock_quantity, o.delivery_days,
+	o.seller_name, COALESCE(o.is_marketplace, false), COALESCE(o.scraped_at, NOW()),
+	COALESCE(o.created_at, NOW()), COALESCE(o.updated_at, NOW()),
+	o.stale_at, CASE WHEN o.stale_at IS NULL THEN 'fresh' ELSE 'stale' END`
+
+// historyColumns is the column list used to scan a domain.PriceHistory (table alias "ph")
+const historyColumns = `ph.time, ph.product_id, ph.variant_id, ph.retailer_id, ph.price, COALESCE(ph.in_stock, true)`
+
+// offerKeyset orders offers by total price (price + shipping), cheapest first
+var offerKeyset = keyset{
+	sort:    "price:asc",
+	keyExpr: "o.price + COALESCE(o.shipping, 0)",
+	keyType: "numeric",
+	idExpr:  "o.id",
+	idType:  "uuid",
+}
+
+// historyKeyset orders price history newest first. Rows are unique on
+// (time, product, variant, retailer), and history is listed per product, so
+// ties break on the retailer and variant: sibling variants marked stale
+// together share both the time and the retailer.
+var historyKeyset = keyset{
+	sort:    "time:desc",
+	keyExpr: "ph.time",
+	keyType: "timestamptz",
+	idExpr:  "ph.retailer_id || '/' || COALESCE(ph.variant_id::text, '')",
+	idType:  "text",
+	desc:    true,
+}
+
+// PostgresOfferRepository implements OfferRepository on top of pgx
+type PostgresOfferRepository struct {
+	db *database.DB
+
+	// timescale records whether price_history can be bucketed with
+	// TimescaleDB's time_bucket; it is detected on first use
+	timescaleOnce sync.Once
+	timescale     bool
+}
+
+// NewPostgresOfferRepository creates a new Postgres-backed offer repository
+func NewPostgresOfferRepository(db *database.DB) *PostgresOfferRepository {
+	return &PostgresOfferRepository{db: db}
+}
+
+// GetByProductID returns all offers for a product, cheapest first,
+// optionally restricted to a single variant
+func (r *PostgresOfferRepository) GetByProductID(ctx context.Context, productID string, variantID *string) ([]domain.Offer, error) {
+	offers, err := offersByProduct(ctx, r.db, productID, variantID)
+	return offers, translateLookupError(err, "product")
+}
+
+// ListByProductID returns a page of a product's offers, cheapest first,
+// optionally restricted to a single variant
+func (r *PostgresOfferRepository) ListByProductID(ctx context.Context, productID string, variantID *string, params ListParams) (*PaginatedResult[domain.Offer], error) {
+	q := &listQuery{}
+	q.where("o.product_id = " + q.arg(productID))
+	if variantID != nil {
+		q.where("o.variant_id = " + q.arg(*variantID))
+	}
+
+	return listPage(ctx, r.db, q, params, listing[domain.Offer]{
+		from:     "offers o",
+		columns:  offerColumns,
+		resource: "product",
+		keyset:   offerKeyset,
+		scan:     scanOffer,
+	})
+}
+
+// ListHistory returns a page of a product's price history, newest first
+func (r *PostgresOfferRepository) ListHistory(ctx context.Context, productID string, retailerID *string, params ListParams) (*PaginatedResult[domain.PriceHistory], error) {
+	q := &listQuery{}
+	q.where("ph.product_id = " + q.arg(productID))
+	if retailerID != nil {
+		q.where("ph.retailer_id = " + q.arg(*retailerID))
+	}
+
+	return listPage(ctx, r.db, q, params, listing[domain.PriceHistory]{
+		from:     "price_history ph",
+		columns:  historyColumns,
+		resource: "product",
+		keyset:   historyKeyset,
+		scan:     scanHistory,
+	})
+}
+
+// GetHistory returns the price history of a product, newest first,
+// optionally restricted to a single retailer
+func (r *PostgresOfferRepository) GetHistory(ctx context.Context, productID string, retailerID *string) ([]domain.PriceHistory, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+historyColumns+`
+		FROM price_history ph
+		WHERE ph.product_id = $1
+		  AND ($2::text IS NULL OR ph.retailer_id = $2)
+		ORDER BY ph.time DESC`, productID, retailerID)
+	if err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+	defer rows.Close()
+
+	history := []domain.PriceHistory{}
+	for rows.Next() {
+		var h domain.PriceHistory
+		if err := scanHistory(rows, &h); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, translateLookupError(rows.Err(), "product")
+}
+
+// AggregateHistory returns the price history of a product in q's range,
+// bucketed by q.Interval per retailer and variant with gaps filled
+func (r *PostgresOfferRepository) AggregateHistory(ctx context.Context, productID string, q domain.PriceHistoryQuery) ([]domain.RetailerPriceSeries, error) {
+	// The interval is spliced into the query
+	if !domain.ValidHistoryInterval(q.Interval) {
+		return nil, apperr.NewValidationError("Invalid price history interval", nil)
+	}
+
+	// Buckets are aligned in UTC, as domain.TruncateToInterval expects
+	bucket := `date_trunc('` + q.Interval + `', ph.time AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'`
+	if r.hasTimescale(ctx) {
+		bucket = `time_bucket('1 ` + q.Interval + `', ph.time)`
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT ph.retailer_id, ph.variant_id, `+bucket+` AS bucket,
+			MIN(ph.price) FILTER (WHERE COALESCE(ph.in_stock, true)),
+			ROUND(AVG(ph.price) FILTER (WHERE COALESCE(ph.in_stock, true)), 2),
+			MAX(ph.price) FILTER (WHERE COALESCE(ph.in_stock, true)),
+			(array_agg(ph.price ORDER BY ph.time DESC))[1],
+			(array_agg(COALESCE(ph.in_stock, true) ORDER BY ph.time DESC))[1],
+			COUNT(*)
+		FROM price_history ph
+		WHERE ph.product_id = $1
+		  AND ($2::text IS NULL OR ph.retailer_id = $2)
+		  AND ($5::uuid IS NULL OR ph.variant_id = $5)
+		  AND ph.time >= $3 AND ph.time < $4
+		GROUP BY ph.retailer_id, ph.variant_id, bucket
+		ORDER BY ph.retailer_id, ph.variant_id, bucket`, productID, q.RetailerID, q.From, q.To, q.VariantID)
+	if err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+	defer rows.Close()
+
+	buckets := map[seriesKey][]domain.PriceBucket{}
+	var keys []seriesKey
+	for rows.Next() {
+		var (
+			key       seriesKey
+			variantID *string
+			b         domain.PriceBucket
+		)
+		if err := rows.Scan(&key.retailerID, &variantID, &b.Time, &b.Min, &b.Avg, &b.Max, &b.Last, &b.InStock, &b.Samples); err != nil {
+			return nil, err
+		}
+		if variantID != nil {
+			key.variantID = *variantID
+		}
+		if _, ok := buckets[key]; !ok {
+			keys = append(keys, key)
+		}
+		buckets[key] = append(buckets[key], b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+
+	// The state before the range seeds the leading gaps, and covers series
+	// whose price did not change during it
+	previous := map[seriesKey]*domain.PriceBucket{}
+	rows, err = r.db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (ph.retailer_id, ph.variant_id)
+			ph.retailer_id, ph.variant_id, ph.price, COALESCE(ph.in_stock, true)
+		FROM price_history ph
+		WHERE ph.product_id = $1
+		  AND ($2::text IS NULL OR ph.retailer_id = $2)
+		  AND ($4::uuid IS NULL OR ph.variant_id = $4)
+		  AND ph.time < $3
+		ORDER BY ph.retailer_id, ph.variant_id, ph.time DESC`, productID, q.RetailerID, q.From, q.VariantID)
+	if err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			key       seriesKey
+			variantID *string
+			b         domain.PriceBucket
+		)
+		if err := rows.Scan(&key.retailerID, &variantID, &b.Last, &b.InStock); err != nil {
+			return nil, err
+		}
+		if variantID != nil {
+			key.variantID = *variantID
+		}
+		if _, ok := buckets[key]; !ok {
+			keys = append(keys, key)
+		}
+		previous[key] = &b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+
+	if len(keys) == 0 {
+		// Tell a product without history from a missing product
+		var exists bool
+		if err := r.db.Pool.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID).Scan(&exists); err != nil {
+			return nil, translateLookupError(err, "product")
+		}
+		if !exists {
+			return nil, apperr.NewNotFoundError("product")
+		}
+	}
+
+	// Offers not tied to a variant come first within a retailer
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].retailerID != keys[j].retailerID {
+			return keys[i].retailerID < keys[j].retailerID
+		}
+		return keys[i].variantID < keys[j].variantID
+	})
+	series := make([]domain.RetailerPriceSeries, 0, len(keys))
+	for _, key := range keys {
+		s := domain.RetailerPriceSeries{
+			RetailerID: key.retailerID,
+			Buckets:    domain.FillPriceGaps(buckets[key], previous[key], q.Interval, q.From, q.To),
+		}
+		if key.variantID != "" {
+			s.VariantID = &key.variantID
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// seriesKey identifies an aggregated price series; variantID is empty for
+// the offers not tied to a variant
+type seriesKey struct {
+	retailerID string
+	variantID  string
+}
+
+// PriceStats returns the low, median and percentile rank of current among
+// the in-stock prices recorded for one variant of a product, or for its
+// offers not tied to a variant when variantID is nil, over each of the
+// windows (in days). current may be nil when there is no offer.
+func (r *PostgresOfferRepository) PriceStats(ctx context.Context, productID string, variantID *string, current *float64, windows []int) ([]domain.PriceWindowStats, error) {
+	// One set of aggregates per window, all filtered from the longest one
+	longest := 0
+	var columns []string
+	for i, days := range windows {
+		longest = max(longest, days)
+		recent := fmt.Sprintf("ph.time >= NOW() - make_interval(days => $%d)", i+4)
+		columns = append(columns,
+			"MIN(ph.price) FILTER (WHERE "+recent+")",
+			"percentile_cont(0.5) WITHIN GROUP (ORDER BY ph.price) FILTER (WHERE "+recent+")",
+			"ROUND(100.0 * COUNT(*) FILTER (WHERE "+recent+" AND ph.price < $3) / NULLIF(COUNT(*) FILTER (WHERE "+recent+"), 0), 1)",
+			"COUNT(*) FILTER (WHERE "+recent+")",
+		)
+	}
+
+	args := []any{productID, variantID, current}
+	for _, days := range windows {
+		args = append(args, days)
+	}
+	args = append(args, longest)
+
+	stats := make([]domain.PriceWindowStats, len(windows))
+	dest := make([]any, 0, 4*len(windows))
+	for i, days := range windows {
+		stats[i].Days = days
+		dest = append(dest, &stats[i].Low, &stats[i].Median, &stats[i].PercentileRank, &stats[i].Samples)
+	}
+
+	err := r.db.Pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM price_history ph
+		WHERE ph.product_id = $1
+		  AND ph.variant_id IS NOT DISTINCT FROM $2::uuid
+		  AND COALESCE(ph.in_stock, true)
+		  AND ph.time >= NOW() - make_interval(days => $%d)`,
+		strings.Join(columns, ",\n\t\t\t"), len(args)), args...).Scan(dest...)
+	if err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+
+	// Without a current price there is nothing to rank
+	if current == nil {
+		for i := range stats {
+			stats[i].PercentileRank = nil
+		}
+	}
+	return stats, nil
+}
+
+// hasTimescale reports whether the TimescaleDB extension is installed. A
+// failed check falls back to date_trunc bucketing.
+func (r *PostgresOfferRepository) hasTimescale(ctx context.Context) bool {
+	r.timescaleOnce.Do(func() {
+		installed, err := r.db.HasExtension(ctx, "timescaledb")
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to detect TimescaleDB, bucketing price history with date_trunc")
+		}
+		r.timescale = installed
+	})
+	return r.timescale
+}
+
+// Upsert inserts the offer or updates the existing one for the same
+// product/variant/retailer, and appends a price_history row when the offer is
+// new or its price or stock changed. It returns one of the Offer* outcomes.
+// The table's UNIQUE constraint does not fire when variant_id is NULL, so the
+// match is done explicitly with IS NOT DISTINCT FROM under an advisory lock.
+func (r *PostgresOfferRepository) Upsert(ctx context.Context, offer *domain.Offer) (string, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(hashtextextended($1::text || '/' || COALESCE($2::text, '') || '/' || $3::text, 0))`,
+		offer.ProductID, offer.VariantID, offer.RetailerID); err != nil {
+		return "", err
+	}
+
+	if offer.VariantID != nil {
+		var ok bool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM variants WHERE id = $1 AND product_id = $2)`,
+			*offer.VariantID, offer.ProductID).Scan(&ok); err != nil {
+			return "", translateError(err, "offer")
+		}
+		if !ok {
+			return "", apperr.NewInvalidReferenceError("variant", "variantId")
+		}
+	}
+
+	outcome := domain.OfferUnchanged
+	var changed bool
+	err = scanOffer(tx.QueryRow(ctx, `
+		WITH prev AS (
+			SELECT o.id, o.price, COALESCE(o.in_stock, true) AS in_stock
+			FROM offers o
+			WHERE o.product_id = $1 AND o.variant_id IS NOT DISTINCT FROM $2 AND o.retailer_id = $3
+			FOR UPDATE
+		)
+		UPDATE offers AS o SET
+			price = $4, shipping = $5, currency = $6, was_price = $7, discount_percent = $8,
+			url = $9, affiliate_url = $10, in_stock = $11, stock_quantity = $12,
+			delivery_days = $13, seller_name = $14, is_marketplace = $15, scraped_at = NOW(),
+			stale_at = NULL
+		FROM prev
+		WHERE o.id = prev.id
+		RETURNING `+offerColumns+`, (prev.price <> o.price OR prev.in_stock <> o.in_stock)`,
+		offer.ProductID, offer.VariantID, offer.RetailerID,
+		offer.Price, offer.Shipping, offerCurrency(offer), offer.WasPrice, offer.DiscountPercent,
+		offer.URL, offer.AffiliateURL, offer.InStock, offer.StockQuantity,
+		offer.DeliveryDays, offer.SellerName, offer.IsMarketplace,
+	), offer, &changed)
+	if changed {
+		outcome = domain.OfferUpdated
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		outcome = domain.OfferCreated
+		err = scanOffer(tx.QueryRow(ctx, `
+			INSERT INTO offers AS o (
+				product_id, variant_id, retailer_id,
+				price, shipping, currency, was_price, discount_percent,
+				url, affiliate_url, in_stock, stock_quantity,
+				delivery_days, seller_name, is_marketplace, scraped_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+			RETURNING `+offerColumns,
+			offer.ProductID, offer.VariantID, offer.RetailerID,
+			offer.Price, offer.Shipping, offerCurrency(offer), offer.WasPrice, offer.DiscountPercent,
+			offer.URL, offer.AffiliateURL, offer.InStock, offer.StockQuantity,
+			offer.DeliveryDays, offer.SellerName, offer.IsMarketplace,
+		), offer)
+	}
+	if err != nil {
+		return "", translateError(err, "offer")
+	}
+
+	if outcome != domain.OfferUnchanged {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO price_history (time, product_id, variant_id, retailer_id, price, in_stock)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			offer.ScrapedAt, offer.ProductID, offer.VariantID, offer.RetailerID,
+			offer.Price, offer.InStock); err != nil {
+			return "", translateError(err, "offer")
+		}
+	}
+
+	return outcome, tx.Commit(ctx)
+}
+
+// MarkStale flags the retailer's offers not scraped within olderThan as stale
+// and out of stock, and records the stock-out in the price history. It
+// returns the number of offers expired.
+func (r *PostgresOfferRepository) MarkStale(ctx context.Context, retailerID string, olderThan time.Duration) (int64, error) {
+	var count int64
+	err := r.db.Pool.QueryRow(ctx, `
+		WITH expired AS (
+			UPDATE offers o SET stale_at = NOW(), in_stock = false
+			WHERE o.retailer_id = $1
+			  AND o.stale_at IS NULL
+			  AND o.scraped_at < NOW() - make_interval(secs => $2)
+			RETURNING o.product_id, o.variant_id, o.retailer_id, o.price
+		), history AS (
+			INSERT INTO price_history (time, product_id, variant_id, retailer_id, price, in_stock)
+			SELECT NOW(), product_id, variant_id, retailer_id, price, false
+			FROM expired
+		)
+		SELECT COUNT(*) FROM expired`, retailerID, olderThan.Seconds()).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// offersByProduct returns the offers of a product, cheapest first, only
+// those of variantID when it is set
+func offersByProduct(ctx context.Context, db *database.DB, productID string, variantID *string) ([]domain.Offer, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+offerColumns+`
+		FROM offers o
+		WHERE o.product_id = $1
+		  AND ($2::uuid IS NULL OR o.variant_id = $2)
+		ORDER BY o.price + COALESCE(o.shipping, 0), o.retailer_id`, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offers := []domain.Offer{}
+	for rows.Next() {
+		var o domain.Offer
+		if err := scanOffer(rows, &o); err != nil {
+			return nil, err
+		}
+		offers = append(offers, o)
+	}
+	return offers, rows.Err()
+}
+
+// offerCurrency defaults an empty currency to EUR
+func offerCurrency(o *domain.Offer) string {
+	if o.Currency == "" {
+		return "EUR"
+	}
+	return o.Currency
+}
+
+// scanOffer scans offerColumns followed by any extra destinations
+func scanOffer(row scanner, o *domain.Offer, extra ...any) error {
+	return row.Scan(append([]any{
+		&o.ID, &o.ProductID, &o.VariantID, &o.RetailerID, &o.Price,
+		&o.Shipping, &o.Currency, &o.WasPrice, &o.DiscountPercent,
+		&o.URL, &o.AffiliateURL, &o.InStock, &o.StockQuantity, &o.DeliveryDays,
+		&o.SellerName, &o.IsMarketplace, &o.ScrapedAt,
+		&o.CreatedAt, &o.UpdatedAt,
+		&o.StaleAt, &o.Freshness,
+	}, extra...)...)
+}
+
+// scanHistory scans historyColumns followed by any extra destinations
+func scanHistory(row scanner, h *domain.PriceHistory, extra ...any) error {
+	return row.Scan(append([]any{
+		&h.Time, &h.ProductID, &h.VariantID, &h.RetailerID, &h.Price, &h.InStock,
+	}, extra...)...)
+}
+
+// Compile-time interface check
+var _ OfferRepository = (*PostgresOfferRepository)(nil)
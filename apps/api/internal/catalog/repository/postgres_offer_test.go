@@ -0,0 +1,585 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+// insertHistory records a price for a product at fnac
+func insertHistory(t *testing.T, db *database.DB, productID string, variantID *string, at time.Time, price float64, inStock bool) {
+	t.Helper()
+	dbtest.Exec(t, db, `
+		INSERT INTO price_history (time, product_id, variant_id, retailer_id, price, in_stock)
+		VALUES ($1, $2, $3, 'fnac', $4, $5)`, at, productID, variantID, price, inStock)
+}
+
+func TestPostgresOfferRepository_Upsert(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	v := createVariant(t, db, p.ID, "P-BLK", ptr("black"), nil)
+	other := createProduct(t, db, "Acme", "Other")
+	foreign := createVariant(t, db, other.ID, "O-BLK", ptr("black"), nil)
+
+	offer := func(variantID *string, price float64, inStock bool) *domain.Offer {
+		return &domain.Offer{
+			ProductID: p.ID, VariantID: variantID, RetailerID: "fnac",
+			Price: price, URL: "https://www.fnac.com/p", InStock: inStock,
+		}
+	}
+
+	steps := []struct {
+		offer *domain.Offer
+		want  string
+	}{
+		{offer(nil, 100, true), domain.OfferCreated},
+		{offer(nil, 100, true), domain.OfferUnchanged},
+		{offer(nil, 90, true), domain.OfferUpdated},
+		{offer(nil, 90, false), domain.OfferUpdated},
+		{offer(&v.ID, 95, true), domain.OfferCreated},
+		{offer(&v.ID, 95, true), domain.OfferUnchanged},
+	}
+	for i, step := range steps {
+		outcome, err := repo.Upsert(ctx, step.offer)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if outcome != step.want {
+			t.Errorf("step %d: outcome = %s, want %s", i, outcome, step.want)
+		}
+		if step.offer.ID == "" || step.offer.Currency != "EUR" || step.offer.Freshness != domain.FreshnessFresh {
+			t.Errorf("step %d: offer not filled from the stored row: %+v", i, step.offer)
+		}
+	}
+
+	offers, err := repo.GetByProductID(ctx, p.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offers) != 2 {
+		t.Fatalf("offers = %d, want one per variant", len(offers))
+	}
+
+	history, err := repo.GetHistory(ctx, p.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded []float64
+	for _, h := range slices.Backward(history) {
+		recorded = append(recorded, h.Price)
+	}
+	// Unchanged offers are not recorded again
+	if want := []float64{100, 90, 90, 95}; !slices.Equal(recorded, want) {
+		t.Errorf("history = %v, want %v", recorded, want)
+	}
+	if history[1].InStock || history[1].VariantID != nil || *history[0].VariantID != v.ID {
+		t.Errorf("history = %+v", history)
+	}
+
+	tests := []struct {
+		name  string
+		offer *domain.Offer
+		field string
+	}{
+		{"variant of another product", offer(&foreign.ID, 10, true), "variantId"},
+		{"unknown retailer", &domain.Offer{ProductID: p.ID, RetailerID: "nope", Price: 10, URL: "https://x.example"}, "retailerId"},
+		{"unknown product", &domain.Offer{ProductID: missingID, RetailerID: "fnac", Price: 10, URL: "https://x.example"}, "productId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.Upsert(ctx, tt.offer)
+			assertError(t, err, apperr.ErrInvalidReference, apperr.CodeInvalidReference, tt.field)
+		})
+	}
+}
+
+func TestPostgresOfferRepository_MarkStale(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	q := createProduct(t, db, "Acme", "Other")
+	old := insertOffer(t, db, p.ID, nil, "fnac", 100)
+	insertOffer(t, db, q.ID, nil, "fnac", 200)
+	otherRetailer := insertOffer(t, db, p.ID, nil, "darty", 110)
+	dbtest.Exec(t, db, `UPDATE offers SET scraped_at = NOW() - interval '3 days' WHERE id IN ($1, $2)`, old, otherRetailer)
+
+	n, err := repo.MarkStale(ctx, "fnac", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expired %d offers, want 1", n)
+	}
+
+	offers, err := repo.GetByProductID(ctx, p.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range offers {
+		stale := o.RetailerID == "fnac"
+		if (o.StaleAt != nil) != stale || o.InStock == stale || (o.Freshness == domain.FreshnessStale) != stale {
+			t.Errorf("%s offer = %+v, want stale %v", o.RetailerID, o, stale)
+		}
+	}
+
+	history, err := repo.GetHistory(ctx, p.ID, ptr("fnac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].InStock || history[0].Price != 100 {
+		t.Errorf("history = %+v, want the stock-out at 100", history)
+	}
+
+	// Already stale offers are left alone
+	if n, err := repo.MarkStale(ctx, "fnac", 24*time.Hour); err != nil || n != 0 {
+		t.Errorf("second run expired %d offers (%v), want 0", n, err)
+	}
+
+	// Scraping the offer again makes it fresh and back in stock
+	outcome, err := repo.Upsert(ctx, &domain.Offer{
+		ProductID: p.ID, RetailerID: "fnac", Price: 100, URL: "https://www.fnac.com/p", InStock: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != domain.OfferUpdated {
+		t.Errorf("outcome = %s, want %s", outcome, domain.OfferUpdated)
+	}
+	offers, err = repo.GetByProductID(ctx, p.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range offers {
+		if o.RetailerID == "fnac" && (o.StaleAt != nil || !o.InStock) {
+			t.Errorf("re-scraped offer = %+v, want fresh and in stock", o)
+		}
+	}
+}
+
+func TestPostgresOfferRepository_SiblingVariantHistory(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	black := createVariant(t, db, p.ID, "P-BLK", ptr("black"), nil)
+	white := createVariant(t, db, p.ID, "P-WHT", ptr("white"), nil)
+
+	for _, v := range []*domain.Variant{black, white} {
+		if _, err := repo.Upsert(ctx, &domain.Offer{
+			ProductID: p.ID, VariantID: &v.ID, RetailerID: "fnac", Price: 100,
+			URL: "https://www.fnac.com/p/" + v.SKU, InStock: true,
+		}); err != nil {
+			t.Fatalf("upsert %s: %v", v.SKU, err)
+		}
+	}
+	dbtest.Exec(t, db, `UPDATE offers SET scraped_at = NOW() - interval '3 days' WHERE product_id = $1`, p.ID)
+
+	// Both expire in the same statement, so their stock-outs share NOW()
+	if n, err := repo.MarkStale(ctx, "fnac", 24*time.Hour); err != nil || n != 2 {
+		t.Fatalf("expired %d offers (%v), want 2", n, err)
+	}
+
+	for _, v := range []*domain.Variant{black, white} {
+		var rows, stockOuts int
+		if err := db.Pool.QueryRow(ctx, `
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE NOT in_stock)
+			FROM price_history WHERE variant_id = $1`, v.ID).Scan(&rows, &stockOuts); err != nil {
+			t.Fatal(err)
+		}
+		if rows != 2 || stockOuts != 1 {
+			t.Errorf("%s history has %d rows, %d stock-outs, want 2 and 1", v.SKU, rows, stockOuts)
+		}
+	}
+
+	// The stock-outs tie on time and retailer: one row per page, a cursor
+	// walk must still list every row once, both ways
+	walk := func(params ListParams, next func(*PaginatedResult[domain.PriceHistory]) *string) (rows []string, last *PaginatedResult[domain.PriceHistory]) {
+		for len(rows) <= 4 {
+			page, err := repo.ListHistory(ctx, p.ID, nil, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, h := range page.Items {
+				rows = append(rows, fmt.Sprintf("%s %s %v", h.Time.Format(time.RFC3339Nano), *h.VariantID, h.InStock))
+			}
+			last = page
+			cursor := next(page)
+			if cursor == nil {
+				break
+			}
+			params.Cursor = *cursor
+		}
+		return rows, last
+	}
+
+	forward, last := walk(ListParams{Limit: 1}, func(page *PaginatedResult[domain.PriceHistory]) *string { return page.NextCursor })
+	seen := map[string]bool{}
+	for _, row := range forward {
+		seen[row] = true
+	}
+	if len(forward) != 4 || len(seen) != 4 {
+		t.Fatalf("forward walk = %v, want 4 distinct rows", forward)
+	}
+
+	backward, _ := walk(ListParams{Limit: 1, Cursor: *last.PrevCursor}, func(page *PaginatedResult[domain.PriceHistory]) *string { return page.PrevCursor })
+	slices.Reverse(backward)
+	if !slices.Equal(backward, forward[:3]) {
+		t.Errorf("backward walk = %v, want %v", backward, forward[:3])
+	}
+}
+
+func TestPostgresOfferRepository_ListByProductID(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	v := createVariant(t, db, p.ID, "P-BLK", ptr("black"), nil)
+	for retailer, price := range map[string]float64{"fnac": 300, "darty": 100, "boulanger": 200, "ldlc": 250} {
+		insertOffer(t, db, p.ID, nil, retailer, price)
+	}
+	insertOffer(t, db, p.ID, &v.ID, "fnac", 150)
+	// Shipping counts towards the total price
+	dbtest.Exec(t, db, `UPDATE offers SET shipping = 100 WHERE retailer_id = 'ldlc'`)
+
+	// darty 100, fnac variant 150, boulanger 200, fnac 300, ldlc 250 + 100
+	want := []string{"darty", "fnac", "boulanger", "fnac", "ldlc"}
+
+	offers, err := repo.GetByProductID(ctx, p.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := offerRetailers(offers); !slices.Equal(got, want) {
+		t.Errorf("offers = %v, want %v", got, want)
+	}
+
+	page, err := repo.ListByProductID(ctx, p.ID, nil, ListParams{Page: 2, PerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := offerRetailers(page.Items); !slices.Equal(got, want[2:4]) || page.Total != 5 {
+		t.Errorf("page 2 = %v of %d, want %v of 5", got, page.Total, want[2:4])
+	}
+
+	var walked []string
+	params := ListParams{Limit: 2}
+	for {
+		page, err := repo.ListByProductID(ctx, p.ID, nil, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		walked = append(walked, offerRetailers(page.Items)...)
+		if page.NextCursor == nil {
+			break
+		}
+		params.Cursor = *page.NextCursor
+	}
+	if !slices.Equal(walked, want) {
+		t.Errorf("cursor walk = %v, want %v", walked, want)
+	}
+
+	_, err = repo.GetByProductID(ctx, "not-a-uuid", nil)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	_, err = repo.ListByProductID(ctx, "not-a-uuid", nil, ListParams{})
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresOfferRepository_ListByVariant(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	v := createVariant(t, db, p.ID, "P-BLK", ptr("black"), nil)
+	insertOffer(t, db, p.ID, nil, "darty", 100)
+	insertOffer(t, db, p.ID, &v.ID, "fnac", 150)
+
+	variantOffers, err := repo.ListByProductID(ctx, p.ID, &v.ID, ListParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variantOffers.Items) != 1 || variantOffers.Items[0].Price != 150 {
+		t.Errorf("variant offers = %+v, want the one at 150", variantOffers.Items)
+	}
+
+	offers, err := repo.GetByProductID(ctx, p.ID, &v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := offerRetailers(offers); !slices.Equal(got, []string{"fnac"}) {
+		t.Errorf("variant offers = %v, want [fnac]", got)
+	}
+}
+
+func TestPostgresOfferRepository_History(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	for i, price := range []float64{100, 110, 120} {
+		insertHistory(t, db, p.ID, nil, start.Add(time.Duration(i)*time.Hour), price, true)
+	}
+	dbtest.Exec(t, db, `INSERT INTO price_history (time, product_id, retailer_id, price)
+		VALUES ($1, $2, 'darty', 105)`, start.Add(time.Hour), p.ID)
+
+	all, err := repo.GetHistory(ctx, p.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || !all[0].Time.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("history = %+v, want 4 rows newest first", all)
+	}
+	fnac, err := repo.GetHistory(ctx, p.ID, ptr("fnac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fnac) != 3 {
+		t.Errorf("fnac history = %d rows, want 3", len(fnac))
+	}
+
+	var walked []float64
+	params := ListParams{Limit: 3}
+	for {
+		page, err := repo.ListHistory(ctx, p.ID, nil, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range page.Items {
+			walked = append(walked, h.Price)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		params.Cursor = *page.NextCursor
+	}
+	// darty and fnac share a timestamp: the retailer breaks the tie
+	if want := []float64{120, 110, 105, 100}; !slices.Equal(walked, want) {
+		t.Errorf("cursor walk = %v, want %v", walked, want)
+	}
+
+	page, err := repo.ListHistory(ctx, p.ID, ptr("darty"), ListParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Items[0].Price != 105 {
+		t.Errorf("darty history = %+v", page)
+	}
+
+	_, err = repo.GetHistory(ctx, "not-a-uuid", nil)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresOfferRepository_AggregateHistory(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	insertHistory(t, db, p.ID, nil, day.AddDate(0, 0, -2), 120, true)
+	insertHistory(t, db, p.ID, nil, day.AddDate(0, 0, 1).Add(10*time.Hour), 100, true)
+	insertHistory(t, db, p.ID, nil, day.AddDate(0, 0, 1).Add(14*time.Hour), 110, true)
+	insertHistory(t, db, p.ID, nil, day.AddDate(0, 0, 1).Add(18*time.Hour), 90, false)
+	// darty's price did not change during the range
+	dbtest.Exec(t, db, `INSERT INTO price_history (time, product_id, retailer_id, price)
+		VALUES ($1, $2, 'darty', 200)`, day.AddDate(0, 0, -1), p.ID)
+
+	q := domain.PriceHistoryQuery{From: day, To: day.AddDate(0, 0, 3), Interval: domain.HistoryIntervalDay}
+	series, err := repo.AggregateHistory(ctx, p.ID, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].RetailerID != "darty" || series[1].RetailerID != "fnac" {
+		t.Fatalf("series = %+v, want darty and fnac", series)
+	}
+
+	for i, b := range series[0].Buckets {
+		if !b.Filled || b.Last == nil || *b.Last != 200 || !b.InStock {
+			t.Errorf("darty bucket %d = %+v, want carried over at 200", i, b)
+		}
+	}
+
+	fnac := series[1].Buckets
+	if len(fnac) != 3 {
+		t.Fatalf("fnac buckets = %d, want 3", len(fnac))
+	}
+	for i, b := range fnac {
+		if want := day.AddDate(0, 0, i); !b.Time.Equal(want) {
+			t.Errorf("bucket %d time = %v, want %v", i, b.Time, want)
+		}
+	}
+	if b := fnac[0]; !b.Filled || *b.Last != 120 || !b.InStock {
+		t.Errorf("leading bucket = %+v, want the state before the range", b)
+	}
+	// The stock-out is the latest state but not part of the in-stock aggregates
+	if b := fnac[1]; b.Filled || b.Samples != 3 || *b.Min != 100 || *b.Avg != 105 || *b.Max != 110 || *b.Last != 90 || b.InStock {
+		t.Errorf("sampled bucket = %+v", b)
+	}
+	if b := fnac[2]; !b.Filled || *b.Last != 90 || b.InStock {
+		t.Errorf("trailing bucket = %+v, want the stock-out carried over", b)
+	}
+
+	// A variant's prices form their own series instead of skewing the
+	// product's
+	v := createVariant(t, db, p.ID, "P-512", nil, ptr(512))
+	insertHistory(t, db, p.ID, &v.ID, day.AddDate(0, 0, 1).Add(12*time.Hour), 300, true)
+	series, err = repo.AggregateHistory(ctx, p.ID, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 3 || series[1].VariantID != nil || series[2].RetailerID != "fnac" || series[2].VariantID == nil || *series[2].VariantID != v.ID {
+		t.Fatalf("series = %+v, want darty, fnac and fnac's variant", series)
+	}
+	if b := series[1].Buckets[1]; b.Samples != 3 || *b.Max != 110 {
+		t.Errorf("product bucket = %+v, want the variant's price left out", b)
+	}
+	if b := series[2].Buckets[1]; b.Samples != 1 || *b.Min != 300 || *b.Max != 300 {
+		t.Errorf("variant bucket = %+v, want its price alone", b)
+	}
+
+	q.VariantID = &v.ID
+	series, err = repo.AggregateHistory(ctx, p.ID, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].VariantID == nil || *series[0].VariantID != v.ID {
+		t.Errorf("series = %+v, want only the variant", series)
+	}
+	q.VariantID = nil
+
+	q.RetailerID = ptr("darty")
+	series, err = repo.AggregateHistory(ctx, p.ID, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].RetailerID != "darty" {
+		t.Errorf("series = %+v, want only darty", series)
+	}
+
+	empty := createProduct(t, db, "Acme", "Empty")
+	series, err = repo.AggregateHistory(ctx, empty.ID, q)
+	if err != nil || len(series) != 0 {
+		t.Errorf("series of a product without history = %+v, %v, want none", series, err)
+	}
+
+	_, err = repo.AggregateHistory(ctx, missingID, q)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	q.Interval = "fortnight"
+	_, err = repo.AggregateHistory(ctx, p.ID, q)
+	assertError(t, err, apperr.ErrValidation, apperr.CodeValidationFailed, "")
+}
+
+func TestPostgresOfferRepository_PriceStats(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresOfferRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	v := createVariant(t, db, p.ID, "P-BLK", ptr("black"), nil)
+	now := time.Now()
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+	insertHistory(t, db, p.ID, nil, daysAgo(5), 50, false)
+	insertHistory(t, db, p.ID, nil, daysAgo(10), 100, true)
+	insertHistory(t, db, p.ID, &v.ID, daysAgo(15), 300, true)
+	insertHistory(t, db, p.ID, nil, daysAgo(20), 120, true)
+	insertHistory(t, db, p.ID, nil, daysAgo(60), 80, true)
+	insertHistory(t, db, p.ID, nil, daysAgo(120), 10, true)
+
+	tests := []struct {
+		name      string
+		variantID *string
+		current   *float64
+		want      []domain.PriceWindowStats
+	}{
+		{
+			// Only offers not tied to a variant: the variant's 300 is left out
+			name:    "product",
+			current: ptr(110.0),
+			want: []domain.PriceWindowStats{
+				{Days: 30, Low: ptr(100.0), Median: ptr(110.0), PercentileRank: ptr(50.0), Samples: 2},
+				{Days: 90, Low: ptr(80.0), Median: ptr(100.0), PercentileRank: ptr(66.7), Samples: 3},
+			},
+		},
+		{
+			name:      "variant",
+			variantID: &v.ID,
+			current:   ptr(110.0),
+			want: []domain.PriceWindowStats{
+				{Days: 30, Low: ptr(300.0), Median: ptr(300.0), PercentileRank: ptr(0.0), Samples: 1},
+				{Days: 90, Low: ptr(300.0), Median: ptr(300.0), PercentileRank: ptr(0.0), Samples: 1},
+			},
+		},
+		{
+			name: "no current price",
+			want: []domain.PriceWindowStats{
+				{Days: 30, Low: ptr(100.0), Median: ptr(110.0), Samples: 2},
+				{Days: 90, Low: ptr(80.0), Median: ptr(100.0), Samples: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := repo.PriceStats(ctx, p.ID, tt.variantID, tt.current, []int{30, 90})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stats) != len(tt.want) {
+				t.Fatalf("stats = %+v, want %d windows", stats, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := stats[i]; !equalStats(got, want) {
+					t.Errorf("window %d = %s, want %s", want.Days, formatStats(got), formatStats(want))
+				}
+			}
+		})
+	}
+
+	empty := createProduct(t, db, "Acme", "Empty")
+	stats, err := repo.PriceStats(ctx, empty.ID, nil, ptr(100.0), []int{30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stats[0]; s.Low != nil || s.Median != nil || s.PercentileRank != nil || s.Samples != 0 {
+		t.Errorf("stats without history = %s, want empty", formatStats(s))
+	}
+}
+
+func equalStats(a, b domain.PriceWindowStats) bool {
+	eq := func(x, y *float64) bool { return (x == nil) == (y == nil) && (x == nil || *x == *y) }
+	return a.Days == b.Days && a.Samples == b.Samples &&
+		eq(a.Low, b.Low) && eq(a.Median, b.Median) && eq(a.PercentileRank, b.PercentileRank)
+}
+
+func formatStats(s domain.PriceWindowStats) string {
+	f := func(x *float64) any {
+		if x == nil {
+			return nil
+		}
+		return *x
+	}
+	return fmt.Sprintf("{low %v median %v rank %v samples %d}", f(s.Low), f(s.Median), f(s.PercentileRank), s.Samples)
+}
+
+func offerRetailers(offers []domain.Offer) []string {
+	ids := []string{}
+	for _, o := range offers {
+		ids = append(ids, o.RetailerID)
+	}
+	return ids
+}
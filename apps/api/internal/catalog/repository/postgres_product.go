@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// productColumns is the column list used to scan a domain.Product (table alias "p")
+const productColumns = `p.id, p.category_id, p.name, p.slug, p.brand, p.model, p.ean, p.sku,
+	p.image_url, COALESCE(p.images, '[]'::jsonb), COALESCE(p.attributes, '{}'::jsonb),
+	p.source, p.source_url, p.description, COALESCE(p.active, true),
+	COALESCE(p.created_at, NOW()), COALESCE(p.updated_at, NOW()), p.scraped_at,
+	COALESCE(p.quarantined_attributes, '{}'::jsonb)`
+
+// offerSummaryJoin computes the best fresh in-stock price and offer count per product
+const offerSummaryJoin = `LEFT JOIN LATERAL (
+	SELECT MIN(price) FILTER (WHERE COALESCE(in_stock, true) AND stale_at IS NULL) AS best_price,
+	       COUNT(*) AS offer_count
+	FROM offers
+	WHERE product_id = p.id
+) os ON true`
+
+// Maximum attempts to find a free slug when concurrent inserts collide
+const maxSlugAttempts = 3
+
+// PostgresProductRepository implements ProductRepository on top of pgx
+type PostgresProductRepository struct {
+	db *database.DB
+}
+
+// NewPostgresProductRepository creates a new Postgres-backed product repository
+func NewPostgresProductRepository(db *database.DB) *PostgresProductRepository {
+	return &PostgresProductRepository{db: db}
+}
+
+// GetByID retrieves a product with its offers by ID
+func (r *PostgresProductRepository) GetByID(ctx context.Context, id string) (*domain.ProductWithOffers, error) {
+	return r.getWithOffers(ctx, "p.id = $1", id)
+}
+
+// GetBySlug retrieves a product with its offers by slug
+func (r *PostgresProductRepository) GetBySlug(ctx context.Context, slug string) (*domain.ProductWithOffers, error) {
+	return r.getWithOffers(ctx, "p.slug = $1", slug)
+}
+
+// List retrieves a paginated list of products with their offer summary
+func (r *PostgresProductRepository) List(ctx context.Context, params ListParams) (*PaginatedResult[domain.ProductWithOffers], error) {
+	q := &listQuery{}
+	applyProductFilters(q, params.Filters)
+
+	return listPage(ctx, r.db, q, params, listing[domain.ProductWithOffers]{
+		from:      "products p " + offerSummaryJoin,
+		countFrom: productCountFrom(q),
+		columns:   productColumns + ", os.best_price, os.offer_count",
+		resource:  "product",
+		keyset:    productKeyset(params),
+		scan: func(row scanner, pwo *domain.ProductWithOffers, extra ...any) error {
+			pwo.Offers = []domain.Offer{}
+			return scanProductSummary(row, pwo, extra...)
+		},
+	})
+}
+
+// Search retrieves products matching the query by exact EAN/SKU or fuzzy
+// name, brand and model, ranked by relevance unless another sort is requested
+func (r *PostgresProductRepository) Search(ctx context.Context, query string, params ListParams) (*PaginatedResult[domain.ProductSearchHit], error) {
+	q := &listQuery{}
+	t := q.arg(query)
+	q.where(searchMatch(t, q.arg(likePattern(query))))
+	applyProductFilters(q, params.Filters)
+
+	score := searchScore(t)
+	k := relevanceKeyset(score)
+	if params.SortBy != "" {
+		k = productKeyset(params)
+	}
+
+	result, err := listPage(ctx, r.db, q, params, listing[domain.ProductSearchHit]{
+		from:      "products p " + offerSummaryJoin,
+		countFrom: productCountFrom(q),
+		columns:   productColumns + ", os.best_price, os.offer_count, " + score,
+		resource:  "product",
+		keyset:    k,
+		scan: func(row scanner, hit *domain.ProductSearchHit, extra ...any) error {
+			hit.Offers = []domain.Offer{}
+			return scanProductSummary(row, &hit.ProductWithOffers, append([]any{&hit.Score}, extra...)...)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result.Items {
+		hit := &result.Items[i]
+		hit.Score = roundScore(hit.Score)
+		hit.Highlights = searchHighlights(&hit.Product, query)
+	}
+	return result, nil
+}
+
+// Suggest returns up to limit type-ahead suggestions for the typed text
+func (r *PostgresProductRepository) Suggest(ctx context.Context, query string, limit int) ([]domain.Suggestion, error) {
+	prefix, wordStart := likePrefixPatterns(query)
+	rows, err := r.db.Pool.Query(ctx, suggestQuery, query, prefix, wordStart, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []domain.Suggestion{}
+	for rows.Next() {
+		var sg domain.Suggestion
+		if err := rows.Scan(&sg.Type, &sg.Text, &sg.ID, &sg.Slug, &sg.Score); err != nil {
+			return nil, err
+		}
+		sg.Score = roundScore(sg.Score)
+		suggestions = append(suggestions, sg)
+	}
+	return suggestions, rows.Err()
+}
+
+// Create inserts a new product, generating a unique slug from its brand and name
+func (r *PostgresProductRepository) Create(ctx context.Context, req *domain.CreateProductRequest) (*domain.Product, error) {
+	images := req.Images
+	if images == nil {
+		images = []string{}
+	}
+	attributes := req.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	quarantined := req.QuarantinedAttributes
+	if quarantined == nil {
+		quarantined = map[string]interface{}{}
+	}
+
+	base := productSlugBase(req.Brand, req.Name)
+
+	for attempt := 0; ; attempt++ {
+		slug, err := uniqueSlug(ctx, r.db.Pool, "products", base)
+		if err != nil {
+			return nil, err
+		}
+
+		var p domain.Product
+		err = scanProduct(r.db.Pool.QueryRow(ctx, `
+			INSERT INTO products AS p (
+				category_id, name, slug, brand, model, ean, sku, image_url,
+				images, attributes, quarantined_attributes, source, source_url, description
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			RETURNING `+productColumns,
+			req.CategoryID, req.Name, slug, req.Brand, req.Model, req.EAN, req.SKU, req.ImageURL,
+			images, attributes, quarantined, req.Source, req.SourceURL, req.Description,
+		), &p)
+
+		// Another writer took the slug between lookup and insert: try again
+		if isUniqueViolation(err, "products_slug_key") && attempt < maxSlugAttempts-1 {
+			continue
+		}
+		if err != nil {
+			return nil, translateError(err, "product")
+		}
+		return &p, nil
+	}
+}
+
+// Update applies the non-nil fields of req to the product
+func (r *PostgresProductRepository) Update(ctx context.Context, id string, req *domain.UpdateProductRequest) (*domain.Product, error) {
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.CategoryID != nil {
+		set("category_id", *req.CategoryID)
+	}
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Brand != nil {
+		set("brand", *req.Brand)
+	}
+	if req.Model != nil {
+		set("model", *req.Model)
+	}
+	if req.EAN != nil {
+		set("ean", *req.EAN)
+	}
+	if req.SKU != nil {
+		set("sku", *req.SKU)
+	}
+	if req.ImageURL != nil {
+		set("image_url", *req.ImageURL)
+	}
+	if req.Images != nil {
+		set("images", req.Images)
+	}
+	if req.Attributes != nil {
+		set("attributes", req.Attributes)
+	}
+	if req.QuarantinedAttributes != nil {
+		set("quarantined_attributes", req.QuarantinedAttributes)
+	}
+	if req.Description != nil {
+		set("description", *req.Description)
+	}
+	if req.Active != nil {
+		set("active", *req.Active)
+	}
+
+	// Nothing to change: return the current state
+	if len(sets) == 0 {
+		pwo, err := r.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &pwo.Product, nil
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf(`UPDATE products AS p SET %s WHERE p.id = $%d RETURNING %s`,
+		strings.Join(sets, ", "), len(args), productColumns)
+
+	var p domain.Product
+	if err := scanProduct(r.db.Pool.QueryRow(ctx, query, args...), &p); err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+	return &p, nil
+}
+
+// Delete removes a product (offers, variants and history cascade)
+func (r *PostgresProductRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM products WHERE id = $1`, id)
+	if err != nil {
+		return translateDeleteError(err, "product")
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NewNotFoundError("product")
+	}
+	return nil
+}
+
+// ListByCategoryAfter returns up to limit products of a category with an ID
+// greater than afterID (empty for the first batch), in ID order
+func (r *PostgresProductRepository) ListByCategoryAfter(ctx context.Context, categoryID, afterID string, limit int) ([]domain.Product, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products p
+		WHERE p.category_id = $1 AND ($2::text = '' OR p.id > NULLIF($2::text, '')::uuid)
+		ORDER BY p.id
+		LIMIT $3`, categoryID, afterID, limit)
+	if err != nil {
+		return nil, translateError(err, "product")
+	}
+	defer rows.Close()
+
+	var products []domain.Product
+	for rows.Next() {
+		var p domain.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// getWithOffers loads a single product matching where and attaches its
+// variants and offers
+func (r *PostgresProductRepository) getWithOffers(ctx context.Context, where string, arg any) (*domain.ProductWithOffers, error) {
+	var pwo domain.ProductWithOffers
+	err := scanProduct(r.db.Pool.QueryRow(ctx,
+		`SELECT `+productColumns+` FROM products p WHERE `+where, arg,
+	), &pwo.Product)
+	if err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+
+	variants, err := variantsByProduct(ctx, r.db, pwo.ID)
+	if err != nil {
+		return nil, err
+	}
+	pwo.Variants = variants
+
+	offers, err := offersByProduct(ctx, r.db, pwo.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	pwo.Offers = offers
+	pwo.OfferCount = len(offers)
+	pwo.BestPrice = bestPrice(offers)
+
+	return &pwo, nil
+}
+
+// productCountFrom returns the FROM clause for counting products matching q,
+// only paying for the offer summary when a filter needs it
+func productCountFrom(q *listQuery) string {
+	if q.needsOffers {
+		return "products p " + offerSummaryJoin
+	}
+	return "products p"
+}
+
+// productSlugBase prefixes the name with the brand unless it already starts with it
+func productSlugBase(brand, name string) string {
+	base := name
+	if !strings.HasPrefix(strings.ToLower(name), strings.ToLower(brand)) {
+		base = brand + " " + name
+	}
+	if slug := Slugify(base); slug != "" {
+		return slug
+	}
+	return "product"
+}
+
+// bestPrice returns the lowest fresh in-stock price, or nil if there is none
+func bestPrice(offers []domain.Offer) *float64 {
+	var best *float64
+	for i := range offers {
+		if !offers[i].InStock || offers[i].IsStale() {
+			continue
+		}
+		if best == nil || offers[i].Price < *best {
+			price := offers[i].Price
+			best = &price
+		}
+	}
+	return best
+}
+
+func scanProduct(row scanner, p *domain.Product) error {
+	return row.Scan(
+		&p.ID, &p.CategoryID, &p.Name, &p.Slug, &p.Brand, &p.Model, &p.EAN, &p.SKU,
+		&p.ImageURL, &p.Images, &p.Attributes,
+		&p.Source, &p.SourceURL, &p.Description, &p.Active,
+		&p.CreatedAt, &p.UpdatedAt, &p.ScrapedAt,
+		&p.QuarantinedAttributes,
+	)
+}
+
+// scanProductSummary scans productColumns followed by best_price, offer_count
+// and any extra destinations
+func scanProductSummary(row scanner, pwo *domain.ProductWithOffers, extra ...any) error {
+	p := &pwo.Product
+	return row.Scan(append([]any{
+		&p.ID, &p.CategoryID, &p.Name, &p.Slug, &p.Brand, &p.Model, &p.EAN, &p.SKU,
+		&p.ImageURL, &p.Images, &p.Attributes,
+		&p.Source, &p.SourceURL, &p.Description, &p.Active,
+		&p.CreatedAt, &p.UpdatedAt, &p.ScrapedAt,
+		&p.QuarantinedAttributes,
+		&pwo.BestPrice, &pwo.OfferCount,
+	}, extra...)...)
+}
+
+// Compile-time interface check
+var _ ProductRepository = (*PostgresProductRepository)(nil)
@@ -0,0 +1,521 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+func TestPostgresProductRepository_GetWithOffers(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone 1")
+	createVariant(t, db, p.ID, "P1-BLK", ptr("black"), nil)
+	dbtest.Exec(t, db, `
+		INSERT INTO offers (product_id, retailer_id, price, url, in_stock, stale_at) VALUES
+			($1, 'amazon_fr', 500, 'https://a.example/p1', true, NULL),
+			($1, 'fnac', 450, 'https://f.example/p1', false, NULL),
+			($1, 'darty', 400, 'https://d.example/p1', true, NOW())`, p.ID)
+
+	lookups := map[string]func() (*domain.ProductWithOffers, error){
+		"GetByID":   func() (*domain.ProductWithOffers, error) { return repo.GetByID(ctx, p.ID) },
+		"GetBySlug": func() (*domain.ProductWithOffers, error) { return repo.GetBySlug(ctx, p.Slug) },
+	}
+	for name, get := range lookups {
+		t.Run(name, func(t *testing.T) {
+			pwo, err := get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pwo.ID != p.ID {
+				t.Errorf("ID = %s, want %s", pwo.ID, p.ID)
+			}
+			if len(pwo.Offers) != 3 || pwo.OfferCount != 3 {
+				t.Errorf("offers = %d, offerCount = %d, want 3", len(pwo.Offers), pwo.OfferCount)
+			}
+			if len(pwo.Variants) != 1 {
+				t.Errorf("variants = %d, want 1", len(pwo.Variants))
+			}
+			// The cheaper offers are out of stock or stale
+			if pwo.BestPrice == nil || *pwo.BestPrice != 500 {
+				t.Errorf("bestPrice = %v, want 500", pwo.BestPrice)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		for _, id := range []string{missingID, "not-a-uuid"} {
+			_, err := repo.GetByID(ctx, id)
+			assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+		}
+		_, err := repo.GetBySlug(ctx, "no-such-product")
+		assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	})
+}
+
+func TestPostgresProductRepository_CreateSlug(t *testing.T) {
+	db := dbtest.New(t)
+
+	t.Run("taken", func(t *testing.T) {
+		first := createProduct(t, db, "Acme", "Taken")
+		second := createProduct(t, db, "Acme", "Taken")
+		if first.Slug != "acme-taken" || second.Slug != "acme-taken-2" {
+			t.Errorf("slugs = %q, %q, want acme-taken, acme-taken-2", first.Slug, second.Slug)
+		}
+	})
+
+	t.Run("brand prefix", func(t *testing.T) {
+		p := createProduct(t, db, "Acme", "Acme Phone")
+		if p.Slug != "acme-phone" {
+			t.Errorf("slug = %q, want acme-phone", p.Slug)
+		}
+	})
+
+	t.Run("race", func(t *testing.T) {
+		// The first insert collides as if a concurrent writer had taken the
+		// slug; sequences survive the rollback, so it only collides once
+		dbtest.Exec(t, db, `CREATE SEQUENCE race_attempts`)
+		dbtest.Exec(t, db, `
+			CREATE FUNCTION collide_once() RETURNS trigger AS $$
+			BEGIN
+				IF NEW.name = 'Race' AND nextval('race_attempts') = 1 THEN
+					RAISE unique_violation USING CONSTRAINT = 'products_slug_key';
+				END IF;
+				RETURN NEW;
+			END $$ LANGUAGE plpgsql`)
+		dbtest.Exec(t, db, `CREATE TRIGGER trg_collide_once BEFORE INSERT ON products
+			FOR EACH ROW EXECUTE FUNCTION collide_once()`)
+
+		p := createProduct(t, db, "Acme", "Race")
+		if p.Slug != "acme-race" {
+			t.Errorf("slug = %q, want acme-race", p.Slug)
+		}
+		var attempts int
+		if err := db.Pool.QueryRow(context.Background(), `SELECT last_value FROM race_attempts`).Scan(&attempts); err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 2 {
+			t.Errorf("insert attempts = %d, want 2", attempts)
+		}
+	})
+}
+
+func TestPostgresProductRepository_Update(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+
+	updated, err := repo.Update(ctx, p.ID, &domain.UpdateProductRequest{
+		Name:       ptr("Phone Pro"),
+		Attributes: map[string]interface{}{"ram_gb": 8.0},
+		Active:     ptr(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Phone Pro" || updated.Active || updated.Attributes["ram_gb"] != 8.0 {
+		t.Errorf("updated = %+v", updated)
+	}
+	// The slug is kept so that links stay valid
+	if updated.Slug != p.Slug {
+		t.Errorf("slug = %q, want %q", updated.Slug, p.Slug)
+	}
+
+	unchanged, err := repo.Update(ctx, p.ID, &domain.UpdateProductRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.Name != "Phone Pro" {
+		t.Errorf("empty update returned %+v", unchanged)
+	}
+
+	_, err = repo.Update(ctx, missingID, &domain.UpdateProductRequest{Name: ptr("x")})
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	_, err = repo.Update(ctx, p.ID, &domain.UpdateProductRequest{CategoryID: ptr(missingID)})
+	assertError(t, err, apperr.ErrInvalidReference, apperr.CodeInvalidReference, "categoryId")
+}
+
+func TestPostgresProductRepository_Delete(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Gone")
+	createVariant(t, db, p.ID, "GONE-1", nil, nil)
+	insertOffer(t, db, p.ID, nil, "fnac", 100)
+
+	if err := repo.Delete(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repo.GetByID(ctx, p.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	err = repo.Delete(ctx, p.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresProductRepository_Errors(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	ean := "4006381333931"
+	p, err := repo.Create(ctx, &domain.CreateProductRequest{
+		CategoryID: dbtest.SmartphonesCategoryID, Name: "Errors", Brand: "Acme", Model: "E1", EAN: &ean, Source: "manual",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertOffer(t, db, p.ID, nil, "fnac", 300)
+	dbtest.Exec(t, db, `INSERT INTO affiliate_clicks (offer_id, product_id, retailer_id)
+		SELECT id, product_id, retailer_id FROM offers WHERE product_id = $1`, p.ID)
+
+	tests := []struct {
+		name  string
+		call  func() error
+		kind  error
+		code  string
+		field string
+	}{
+		{
+			name: "duplicate EAN",
+			call: func() error {
+				_, err := repo.Create(ctx, &domain.CreateProductRequest{
+					CategoryID: dbtest.SmartphonesCategoryID, Name: "Errors 2", Brand: "Acme", Model: "E2", EAN: &ean, Source: "manual",
+				})
+				return err
+			},
+			kind: apperr.ErrConflict, code: apperr.CodeConflict, field: "ean",
+		},
+		{
+			name: "unknown category",
+			call: func() error {
+				_, err := repo.Create(ctx, &domain.CreateProductRequest{
+					CategoryID: missingID, Name: "Errors 3", Brand: "Acme", Model: "E3", Source: "manual",
+				})
+				return err
+			},
+			kind: apperr.ErrInvalidReference, code: apperr.CodeInvalidReference, field: "categoryId",
+		},
+		{
+			name: "delete referenced",
+			call: func() error { return repo.Delete(ctx, p.ID) },
+			kind: apperr.ErrConflict, code: apperr.CodeInUse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, tt.call(), tt.kind, tt.code, tt.field)
+		})
+	}
+}
+
+// createPricedProducts creates products A to D with an offer at fnac, and E
+// of another brand without offers
+func createPricedProducts(t *testing.T, db *database.DB) {
+	t.Helper()
+	for name, price := range map[string]float64{"A": 300, "B": 100, "C": 500, "D": 200} {
+		p := createProduct(t, db, "Acme", name)
+		insertOffer(t, db, p.ID, nil, "fnac", price)
+	}
+	createProduct(t, db, "Other", "E")
+}
+
+func TestPostgresProductRepository_List(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	createPricedProducts(t, db)
+	byPrice := ListParams{SortBy: SortByPrice, SortOrder: SortAsc}
+
+	t.Run("offset", func(t *testing.T) {
+		want := [][]string{{"B", "D"}, {"A", "C"}, {"E"}}
+		for i, names := range want {
+			params := byPrice
+			params.Page, params.PerPage = i+1, 2
+			page, err := repo.List(ctx, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := productNames(page.Items); !slices.Equal(got, names) {
+				t.Errorf("page %d = %v, want %v", i+1, got, names)
+			}
+			if page.Total != 5 || page.TotalPages != 3 {
+				t.Errorf("page %d: total = %d, totalPages = %d, want 5 and 3", i+1, page.Total, page.TotalPages)
+			}
+		}
+	})
+
+	filters := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []string
+	}{
+		{"brand", map[string]interface{}{FilterBrands: []string{"OTHER"}}, []string{"E"}},
+		{"price range", map[string]interface{}{FilterMinPrice: 150.0, FilterMaxPrice: 350.0}, []string{"D", "A"}},
+		{"in stock", map[string]interface{}{FilterInStock: false}, []string{"E"}},
+		{"category", map[string]interface{}{FilterCategoryID: dbtest.SmartphonesCategoryID}, []string{"B", "D", "A", "C", "E"}},
+	}
+	for _, tt := range filters {
+		t.Run(tt.name, func(t *testing.T) {
+			params := byPrice
+			params.Filters = tt.filters
+			page, err := repo.List(ctx, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := productNames(page.Items); !slices.Equal(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+			if page.Total != len(tt.want) {
+				t.Errorf("total = %d, want %d", page.Total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestPostgresProductRepository_ListCursor(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	createPricedProducts(t, db)
+
+	t.Run("walk", func(t *testing.T) {
+		params := ListParams{SortBy: SortByPrice, SortOrder: SortAsc, Limit: 2}
+
+		var pages [][]string
+		var last *PaginatedResult[domain.ProductWithOffers]
+		for {
+			page, err := repo.List(ctx, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != 0 || page.PerPage != 2 {
+				t.Errorf("total = %d, perPage = %d, want 0 and 2 in cursor mode", page.Total, page.PerPage)
+			}
+			pages = append(pages, productNames(page.Items))
+			last = page
+			if page.NextCursor == nil {
+				break
+			}
+			params.Cursor = *page.NextCursor
+		}
+		want := [][]string{{"B", "D"}, {"A", "C"}, {"E"}}
+		if !slices.EqualFunc(pages, want, slices.Equal[[]string]) {
+			t.Fatalf("pages = %v, want %v", pages, want)
+		}
+
+		if last.PrevCursor == nil {
+			t.Fatal("last page has no prevCursor")
+		}
+		params.Cursor = *last.PrevCursor
+		prev, err := repo.List(ctx, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := productNames(prev.Items); !slices.Equal(got, []string{"A", "C"}) {
+			t.Errorf("previous page = %v, want [A C]", got)
+		}
+		if prev.NextCursor == nil || prev.PrevCursor == nil {
+			t.Errorf("middle page cursors = %v, %v, want both", prev.NextCursor, prev.PrevCursor)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := repo.List(ctx, ListParams{Cursor: "not a cursor"})
+		assertError(t, err, apperr.ErrValidation, apperr.CodeValidationFailed, "cursor")
+
+		first, err := repo.List(ctx, ListParams{SortBy: SortByName, Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = repo.List(ctx, ListParams{SortBy: SortByPrice, Cursor: *first.NextCursor})
+		assertError(t, err, apperr.ErrValidation, apperr.CodeValidationFailed, "cursor")
+	})
+}
+
+func TestPostgresProductRepository_ListAttributes(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	for name, attrs := range map[string]map[string]interface{}{
+		"Small": {"ram_gb": 4, "5g": false},
+		"Big":   {"ram_gb": 12, "5g": true},
+		"Text":  {"ram_gb": "lots", "5g": true},
+	} {
+		_, err := repo.Create(ctx, &domain.CreateProductRequest{
+			CategoryID: dbtest.SmartphonesCategoryID, Name: name, Brand: "Acme", Model: name,
+			Attributes: attrs, Source: "manual",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filters []domain.AttributeFilter
+		want    []string
+	}{
+		{"equality", []domain.AttributeFilter{{Attribute: "5g", Operator: domain.AttributeOpEq, Value: true}}, []string{"Big", "Text"}},
+		// Non-numeric values never satisfy a range
+		{"range", []domain.AttributeFilter{{Attribute: "ram_gb", Operator: domain.AttributeOpGte, Value: 8.0}}, []string{"Big"}},
+		{"combined", []domain.AttributeFilter{
+			{Attribute: "ram_gb", Operator: domain.AttributeOpLt, Value: 8.0},
+			{Attribute: "5g", Operator: domain.AttributeOpEq, Value: false},
+		}, []string{"Small"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.List(ctx, ListParams{
+				SortBy:  SortByName,
+				Filters: map[string]interface{}{FilterAttributes: tt.filters},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := productNames(page.Items); !slices.Equal(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresProductRepository_Search(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	ean := "4006381333931"
+	galaxy, err := repo.Create(ctx, &domain.CreateProductRequest{
+		CategoryID: dbtest.SmartphonesCategoryID, Name: "Galaxy S24", Brand: "Samsung", Model: "SM-S921",
+		EAN: &ean, Source: "manual",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createProduct(t, db, "Apple", "iPhone 15")
+
+	t.Run("fuzzy", func(t *testing.T) {
+		result, err := repo.Search(ctx, "galaxy", ListParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Items) != 1 || result.Items[0].ID != galaxy.ID {
+			t.Fatalf("hits = %v, want only Galaxy S24", result.Items)
+		}
+		hit := result.Items[0]
+		if hit.Score <= 0 || hit.Score >= 1 {
+			t.Errorf("score = %v, want between 0 and 1", hit.Score)
+		}
+		if hit.Highlights["name"] != "<mark>Galaxy</mark> S24" {
+			t.Errorf("name highlight = %q", hit.Highlights["name"])
+		}
+	})
+
+	t.Run("exact EAN", func(t *testing.T) {
+		result, err := repo.Search(ctx, ean, ListParams{Limit: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Items) == 0 || result.Items[0].ID != galaxy.ID || result.Items[0].Score != 1 {
+			t.Fatalf("hits = %v, want Galaxy S24 first with score 1", result.Items)
+		}
+		if result.Items[0].Highlights["ean"] != "<mark>"+ean+"</mark>" {
+			t.Errorf("ean highlight = %q", result.Items[0].Highlights["ean"])
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		result, err := repo.Search(ctx, "zzzzqqq", ListParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Items) != 0 || result.Total != 0 {
+			t.Errorf("hits = %v, total = %d, want none", result.Items, result.Total)
+		}
+	})
+}
+
+func TestPostgresProductRepository_Suggest(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+
+	galaxy := createProduct(t, db, "Samsung", "Galaxy S24")
+	createProduct(t, db, "Apple", "iPhone 15")
+
+	suggestions, err := repo.Suggest(context.Background(), "gal", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, sg := range suggestions {
+		if sg.Text == "iPhone 15" {
+			t.Errorf("unrelated suggestion %+v", sg)
+		}
+		if sg.Type == "product" && sg.Text == "Galaxy S24" && sg.ID != nil && *sg.ID == galaxy.ID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("suggestions = %+v, want the Galaxy S24 product", suggestions)
+	}
+
+	suggestions, err = repo.Suggest(context.Background(), "smart", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) == 0 || suggestions[0].Type != "category" || suggestions[0].Text != "Smartphones" {
+		t.Errorf("suggestions = %+v, want the Smartphones category first", suggestions)
+	}
+}
+
+func TestPostgresProductRepository_ListByCategoryAfter(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresProductRepository(db)
+	ctx := context.Background()
+
+	var ids []string
+	for _, name := range []string{"One", "Two", "Three"} {
+		ids = append(ids, createProduct(t, db, "Acme", name).ID)
+	}
+	slices.Sort(ids)
+
+	first, err := repo.ListByCategoryAfter(ctx, dbtest.SmartphonesCategoryID, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.ListByCategoryAfter(ctx, dbtest.SmartphonesCategoryID, first[len(first)-1].ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range append(first, second...) {
+		got = append(got, p.ID)
+	}
+	if len(first) != 2 || !slices.Equal(got, ids) {
+		t.Errorf("batches = %v, want %v in batches of 2", got, ids)
+	}
+}
+
+func productNames(items []domain.ProductWithOffers) []string {
+	names := []string{}
+	for _, p := range items {
+		names = append(names, p.Name)
+	}
+	return names
+}
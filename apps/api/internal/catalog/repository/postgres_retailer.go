@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// retailerColumns is the column list used to scan a domain.Retailer (table alias "r")
+const retailerColumns = `r.id, r.name, r.slug, r.website_url, r.logo_url,
+	r.affiliate_network, r.affiliate_id, r.affiliate_url_template,
+	COALESCE(r.rate_limit_ms, 2000), COALESCE(r.anti_bot_level, 'medium'),
+	COALESCE(r.active, true), COALESCE(r.priority, 0), r.freshness_window_hours,
+	COALESCE(r.created_at, NOW()), COALESCE(r.updated_at, NOW())`
+
+// retailerAuditColumns is the column list used to scan a domain.RetailerAuditEntry (table alias "a")
+const retailerAuditColumns = `a.id, a.retailer_id, a.action, a.changes, a.actor, a.request_id, a.created_at`
+
+// retailerAuditKeyset orders audit entries newest first
+var retailerAuditKeyset = keyset{
+	sort:    "createdAt:desc",
+	keyExpr: "a.created_at",
+	keyType: "timestamptz",
+	idExpr:  "a.id",
+	idType:  "bigint",
+	desc:    true,
+}
+
+// PostgresRetailerRepository implements RetailerRepository on top of pgx
+type PostgresRetailerRepository struct {
+	db *database.DB
+}
+
+// NewPostgresRetailerRepository creates a new Postgres-backed retailer repository
+func NewPostgresRetailerRepository(db *database.DB) *PostgresRetailerRepository {
+	return &PostgresRetailerRepository{db: db}
+}
+
+// GetByID retrieves a retailer by ID
+func (r *PostgresRetailerRepository) GetByID(ctx context.Context, id string) (*domain.Retailer, error) {
+	return r.get(ctx, "r.id = $1", id)
+}
+
+// GetBySlug retrieves a retailer by slug
+func (r *PostgresRetailerRepository) GetBySlug(ctx context.Context, slug string) (*domain.Retailer, error) {
+	return r.get(ctx, "r.slug = $1", slug)
+}
+
+// List retrieves a paginated list of retailers, highest priority first
+func (r *PostgresRetailerRepository) List(ctx context.Context, params ListParams) (*PaginatedResult[domain.Retailer], error) {
+	page, perPage, offset := normalizePage(params)
+
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM retailers`).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	items, err := r.query(ctx, `
+		SELECT `+retailerColumns+`
+		FROM retailers r
+		ORDER BY COALESCE(r.priority, 0) DESC, r.name
+		LIMIT $1 OFFSET $2`, perPage, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaginatedResult(items, page, perPage, total), nil
+}
+
+// GetActive returns all active retailers, highest priority first
+func (r *PostgresRetailerRepository) GetActive(ctx context.Context) ([]domain.Retailer, error) {
+	return r.query(ctx, `
+		SELECT `+retailerColumns+`
+		FROM retailers r
+		WHERE COALESCE(r.active, true)
+		ORDER BY COALESCE(r.priority, 0) DESC, r.name`)
+}
+
+// Create inserts a retailer and records it in the audit log. Unset scraping
+// and status fields take the column defaults.
+func (r *PostgresRetailerRepository) Create(ctx context.Context, req *domain.CreateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var slug string
+	if req.Slug != nil {
+		slug = *req.Slug
+	} else {
+		base := Slugify(req.Name)
+		if base == "" {
+			base = "retailer"
+		}
+		if slug, err = uniqueSlug(ctx, tx, "retailers", base); err != nil {
+			return nil, err
+		}
+	}
+
+	var ret domain.Retailer
+	err = scanRetailer(tx.QueryRow(ctx, `
+		INSERT INTO retailers AS r (
+			id, name, slug, website_url, logo_url,
+			affiliate_network, affiliate_id, affiliate_url_template,
+			rate_limit_ms, anti_bot_level, active, priority, freshness_window_hours
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+			COALESCE($9, 2000), COALESCE($10, 'medium'), COALESCE($11, true), COALESCE($12, 0), $13)
+		RETURNING `+retailerColumns,
+		req.ID, req.Name, slug, req.WebsiteURL, req.LogoURL,
+		req.AffiliateNetwork, req.AffiliateID, req.AffiliateURLTemplate,
+		req.RateLimitMs, req.AntiBotLevel, req.Active, req.Priority, req.FreshnessWindowHours,
+	), &ret)
+	if err != nil {
+		return nil, translateError(err, "retailer")
+	}
+
+	if err := recordRetailerAudit(ctx, tx, ret.ID, domain.AuditActionCreate, nil, &ret, audit); err != nil {
+		return nil, err
+	}
+	return &ret, tx.Commit(ctx)
+}
+
+// Update applies the non-nil fields of req and records the changed values in
+// the audit log. An update that changes nothing is not recorded.
+func (r *PostgresRetailerRepository) Update(ctx context.Context, id string, req *domain.UpdateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var before domain.Retailer
+	err = scanRetailer(tx.QueryRow(ctx,
+		`SELECT `+retailerColumns+` FROM retailers r WHERE r.id = $1 FOR UPDATE`, id), &before)
+	if err != nil {
+		return nil, translateLookupError(err, "retailer")
+	}
+
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Slug != nil {
+		set("slug", *req.Slug)
+	}
+	if req.WebsiteURL != nil {
+		set("website_url", *req.WebsiteURL)
+	}
+	if req.LogoURL != nil {
+		set("logo_url", *req.LogoURL)
+	}
+	if req.AffiliateNetwork != nil {
+		set("affiliate_network", *req.AffiliateNetwork)
+	}
+	if req.AffiliateID != nil {
+		set("affiliate_id", *req.AffiliateID)
+	}
+	if req.AffiliateURLTemplate != nil {
+		set("affiliate_url_template", *req.AffiliateURLTemplate)
+	}
+	if req.RateLimitMs != nil {
+		set("rate_limit_ms", *req.RateLimitMs)
+	}
+	if req.AntiBotLevel != nil {
+		set("anti_bot_level", *req.AntiBotLevel)
+	}
+	if req.Active != nil {
+		set("active", *req.Active)
+	}
+	if req.Priority != nil {
+		set("priority", *req.Priority)
+	}
+	if req.FreshnessWindowHours != nil {
+		// 0 clears the override
+		var hours *int
+		if *req.FreshnessWindowHours > 0 {
+			hours = req.FreshnessWindowHours
+		}
+		set("freshness_window_hours", hours)
+	}
+	if len(sets) == 0 {
+		return &before, nil
+	}
+
+	var after domain.Retailer
+	args = append(args, id)
+	err = scanRetailer(tx.QueryRow(ctx, fmt.Sprintf(
+		`UPDATE retailers AS r SET %s WHERE r.id = $%d RETURNING %s`,
+		strings.Join(sets, ", "), len(args), retailerColumns), args...), &after)
+	if err != nil {
+		return nil, translateLookupError(err, "retailer")
+	}
+
+	if err := recordRetailerAudit(ctx, tx, id, domain.AuditActionUpdate, &before, &after, audit); err != nil {
+		return nil, err
+	}
+	return &after, tx.Commit(ctx)
+}
+
+// Delete removes a retailer that has no offers and records its last state in
+// the audit log. Retailers with offers should be deactivated instead.
+func (r *PostgresRetailerRepository) Delete(ctx context.Context, id string, audit domain.AuditInfo) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var before domain.Retailer
+	err = scanRetailer(tx.QueryRow(ctx,
+		`SELECT `+retailerColumns+` FROM retailers r WHERE r.id = $1 FOR UPDATE`, id), &before)
+	if err != nil {
+		return translateLookupError(err, "retailer")
+	}
+
+	var offers int
+	if err := tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM offers WHERE retailer_id = $1`, id).Scan(&offers); err != nil {
+		return err
+	}
+	if offers > 0 {
+		derr := apperr.NewInUseError("retailer")
+		derr.Message = fmt.Sprintf("retailer still has %d offers; deactivate it instead", offers)
+		return derr
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM retailers WHERE id = $1`, id); err != nil {
+		return translateDeleteError(err, "retailer")
+	}
+	if err := recordRetailerAudit(ctx, tx, id, domain.AuditActionDelete, &before, nil, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListAudit returns the audit log of a retailer, newest first. Entries are
+// kept after the retailer is deleted.
+func (r *PostgresRetailerRepository) ListAudit(ctx context.Context, id string, params ListParams) (*PaginatedResult[domain.RetailerAuditEntry], error) {
+	q := &listQuery{}
+	q.where("a.retailer_id = " + q.arg(id))
+
+	return listPage(ctx, r.db, q, params, listing[domain.RetailerAuditEntry]{
+		from:     "retailer_audit_log a",
+		columns:  retailerAuditColumns,
+		resource: "retailer",
+		keyset:   retailerAuditKeyset,
+		scan:     scanRetailerAudit,
+	})
+}
+
+func (r *PostgresRetailerRepository) get(ctx context.Context, where string, arg any) (*domain.Retailer, error) {
+	var ret domain.Retailer
+	err := scanRetailer(r.db.Pool.QueryRow(ctx,
+		`SELECT `+retailerColumns+` FROM retailers r WHERE `+where, arg,
+	), &ret)
+	if err != nil {
+		return nil, translateLookupError(err, "retailer")
+	}
+	return &ret, nil
+}
+
+func (r *PostgresRetailerRepository) query(ctx context.Context, sql string, args ...any) ([]domain.Retailer, error) {
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	retailers := []domain.Retailer{}
+	for rows.Next() {
+		var ret domain.Retailer
+		if err := scanRetailer(rows, &ret); err != nil {
+			return nil, err
+		}
+		retailers = append(retailers, ret)
+	}
+	return retailers, rows.Err()
+}
+
+func scanRetailer(row scanner, r *domain.Retailer) error {
+	return row.Scan(
+		&r.ID, &r.Name, &r.Slug, &r.WebsiteURL, &r.LogoURL,
+		&r.AffiliateNetwork, &r.AffiliateID, &r.AffiliateURLTemplate,
+		&r.RateLimitMs, &r.AntiBotLevel,
+		&r.Active, &r.Priority, &r.FreshnessWindowHours,
+		&r.CreatedAt, &r.UpdatedAt,
+	)
+}
+
+func scanRetailerAudit(row scanner, e *domain.RetailerAuditEntry, extra ...any) error {
+	return row.Scan(append([]any{
+		&e.ID, &e.RetailerID, &e.Action, &e.Changes, &e.Actor, &e.RequestID, &e.CreatedAt,
+	}, extra...)...)
+}
+
+// Compile-time interface check
+var _ RetailerRepository = (*PostgresRetailerRepository)(nil)
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+var testAudit = domain.AuditInfo{Actor: "admin", RequestID: "req-1"}
+
+func TestPostgresRetailerRepository_Read(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRetailerRepository(db)
+	ctx := context.Background()
+
+	byID, err := repo.GetByID(ctx, "fnac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySlug, err := repo.GetBySlug(ctx, "amazon-fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.Name != "Fnac" || bySlug.ID != "amazon_fr" {
+		t.Errorf("got %s and %s, want Fnac and amazon_fr", byID.Name, bySlug.ID)
+	}
+	_, err = repo.GetByID(ctx, "nope")
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	page, err := repo.List(ctx, ListParams{Page: 2, PerPage: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Highest priority first: amazon_fr, fnac, darty, boulanger | cdiscount, ldlc
+	if got := retailerIDs(page.Items); !slices.Equal(got, []string{"cdiscount", "ldlc"}) {
+		t.Errorf("page 2 = %v, want [cdiscount ldlc]", got)
+	}
+	if page.Total != 6 || page.TotalPages != 2 {
+		t.Errorf("total = %d, totalPages = %d, want 6 and 2", page.Total, page.TotalPages)
+	}
+
+	dbtest.Exec(t, db, `UPDATE retailers SET active = false WHERE id IN ('fnac', 'ldlc')`)
+	active, err := repo.GetActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := retailerIDs(active); !slices.Equal(got, []string{"amazon_fr", "darty", "boulanger", "cdiscount"}) {
+		t.Errorf("active = %v", got)
+	}
+}
+
+func TestPostgresRetailerRepository_Write(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRetailerRepository(db)
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, &domain.CreateRetailerRequest{
+		ID:         "rue_du_commerce",
+		Name:       "Rue du Commerce",
+		WebsiteURL: "https://www.rueducommerce.fr",
+	}, testAudit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Slug != "rue-du-commerce" || created.RateLimitMs != 2000 || created.AntiBotLevel != "medium" ||
+		!created.Active || created.Priority != 0 || created.FreshnessWindowHours != nil {
+		t.Errorf("created = %+v, want the defaults", created)
+	}
+
+	_, err = repo.Create(ctx, &domain.CreateRetailerRequest{ID: "fnac", Name: "Fnac 2", WebsiteURL: "https://fnac.example"}, testAudit)
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "id")
+	_, err = repo.Create(ctx, &domain.CreateRetailerRequest{ID: "fnac_2", Name: "Fnac 2", Slug: ptr("fnac"), WebsiteURL: "https://fnac.example"}, testAudit)
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "slug")
+
+	updated, err := repo.Update(ctx, created.ID, &domain.UpdateRetailerRequest{
+		Priority:             ptr(40),
+		FreshnessWindowHours: ptr(12),
+	}, testAudit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Priority != 40 || updated.FreshnessWindowHours == nil || *updated.FreshnessWindowHours != 12 {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	// Zero resets the window to the one derived from rate limit and priority
+	reset, err := repo.Update(ctx, created.ID, &domain.UpdateRetailerRequest{FreshnessWindowHours: ptr(0)}, testAudit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.FreshnessWindowHours != nil {
+		t.Errorf("freshnessWindowHours = %d, want unset", *reset.FreshnessWindowHours)
+	}
+
+	// Setting a field to its current value is not a change
+	if _, err := repo.Update(ctx, created.ID, &domain.UpdateRetailerRequest{Priority: ptr(40)}, testAudit); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Update(ctx, "nope", &domain.UpdateRetailerRequest{Priority: ptr(1)}, testAudit)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	insertOffer(t, db, createProduct(t, db, "Acme", "Phone").ID, nil, "fnac", 100)
+	err = repo.Delete(ctx, "fnac", testAudit)
+	assertError(t, err, apperr.ErrConflict, apperr.CodeInUse, "")
+
+	if err := repo.Delete(ctx, created.ID, domain.AuditInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetByID(ctx, created.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	err = repo.Delete(ctx, created.ID, testAudit)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	t.Run("audit", func(t *testing.T) {
+		result, err := repo.ListAudit(ctx, created.ID, ListParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actions []string
+		for _, e := range result.Items {
+			actions = append(actions, e.Action)
+		}
+		// Newest first; the no-op update and the failed writes left no entry
+		want := []string{domain.AuditActionDelete, domain.AuditActionUpdate, domain.AuditActionUpdate, domain.AuditActionCreate}
+		if !slices.Equal(actions, want) {
+			t.Fatalf("actions = %v, want %v", actions, want)
+		}
+
+		deleted, reset, update, create := result.Items[0], result.Items[1], result.Items[2], result.Items[3]
+		if deleted.Actor != nil || deleted.RequestID != nil {
+			t.Errorf("anonymous delete recorded actor %v, request %v", deleted.Actor, deleted.RequestID)
+		}
+		if create.Actor == nil || *create.Actor != "admin" || create.RequestID == nil || *create.RequestID != "req-1" {
+			t.Errorf("create entry = %+v, want actor admin and request req-1", create)
+		}
+		if create.Changes["name"].To != "Rue du Commerce" || create.Changes["name"].From != nil {
+			t.Errorf("create changes = %+v", create.Changes)
+		}
+		if len(update.Changes) != 2 || update.Changes["priority"].From != 0.0 || update.Changes["priority"].To != 40.0 {
+			t.Errorf("update changes = %+v, want priority and freshnessWindowHours", update.Changes)
+		}
+		if c, ok := reset.Changes["freshnessWindowHours"]; !ok || len(reset.Changes) != 1 || c.From != 12.0 || c.To != nil {
+			t.Errorf("reset changes = %+v", reset.Changes)
+		}
+		if deleted.Changes["id"].From != created.ID || deleted.Changes["id"].To != nil {
+			t.Errorf("delete changes = %+v", deleted.Changes)
+		}
+
+		var pages [][]string
+		params := ListParams{Limit: 3}
+		for {
+			page, err := repo.ListAudit(ctx, created.ID, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var page1 []string
+			for _, e := range page.Items {
+				page1 = append(page1, e.Action)
+			}
+			pages = append(pages, page1)
+			if page.NextCursor == nil {
+				break
+			}
+			params.Cursor = *page.NextCursor
+		}
+		if len(pages) != 2 || !slices.Equal(slices.Concat(pages...), want) {
+			t.Errorf("cursor pages = %v, want %v in pages of 3", pages, want)
+		}
+	})
+}
+
+func retailerIDs(retailers []domain.Retailer) []string {
+	ids := []string{}
+	for _, r := range retailers {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
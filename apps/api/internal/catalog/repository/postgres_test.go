@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+// missingID is a well-formed UUID no record uses
+const missingID = "00000000-0000-0000-0000-000000000000"
+
+func createProduct(t *testing.T, db *database.DB, brand, name string) *domain.Product {
+	t.Helper()
+	p, err := NewPostgresProductRepository(db).Create(context.Background(), &domain.CreateProductRequest{
+		CategoryID: dbtest.SmartphonesCategoryID,
+		Name:       name,
+		Brand:      brand,
+		Model:      name,
+		Source:     "manual",
+	})
+	if err != nil {
+		t.Fatalf("create product %s %s: %v", brand, name, err)
+	}
+	return p
+}
+
+func createVariant(t *testing.T, db *database.DB, productID, sku string, color *string, storageGB *int) *domain.Variant {
+	t.Helper()
+	v, err := NewPostgresVariantRepository(db).Create(context.Background(), &domain.CreateVariantRequest{
+		ProductID: productID,
+		SKU:       sku,
+		Color:     color,
+		StorageGB: storageGB,
+	})
+	if err != nil {
+		t.Fatalf("create variant %s: %v", sku, err)
+	}
+	return v
+}
+
+// insertOffer adds a fresh in-stock offer and returns its ID
+func insertOffer(t *testing.T, db *database.DB, productID string, variantID *string, retailerID string, price float64) string {
+	t.Helper()
+	var id string
+	err := db.Pool.QueryRow(context.Background(), `
+		INSERT INTO offers (product_id, variant_id, retailer_id, price, url)
+		VALUES ($1, $2, $3, $4, 'https://shop.example/' || $3)
+		RETURNING id`, productID, variantID, retailerID, price).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert offer: %v", err)
+	}
+	return id
+}
+
+// assertError checks that err is a *apperr.Error of the given kind, code and field
+func assertError(t *testing.T, err error, kind error, code, field string) {
+	t.Helper()
+	var derr *apperr.Error
+	if !errors.As(err, &derr) {
+		t.Fatalf("err = %v, want a *apperr.Error", err)
+	}
+	if !errors.Is(err, kind) || derr.Code != code || derr.Field != field {
+		t.Errorf("err = %v (code %q, field %q), want %v (code %q, field %q)",
+			err, derr.Code, derr.Field, kind, code, field)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestTranslateForeignKeyErrors(t *testing.T) {
+	// The same constraint and table on both sides: only the operation tells
+	// a dangling parentId from a parent that still has children
+	selfRef := &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "categories_parent_id_fkey", TableName: "categories"}
+	offerRef := &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "offers_product_id_fkey", TableName: "offers"}
+	unknown := &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "affiliate_clicks_offer_id_fkey", TableName: "affiliate_clicks"}
+
+	tests := []struct {
+		name      string
+		translate func(error, string) error
+		err       error
+		resource  string
+		kind      error
+		code      string
+		field     string
+	}{
+		{"write self-reference", translateError, selfRef, "category", apperr.ErrInvalidReference, apperr.CodeInvalidReference, "parentId"},
+		{"delete self-reference", translateDeleteError, selfRef, "category", apperr.ErrConflict, apperr.CodeInUse, ""},
+		{"write reference", translateError, offerRef, "offer", apperr.ErrInvalidReference, apperr.CodeInvalidReference, "productId"},
+		{"delete referenced", translateDeleteError, offerRef, "product", apperr.ErrConflict, apperr.CodeInUse, ""},
+		{"delete referenced by unmapped table", translateDeleteError, unknown, "offer", apperr.ErrConflict, apperr.CodeInUse, ""},
+		{"delete with malformed ID", translateDeleteError, &pgconn.PgError{Code: pgInvalidTextRepresentation}, "product", apperr.ErrNotFound, apperr.CodeNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.translate(tt.err, tt.resource)
+			assertError(t, err, tt.kind, tt.code, tt.field)
+			if tt.code != apperr.CodeNotFound && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want it to wrap the driver error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// variantColumns is the column list used to scan a domain.Variant (table alias "v")
+const variantColumns = `v.id, v.product_id, v.sku, v.ean, v.color, v.color_hex,
+	v.storage_gb, v.ram_gb, COALESCE(v.attributes, '{}'::jsonb), v.image_url,
+	v.msrp, COALESCE(v.currency, 'EUR'), COALESCE(v.active, true),
+	COALESCE(v.created_at, NOW()), COALESCE(v.updated_at, NOW())`
+
+// variantOrder lists variants by color, then storage and RAM, smallest first
+const variantOrder = `ORDER BY v.color NULLS LAST, v.storage_gb NULLS LAST, v.ram_gb NULLS LAST, v.sku`
+
+// PostgresVariantRepository implements VariantRepository on top of pgx
+type PostgresVariantRepository struct {
+	db *database.DB
+}
+
+// NewPostgresVariantRepository creates a new Postgres-backed variant repository
+func NewPostgresVariantRepository(db *database.DB) *PostgresVariantRepository {
+	return &PostgresVariantRepository{db: db}
+}
+
+// GetByID retrieves a variant of a product
+func (r *PostgresVariantRepository) GetByID(ctx context.Context, productID, id string) (*domain.Variant, error) {
+	var v domain.Variant
+	err := scanVariant(r.db.Pool.QueryRow(ctx, `
+		SELECT `+variantColumns+`
+		FROM variants v
+		WHERE v.id = $1 AND v.product_id = $2`, id, productID), &v)
+	if err != nil {
+		return nil, translateLookupError(err, "variant")
+	}
+	return &v, nil
+}
+
+// ListByProductID returns the variants of a product
+func (r *PostgresVariantRepository) ListByProductID(ctx context.Context, productID string) ([]domain.Variant, error) {
+	variants, err := variantsByProduct(ctx, r.db, productID)
+	if err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+	if len(variants) > 0 {
+		return variants, nil
+	}
+
+	// Tell a product without variants from a missing product
+	var exists bool
+	if err := r.db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID).Scan(&exists); err != nil {
+		return nil, translateLookupError(err, "product")
+	}
+	if !exists {
+		return nil, apperr.NewNotFoundError("product")
+	}
+	return variants, nil
+}
+
+// Create inserts a variant of req.ProductID
+func (r *PostgresVariantRepository) Create(ctx context.Context, req *domain.CreateVariantRequest) (*domain.Variant, error) {
+	attributes := req.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	var v domain.Variant
+	err := scanVariant(r.db.Pool.QueryRow(ctx, `
+		INSERT INTO variants AS v (
+			product_id, sku, ean, color, color_hex, storage_gb, ram_gb,
+			attributes, image_url, msrp
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+variantColumns,
+		req.ProductID, req.SKU, req.EAN, req.Color, req.ColorHex, req.StorageGB, req.RAMGB,
+		attributes, req.ImageURL, req.MSRP,
+	), &v)
+	if err != nil {
+		err = translateError(err, "variant")
+		// The product comes from the URL: a missing one is not a bad field
+		if errors.Is(err, apperr.ErrInvalidReference) {
+			return nil, apperr.NewNotFoundError("product")
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Update applies the non-nil fields of req to a variant of a product
+func (r *PostgresVariantRepository) Update(ctx context.Context, productID, id string, req *domain.UpdateVariantRequest) (*domain.Variant, error) {
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.SKU != nil {
+		set("sku", *req.SKU)
+	}
+	if req.EAN != nil {
+		set("ean", *req.EAN)
+	}
+	if req.Color != nil {
+		set("color", *req.Color)
+	}
+	if req.ColorHex != nil {
+		set("color_hex", *req.ColorHex)
+	}
+	if req.StorageGB != nil {
+		set("storage_gb", *req.StorageGB)
+	}
+	if req.RAMGB != nil {
+		set("ram_gb", *req.RAMGB)
+	}
+	if req.Attributes != nil {
+		set("attributes", req.Attributes)
+	}
+	if req.ImageURL != nil {
+		set("image_url", *req.ImageURL)
+	}
+	if req.MSRP != nil {
+		set("msrp", *req.MSRP)
+	}
+	if req.Active != nil {
+		set("active", *req.Active)
+	}
+
+	if len(sets) == 0 {
+		return r.GetByID(ctx, productID, id)
+	}
+
+	args = append(args, id, productID)
+	var v domain.Variant
+	err := scanVariant(r.db.Pool.QueryRow(ctx, fmt.Sprintf(
+		`UPDATE variants AS v SET %s WHERE v.id = $%d AND v.product_id = $%d RETURNING %s`,
+		strings.Join(sets, ", "), len(args)-1, len(args), variantColumns), args...), &v)
+	if err != nil {
+		return nil, translateLookupError(err, "variant")
+	}
+	return &v, nil
+}
+
+// Delete removes a variant of a product (its offers and history cascade)
+func (r *PostgresVariantRepository) Delete(ctx context.Context, productID, id string) error {
+	tag, err := r.db.Pool.Exec(ctx,
+		`DELETE FROM variants WHERE id = $1 AND product_id = $2`, id, productID)
+	if err != nil {
+		return translateDeleteError(err, "variant")
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NewNotFoundError("variant")
+	}
+	return nil
+}
+
+// variantsByProduct returns the variants of a product in matrix order
+func variantsByProduct(ctx context.Context, db *database.DB, productID string) ([]domain.Variant, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+variantColumns+`
+		FROM variants v
+		WHERE v.product_id = $1
+		`+variantOrder, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []domain.Variant{}
+	for rows.Next() {
+		var v domain.Variant
+		if err := scanVariant(rows, &v); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+func scanVariant(row scanner, v *domain.Variant) error {
+	return row.Scan(
+		&v.ID, &v.ProductID, &v.SKU, &v.EAN, &v.Color, &v.ColorHex,
+		&v.StorageGB, &v.RAMGB, &v.Attributes, &v.ImageURL,
+		&v.MSRP, &v.Currency, &v.Active,
+		&v.CreatedAt, &v.UpdatedAt,
+	)
+}
+
+// Compile-time interface check
+var _ VariantRepository = (*PostgresVariantRepository)(nil)
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+func TestPostgresVariantRepository_CreateAndList(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresVariantRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	createVariant(t, db, p.ID, "P-WHT-256", ptr("white"), ptr(256))
+	createVariant(t, db, p.ID, "P-BLK-256", ptr("black"), ptr(256))
+	createVariant(t, db, p.ID, "P-BLK-128", ptr("black"), ptr(128))
+	createVariant(t, db, p.ID, "P-PLAIN", nil, nil)
+
+	variants, err := repo.ListByProductID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skus []string
+	for _, v := range variants {
+		skus = append(skus, v.SKU)
+	}
+	// Matrix order: color, then storage, unset values last
+	if want := []string{"P-BLK-128", "P-BLK-256", "P-WHT-256", "P-PLAIN"}; !slices.Equal(skus, want) {
+		t.Errorf("variants = %v, want %v", skus, want)
+	}
+
+	bare := createProduct(t, db, "Acme", "Bare")
+	variants, err = repo.ListByProductID(ctx, bare.ID)
+	if err != nil || variants == nil || len(variants) != 0 {
+		t.Errorf("variants of a product without any = %v, %v, want an empty list", variants, err)
+	}
+	for _, id := range []string{missingID, "not-a-uuid"} {
+		_, err = repo.ListByProductID(ctx, id)
+		assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	}
+
+	ean := "4006381333931"
+	_, err = repo.Create(ctx, &domain.CreateVariantRequest{ProductID: p.ID, SKU: "P-BLK-128"})
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "sku")
+	if _, err = repo.Create(ctx, &domain.CreateVariantRequest{ProductID: p.ID, SKU: "P-EAN", EAN: &ean}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Create(ctx, &domain.CreateVariantRequest{ProductID: bare.ID, SKU: "B-EAN", EAN: &ean})
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "ean")
+
+	// The same SKU is fine on another product
+	if _, err = repo.Create(ctx, &domain.CreateVariantRequest{ProductID: bare.ID, SKU: "P-BLK-128"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The product comes from the URL, so a missing one is not found
+	_, err = repo.Create(ctx, &domain.CreateVariantRequest{ProductID: missingID, SKU: "X"})
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+}
+
+func TestPostgresVariantRepository_GetUpdateDelete(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresVariantRepository(db)
+	ctx := context.Background()
+
+	p := createProduct(t, db, "Acme", "Phone")
+	other := createProduct(t, db, "Acme", "Other")
+	v := createVariant(t, db, p.ID, "P-BLK", ptr("black"), ptr(128))
+
+	got, err := repo.GetByID(ctx, p.ID, v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SKU != "P-BLK" || *got.Color != "black" || *got.StorageGB != 128 || !got.Active {
+		t.Errorf("variant = %+v", got)
+	}
+
+	updated, err := repo.Update(ctx, p.ID, v.ID, &domain.UpdateVariantRequest{
+		StorageGB: ptr(256),
+		MSRP:      ptr(899.99),
+		Active:    ptr(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updated.StorageGB != 256 || *updated.MSRP != 899.99 || updated.Active || *updated.Color != "black" {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	unchanged, err := repo.Update(ctx, p.ID, v.ID, &domain.UpdateVariantRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *unchanged.StorageGB != 256 {
+		t.Errorf("empty update returned %+v", unchanged)
+	}
+
+	// A variant is only reachable through its own product
+	_, err = repo.GetByID(ctx, other.ID, v.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	_, err = repo.Update(ctx, other.ID, v.ID, &domain.UpdateVariantRequest{Color: ptr("red")})
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	err = repo.Delete(ctx, other.ID, v.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+	_, err = repo.GetByID(ctx, p.ID, "not-a-uuid")
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	createVariant(t, db, p.ID, "P-WHT", ptr("white"), nil)
+	_, err = repo.Update(ctx, p.ID, v.ID, &domain.UpdateVariantRequest{SKU: ptr("P-WHT")})
+	assertError(t, err, apperr.ErrConflict, apperr.CodeConflict, "sku")
+
+	insertOffer(t, db, p.ID, &v.ID, "fnac", 800)
+	if err := repo.Delete(ctx, p.ID, v.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetByID(ctx, p.ID, v.ID)
+	assertError(t, err, apperr.ErrNotFound, apperr.CodeNotFound, "")
+
+	var offers int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM offers WHERE variant_id = $1`, v.ID).Scan(&offers); err != nil {
+		t.Fatal(err)
+	}
+	if offers != 0 {
+		t.Errorf("%d offers left for the deleted variant, want them cascaded", offers)
+	}
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+)
+
+// Relevance weights of the fuzzy matches. They add up to less than 1 so an
+// exact EAN/SKU match always ranks first.
+const (
+	searchNameWeight  = 0.55
+	searchBrandWeight = 0.25
+	searchModelWeight = 0.15
+)
+
+// searchMatch returns the predicate selecting products that match the search
+// term t (a query placeholder): an exact identifier, a trigram match on name,
+// brand or model, or a plain substring of the name. pattern is the matching
+// ILIKE pattern. All branches can use the trigram and EAN indexes.
+func searchMatch(t, pattern string) string {
+	return fmt.Sprintf(`(p.ean = %[1]s::text OR LOWER(p.sku) = LOWER(%[1]s::text)
+		OR p.name %% %[1]s::text OR %[1]s::text <%% p.name
+		OR %[1]s::text <%% p.brand OR %[1]s::text <%% p.model
+		OR p.name ILIKE %[2]s::text)`, t, pattern)
+}
+
+// searchScore returns the relevance expression for the search term t
+func searchScore(t string) string {
+	return fmt.Sprintf(`(CASE WHEN p.ean = %[1]s::text OR LOWER(p.sku) = LOWER(%[1]s::text) THEN 1
+		ELSE %[2]g * GREATEST(similarity(p.name, %[1]s::text), word_similarity(%[1]s::text, p.name))
+		   + %[3]g * word_similarity(%[1]s::text, p.brand)
+		   + %[4]g * word_similarity(%[1]s::text, p.model)
+		END)::float8`, t, searchNameWeight, searchBrandWeight, searchModelWeight)
+}
+
+// Suggestion list sizes
+const (
+	DefaultSuggestLimit = 8
+	MaxSuggestLimit     = 20
+)
+
+// suggestQuery returns product names, brands and categories that start with
+// the typed text $1 ($2: prefix pattern, $3: word-start pattern) or are a
+// close trigram match. Hits are ranked by similarity, boosted logarithmically
+// by popularity: offers for a product, active products for a brand or
+// category. $4 is the number of suggestions.
+const suggestQuery = `
+	WITH hits AS (
+		(SELECT 'product' AS kind, p.name AS text, p.id::text AS id, p.slug,
+		        word_similarity($1::text, p.name) AS sim,
+		        (SELECT COUNT(*) FROM offers o WHERE o.product_id = p.id) AS popularity
+		 FROM products p
+		 WHERE COALESCE(p.active, true)
+		   AND (p.name ILIKE $2::text OR p.name ILIKE $3::text OR $1::text <% p.name)
+		 ORDER BY sim DESC
+		 LIMIT $4)
+		UNION ALL
+		(SELECT 'brand', MIN(p.brand), NULL, NULL,
+		        MAX(word_similarity($1::text, p.brand)),
+		        COUNT(*)
+		 FROM products p
+		 WHERE COALESCE(p.active, true)
+		   AND (p.brand ILIKE $2::text OR p.brand ILIKE $3::text OR $1::text <% p.brand)
+		 GROUP BY LOWER(p.brand)
+		 ORDER BY 5 DESC
+		 LIMIT $4)
+		UNION ALL
+		(SELECT 'category', c.name, c.id::text, c.slug,
+		        word_similarity($1::text, c.name),
+		        (SELECT COUNT(*) FROM products p
+		         WHERE p.category_id = c.id AND COALESCE(p.active, true))
+		 FROM categories c
+		 WHERE COALESCE(c.active, true)
+		   AND (c.name ILIKE $2::text OR c.name ILIKE $3::text OR $1::text <% c.name)
+		 ORDER BY 5 DESC
+		 LIMIT $4)
+	)
+	SELECT kind, text, id, slug, (sim * (1 + LN(1 + popularity) / 10))::float8 AS score
+	FROM hits
+	ORDER BY score DESC, text
+	LIMIT $4`
+
+// relevanceKeyset orders search hits by score, best first
+func relevanceKeyset(score string) keyset {
+	return keyset{
+		sort:    "relevance:desc",
+		keyExpr: score,
+		keyType: "float8",
+		idExpr:  "p.id",
+		idType:  "uuid",
+		desc:    true,
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern returns an ILIKE pattern matching s anywhere in a string
+func likePattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// likePrefixPatterns returns ILIKE patterns matching s at the start of a
+// string and at the start of any later word
+func likePrefixPatterns(s string) (prefix, wordStart string) {
+	escaped := likeEscaper.Replace(s)
+	return escaped + "%", "% " + escaped + "%"
+}
+
+// searchHighlights returns the product fields containing a query term, with
+// every matching fragment wrapped in <mark> tags
+func searchHighlights(p *domain.Product, query string) map[string]string {
+	terms := strings.Fields(query)
+	highlights := make(map[string]string)
+
+	fields := map[string]string{"name": p.Name, "brand": p.Brand, "model": p.Model}
+	for field, text := range fields {
+		if h, ok := highlight(text, terms); ok {
+			highlights[field] = h
+		}
+	}
+	if p.EAN != nil && *p.EAN == query {
+		highlights["ean"] = "<mark>" + html.EscapeString(*p.EAN) + "</mark>"
+	}
+	if p.SKU != nil && strings.EqualFold(*p.SKU, query) {
+		highlights["sku"] = "<mark>" + html.EscapeString(*p.SKU) + "</mark>"
+	}
+	return highlights
+}
+
+// highlight marks the case-insensitive occurrences of terms in text and
+// HTML-escapes the rest. It reports false when no term occurs.
+func highlight(text string, terms []string) (string, bool) {
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(text); {
+		for _, term := range terms {
+			if n := foldPrefixLen(text[i:], term); n > 0 {
+				spans = append(spans, span{i, i + n})
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	// Merge overlapping matches so tags never nest
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start <= last.end {
+			last.end = max(last.end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	var b strings.Builder
+	prev := 0
+	for _, s := range merged {
+		b.WriteString(html.EscapeString(text[prev:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		prev = s.end
+	}
+	b.WriteString(html.EscapeString(text[prev:]))
+	return b.String(), true
+}
+
+// foldPrefixLen returns the byte length of the prefix of s that equals prefix
+// under Unicode case folding, or 0 if s does not start with prefix
+func foldPrefixLen(s, prefix string) int {
+	if prefix == "" {
+		return 0
+	}
+	n := 0
+	for _, pr := range prefix {
+		sr, size := utf8.DecodeRuneInString(s[n:])
+		if size == 0 || !strings.EqualFold(string(sr), string(pr)) {
+			return 0
+		}
+		n += size
+	}
+	return n
+}
+
+// roundScore trims a relevance score to 4 decimals for display
+func roundScore(score float64) float64 {
+	return math.Round(score*1e4) / 1e4
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// accentReplacer folds the accented characters commonly found in product
+// and category names down to plain ASCII.
+var accentReplacer = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"ç", "c",
+	"è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i",
+	"ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u",
+	"ý", "y", "ÿ", "y",
+	"æ", "ae", "œ", "oe", "ß", "ss",
+	"+", " plus ",
+)
+
+// Slugify converts a name into a URL-safe slug
+// e.g. "Galaxy S24+ 256 Go" -> "galaxy-s24-plus-256-go"
+func Slugify(s string) string {
+	s = accentReplacer.Replace(strings.ToLower(s))
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	dash := false
+	for _, r := range s {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// uniqueSlug returns base, or base suffixed with the first "-N" not yet used
+// in the slug column of table
+func uniqueSlug(ctx context.Context, db querier, table, base string) (string, error) {
+	rows, err := db.Query(ctx,
+		`SELECT slug FROM `+table+` WHERE slug = $1 OR slug LIKE $1 || '-%'`, base)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", err
+		}
+		taken[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if !taken[base] {
+		return base, nil
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", base, i)
+		if !taken[candidate] {
+			return candidate, nil
+		}
+	}
+}
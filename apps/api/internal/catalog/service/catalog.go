@@ -2,72 +2,360 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
 
 	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
 	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/cache"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// Category tree cache entry. It is dropped whenever a category or product
+// changes; the TTL only bounds staleness after changes made outside the API.
+const (
+	categoryTreeCacheKey = "catalog:category-tree"
+	categoryTreeCacheTTL = 10 * time.Minute
 )
 
 // CatalogService provides catalog business logic
 type CatalogService struct {
 	productRepo  repository.ProductRepository
+	variantRepo  repository.VariantRepository
 	offerRepo    repository.OfferRepository
 	categoryRepo repository.CategoryRepository
 	retailerRepo repository.RetailerRepository
+	cache        *cache.Client
+	schemas      *schemaMigrator
+	staleness    *stalenessSweeper
+
+	// unknownAttributes is the domain.UnknownAttributes* policy for product
+	// attributes the category schema does not declare
+	unknownAttributes string
 }
 
 // NewCatalogService creates a new catalog service
 func NewCatalogService(
 	productRepo repository.ProductRepository,
+	variantRepo repository.VariantRepository,
 	offerRepo repository.OfferRepository,
 	categoryRepo repository.CategoryRepository,
 	retailerRepo repository.RetailerRepository,
+	redis *cache.Client,
+	unknownAttributes string,
 ) *CatalogService {
+	if unknownAttributes != domain.UnknownAttributesQuarantine {
+		if unknownAttributes != domain.UnknownAttributesReject {
+			log.Warn().Str("policy", unknownAttributes).Msg("Unknown attributes policy not recognized, rejecting")
+		}
+		unknownAttributes = domain.UnknownAttributesReject
+	}
+
 	return &CatalogService{
 		productRepo:  productRepo,
+		variantRepo:  variantRepo,
 		offerRepo:    offerRepo,
 		categoryRepo: categoryRepo,
 		retailerRepo: retailerRepo,
+		cache:        redis,
+		schemas:      newSchemaMigrator(categoryRepo, productRepo),
+		staleness:    newStalenessSweeper(retailerRepo, offerRepo),
+
+		unknownAttributes: unknownAttributes,
 	}
 }
 
-// GetProduct retrieves a product by ID with offers
+// RunSchemaMigrations re-validates products against new attribute schema
+// versions in the background until ctx is cancelled
+func (s *CatalogService) RunSchemaMigrations(ctx context.Context) {
+	s.schemas.run(ctx)
+}
+
+// RunStalenessSweeper expires offers that outlived their retailer's freshness
+// window in the background until ctx is cancelled
+func (s *CatalogService) RunStalenessSweeper(ctx context.Context) {
+	s.staleness.run(ctx)
+}
+
+// GetProduct retrieves a product by ID with offers and deal score
 func (s *CatalogService) GetProduct(ctx context.Context, id string) (*domain.ProductWithOffers, error) {
-	return s.productRepo.GetByID(ctx, id)
+	p, err := s.productRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.attachDealScore(ctx, p)
+	return p, nil
 }
 
-// GetProductBySlug retrieves a product by slug with offers
+// GetProductBySlug retrieves a product by slug with offers and deal score
 func (s *CatalogService) GetProductBySlug(ctx context.Context, slug string) (*domain.ProductWithOffers, error) {
-	return s.productRepo.GetBySlug(ctx, slug)
+	p, err := s.productRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	s.attachDealScore(ctx, p)
+	return p, nil
+}
+
+// GetDealScore rates the current best price of a product, or of one of its
+// variants, against the price history of the variant it belongs to
+func (s *CatalogService) GetDealScore(ctx context.Context, productID string, variantID *string) (*domain.DealScore, error) {
+	var offers []domain.Offer
+	if variantID == nil {
+		p, err := s.productRepo.GetByID(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		offers = p.Offers
+	} else {
+		if _, err := s.variantRepo.GetByID(ctx, productID, *variantID); err != nil {
+			return nil, err
+		}
+		var err error
+		if offers, err = s.offerRepo.GetByProductID(ctx, productID, variantID); err != nil {
+			return nil, err
+		}
+	}
+	return s.dealScore(ctx, productID, variantID, offers)
+}
+
+// attachDealScore adds the deal score to a product detail. The score is an
+// extra: failing to compute it does not fail the request.
+func (s *CatalogService) attachDealScore(ctx context.Context, p *domain.ProductWithOffers) {
+	score, err := s.dealScore(ctx, p.ID, nil, p.Offers)
+	if err != nil {
+		log.Warn().Err(err).Str("product", p.ID).Msg("Failed to compute deal score")
+		return
+	}
+	p.DealScore = score
+}
+
+// dealScore rates the best of offers. Variants are priced apart, so without
+// variantID it rates the variant holding the best offer, against that
+// variant's own offers and history.
+func (s *CatalogService) dealScore(ctx context.Context, productID string, variantID *string, offers []domain.Offer) (*domain.DealScore, error) {
+	if variantID == nil {
+		if best := domain.BestOffer(offers); best != nil {
+			variantID = best.VariantID
+		}
+		offers = variantOffers(offers, variantID)
+	}
+
+	var current *float64
+	if best := domain.BestOffer(offers); best != nil {
+		current = &best.Price
+	}
+	windows, err := s.offerRepo.PriceStats(ctx, productID, variantID, current, domain.DealScoreWindows)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewDealScore(productID, variantID, offers, windows), nil
+}
+
+// variantOffers returns the offers of variantID, those not tied to a
+// variant when it is nil
+func variantOffers(offers []domain.Offer, variantID *string) []domain.Offer {
+	var matched []domain.Offer
+	for _, o := range offers {
+		if (o.VariantID == nil && variantID == nil) || (o.VariantID != nil && variantID != nil && *o.VariantID == *variantID) {
+			matched = append(matched, o)
+		}
+	}
+	return matched
 }
 
 // ListProducts retrieves a paginated list of products
 func (s *CatalogService) ListProducts(ctx context.Context, params repository.ListParams) (*repository.PaginatedResult[domain.ProductWithOffers], error) {
+	if err := s.resolveAttributeFilters(ctx, &params); err != nil {
+		return nil, err
+	}
 	return s.productRepo.List(ctx, params)
 }
 
-// SearchProducts searches for products by query
-func (s *CatalogService) SearchProducts(ctx context.Context, query string, params repository.ListParams) (*repository.PaginatedResult[domain.ProductWithOffers], error) {
+// SearchProducts searches for products by query, best matches first
+func (s *CatalogService) SearchProducts(ctx context.Context, query string, params repository.ListParams) (*repository.PaginatedResult[domain.ProductSearchHit], error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		err := apperr.NewValidationError("Search query must not be blank", nil)
+		err.Field = "q"
+		return nil, err
+	}
+	if err := s.resolveAttributeFilters(ctx, &params); err != nil {
+		return nil, err
+	}
 	return s.productRepo.Search(ctx, query, params)
 }
 
-// CreateProduct creates a new product
+// SuggestProducts returns type-ahead suggestions for a partially typed query
+func (s *CatalogService) SuggestProducts(ctx context.Context, query string, limit int) ([]domain.Suggestion, error) {
+	query = domain.NormalizeQuery(query)
+	if query == "" {
+		err := apperr.NewValidationError("Search query must not be blank", nil)
+		err.Field = "q"
+		return nil, err
+	}
+	if limit < 1 {
+		limit = repository.DefaultSuggestLimit
+	}
+	if limit > repository.MaxSuggestLimit {
+		limit = repository.MaxSuggestLimit
+	}
+	return s.productRepo.Suggest(ctx, query, limit)
+}
+
+// CreateProduct creates a new product. Its attributes are coerced to the
+// category's attribute schema first.
 func (s *CatalogService) CreateProduct(ctx context.Context, req *domain.CreateProductRequest) (*domain.Product, error) {
-	return s.productRepo.Create(ctx, req)
+	attrs, quarantined, err := s.normalizeAttributes(ctx, req.CategoryID, req.Attributes, nil)
+	if err != nil {
+		return nil, err
+	}
+	normalized := *req
+	normalized.Attributes, normalized.QuarantinedAttributes = attrs, quarantined
+
+	product, err := s.productRepo.Create(ctx, &normalized)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCategoryTree(ctx)
+	return product, nil
 }
 
-// UpdateProduct updates an existing product
+// UpdateProduct updates an existing product. New attributes, or the current
+// ones when the product changes category, are coerced to the schema of the
+// product's category.
 func (s *CatalogService) UpdateProduct(ctx context.Context, id string, req *domain.UpdateProductRequest) (*domain.Product, error) {
-	return s.productRepo.Update(ctx, id, req)
+	normalized := *req
+	if req.Attributes != nil || req.CategoryID != nil {
+		current, err := s.productRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		categoryID := current.CategoryID
+		if req.CategoryID != nil {
+			categoryID = *req.CategoryID
+		}
+		// Attributes replace the current ones, quarantined keys included;
+		// a category change alone re-checks what is stored
+		attrs, kept := req.Attributes, map[string]interface{}(nil)
+		if attrs == nil {
+			attrs, kept = current.Attributes, current.QuarantinedAttributes
+		}
+
+		attrs, quarantined, err := s.normalizeAttributes(ctx, categoryID, attrs, kept)
+		if err != nil {
+			return nil, err
+		}
+		normalized.Attributes, normalized.QuarantinedAttributes = attrs, quarantined
+	}
+
+	product, err := s.productRepo.Update(ctx, id, &normalized)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCategoryTree(ctx)
+	return product, nil
 }
 
 // DeleteProduct deletes a product
 func (s *CatalogService) DeleteProduct(ctx context.Context, id string) error {
-	return s.productRepo.Delete(ctx, id)
+	if err := s.productRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidateCategoryTree(ctx)
+	return nil
+}
+
+// GetOffers retrieves all offers for a product, or for one of its variants
+func (s *CatalogService) GetOffers(ctx context.Context, productID string, variantID *string) ([]domain.Offer, error) {
+	return s.offerRepo.GetByProductID(ctx, productID, variantID)
+}
+
+// ListOffers retrieves a page of offers for a product, or for one of its variants
+func (s *CatalogService) ListOffers(ctx context.Context, productID string, variantID *string, params repository.ListParams) (*repository.PaginatedResult[domain.Offer], error) {
+	return s.offerRepo.ListByProductID(ctx, productID, variantID, params)
+}
+
+// IngestOffers upserts a batch of scraped offers. Each offer is validated and
+// stored on its own, so the report lists an outcome per item.
+func (s *CatalogService) IngestOffers(ctx context.Context, offers []domain.CreateOfferRequest) *domain.OfferIngestReport {
+	report := &domain.OfferIngestReport{Results: make([]domain.OfferIngestResult, 0, len(offers))}
+	for i := range offers {
+		result := domain.OfferIngestResult{Index: i, Status: domain.OfferFailed}
+
+		if errs := validator.Validate(&offers[i]); errs != nil {
+			result.Error = apperr.NewValidationError("Offer validation failed", errs)
+			report.Add(result)
+			continue
+		}
+
+		offer := offers[i].Offer()
+		outcome, err := s.offerRepo.Upsert(ctx, offer)
+		if err != nil {
+			var derr *apperr.Error
+			if !errors.As(err, &derr) {
+				log.Error().Err(err).Int("index", i).Str("product", offer.ProductID).
+					Str("retailer", offer.RetailerID).Msg("Failed to ingest offer")
+				derr = &apperr.Error{Code: "internal_error", Message: "Internal server error"}
+			}
+			result.Error = derr
+			report.Add(result)
+			continue
+		}
+
+		result.Status = outcome
+		result.OfferID = &offer.ID
+		report.Add(result)
+	}
+	return report
+}
+
+// ListVariants retrieves the variants of a product
+func (s *CatalogService) ListVariants(ctx context.Context, productID string) ([]domain.Variant, error) {
+	return s.variantRepo.ListByProductID(ctx, productID)
+}
+
+// GetVariant retrieves a variant of a product
+func (s *CatalogService) GetVariant(ctx context.Context, productID, id string) (*domain.Variant, error) {
+	return s.variantRepo.GetByID(ctx, productID, id)
+}
+
+// CreateVariant creates a variant of a product
+func (s *CatalogService) CreateVariant(ctx context.Context, req *domain.CreateVariantRequest) (*domain.Variant, error) {
+	return s.variantRepo.Create(ctx, req)
+}
+
+// UpdateVariant updates a variant of a product
+func (s *CatalogService) UpdateVariant(ctx context.Context, productID, id string, req *domain.UpdateVariantRequest) (*domain.Variant, error) {
+	return s.variantRepo.Update(ctx, productID, id, req)
+}
+
+// DeleteVariant deletes a variant of a product along with its offers
+func (s *CatalogService) DeleteVariant(ctx context.Context, productID, id string) error {
+	return s.variantRepo.Delete(ctx, productID, id)
 }
 
-// GetOffers retrieves all offers for a product
-func (s *CatalogService) GetOffers(ctx context.Context, productID string) ([]domain.Offer, error) {
-	return s.offerRepo.GetByProductID(ctx, productID)
+// GetVariantMatrix lays out the variants of a product by color and storage,
+// each with its cheapest in-stock offer
+func (s *CatalogService) GetVariantMatrix(ctx context.Context, productID string) (*domain.VariantMatrix, error) {
+	variants, err := s.variantRepo.ListByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := s.offerRepo.GetByProductID(ctx, productID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewVariantMatrix(productID, variants, offers), nil
 }
 
 // GetPriceHistory retrieves price history for a product
@@ -75,6 +363,58 @@ func (s *CatalogService) GetPriceHistory(ctx context.Context, productID string,
 	return s.offerRepo.GetHistory(ctx, productID, retailerID)
 }
 
+// ListPriceHistory retrieves a page of price history for a product
+func (s *CatalogService) ListPriceHistory(ctx context.Context, productID string, retailerID *string, params repository.ListParams) (*repository.PaginatedResult[domain.PriceHistory], error) {
+	return s.offerRepo.ListHistory(ctx, productID, retailerID, params)
+}
+
+// AggregatePriceHistory returns a product's price history bucketed by
+// q.Interval. The range defaults to a span suited to the interval ending now.
+func (s *CatalogService) AggregatePriceHistory(ctx context.Context, productID string, q domain.PriceHistoryQuery) (*domain.PriceHistorySeries, error) {
+	if q.Interval == "" {
+		q.Interval = domain.HistoryIntervalDay
+	}
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = domain.DefaultHistoryFrom(q.To, q.Interval)
+	}
+
+	var errs validator.Errors
+	switch {
+	case !domain.ValidHistoryInterval(q.Interval):
+		errs = append(errs, validator.FieldError{
+			Field:   "interval",
+			Rule:    "oneof",
+			Message: "must be one of: " + strings.Join(domain.HistoryIntervals, ", "),
+		})
+	case !q.From.Before(q.To):
+		errs = append(errs, validator.FieldError{Field: "from", Rule: "ltfield", Message: "must be before to"})
+	case domain.HistoryBucketCount(q.From, q.To, q.Interval) > domain.MaxHistoryBuckets:
+		errs = append(errs, validator.FieldError{
+			Field:   "from",
+			Rule:    "max",
+			Message: "range spans more than " + strconv.Itoa(domain.MaxHistoryBuckets) + " " + q.Interval + " buckets",
+		})
+	}
+	if len(errs) > 0 {
+		return nil, apperr.NewValidationError("Invalid query parameters", errs)
+	}
+
+	series, err := s.offerRepo.AggregateHistory(ctx, productID, q)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.PriceHistorySeries{
+		ProductID: productID,
+		Interval:  q.Interval,
+		From:      q.From.UTC(),
+		To:        q.To.UTC(),
+		Series:    series,
+	}, nil
+}
+
 // GetCategory retrieves a category by ID
 func (s *CatalogService) GetCategory(ctx context.Context, id string) (*domain.Category, error) {
 	return s.categoryRepo.GetByID(ctx, id)
@@ -85,9 +425,116 @@ func (s *CatalogService) ListCategories(ctx context.Context, params repository.L
 	return s.categoryRepo.List(ctx, params)
 }
 
-// GetCategoryTree retrieves the category tree
+// CreateCategory creates a category
+func (s *CatalogService) CreateCategory(ctx context.Context, req *domain.CreateCategoryRequest) (*domain.Category, error) {
+	if err := validateSlug(req.Slug); err != nil {
+		return nil, err
+	}
+	if err := validateSchema(req.AttributeSchema); err != nil {
+		return nil, err
+	}
+	category, err := s.categoryRepo.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCategoryTree(ctx)
+	return category, nil
+}
+
+// UpdateCategory updates an existing category
+func (s *CatalogService) UpdateCategory(ctx context.Context, id string, req *domain.UpdateCategoryRequest) (*domain.Category, error) {
+	if err := validateSlug(req.Slug); err != nil {
+		return nil, err
+	}
+	category, err := s.categoryRepo.Update(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCategoryTree(ctx)
+	return category, nil
+}
+
+// MoveCategory re-parents a category within the tree
+func (s *CatalogService) MoveCategory(ctx context.Context, id string, req *domain.MoveCategoryRequest) (*domain.Category, error) {
+	category, err := s.categoryRepo.Move(ctx, id, req.ParentID, req.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCategoryTree(ctx)
+	return category, nil
+}
+
+// DeleteCategory deletes a category, moving its products to reassignTo if set
+func (s *CatalogService) DeleteCategory(ctx context.Context, id string, reassignTo *string) error {
+	if err := s.categoryRepo.Delete(ctx, id, reassignTo); err != nil {
+		return err
+	}
+	s.invalidateCategoryTree(ctx)
+	return nil
+}
+
+// GetAttributeSchema returns the current attribute schema version of a category
+func (s *CatalogService) GetAttributeSchema(ctx context.Context, categoryID string) (*domain.CategorySchemaVersion, error) {
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	return s.categoryRepo.GetSchemaVersion(ctx, category.ID, category.SchemaVersion)
+}
+
+// UpdateAttributeSchema stores a new attribute schema version and schedules
+// the re-validation of the category's products against it
+func (s *CatalogService) UpdateAttributeSchema(ctx context.Context, categoryID string, req *domain.UpdateAttributeSchemaRequest) (*domain.CategorySchemaVersion, error) {
+	if err := validateSchema(req.AttributeSchema); err != nil {
+		return nil, err
+	}
+	version, err := s.categoryRepo.UpdateSchema(ctx, categoryID, req.AttributeSchema, req.BaseVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCategoryTree(ctx)
+	s.schemas.notify()
+	return version, nil
+}
+
+// ListAttributeSchemaVersions returns the schema history of a category
+func (s *CatalogService) ListAttributeSchemaVersions(ctx context.Context, categoryID string) ([]domain.CategorySchemaVersion, error) {
+	return s.categoryRepo.ListSchemaVersions(ctx, categoryID)
+}
+
+// GetAttributeSchemaVersion returns a schema version with its validation report
+func (s *CatalogService) GetAttributeSchemaVersion(ctx context.Context, categoryID string, version int) (*domain.CategorySchemaVersion, error) {
+	return s.categoryRepo.GetSchemaVersion(ctx, categoryID, version)
+}
+
+// GetCategoryTree retrieves the category tree, served from cache when possible
 func (s *CatalogService) GetCategoryTree(ctx context.Context) ([]repository.CategoryNode, error) {
-	return s.categoryRepo.GetTree(ctx)
+	if cached, err := s.cache.Get(ctx, categoryTreeCacheKey); err == nil {
+		var tree []repository.CategoryNode
+		if err := json.Unmarshal([]byte(cached), &tree); err == nil {
+			return tree, nil
+		}
+	}
+
+	tree, err := s.categoryRepo.GetTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(tree); err == nil {
+		if err := s.cache.Set(ctx, categoryTreeCacheKey, data, categoryTreeCacheTTL); err != nil {
+			log.Warn().Err(err).Msg("Failed to cache category tree")
+		}
+	}
+	return tree, nil
+}
+
+// invalidateCategoryTree drops the cached tree after a change to categories
+// or products. A failure only leaves the cache stale until its TTL.
+func (s *CatalogService) invalidateCategoryTree(ctx context.Context) {
+	if err := s.cache.Delete(ctx, categoryTreeCacheKey); err != nil {
+		log.Warn().Err(err).Msg("Failed to invalidate category tree cache")
+	}
 }
 
 // GetRetailer retrieves a retailer by ID
@@ -104,3 +551,258 @@ func (s *CatalogService) ListRetailers(ctx context.Context, params repository.Li
 func (s *CatalogService) GetActiveRetailers(ctx context.Context) ([]domain.Retailer, error) {
 	return s.retailerRepo.GetActive(ctx)
 }
+
+// CreateRetailer creates a retailer after checking its affiliate and scraping
+// configuration
+func (s *CatalogService) CreateRetailer(ctx context.Context, req *domain.CreateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error) {
+	var errs validator.Errors
+	if !domain.ValidRetailerID(req.ID) {
+		errs = append(errs, validator.FieldError{
+			Field:   "id",
+			Rule:    "format",
+			Message: "must contain only lowercase letters, digits and single underscores",
+		})
+	}
+	errs = append(errs, retailerConfigErrors(req.Slug, req.AntiBotLevel, req.AffiliateURLTemplate, req.AffiliateID)...)
+	if len(errs) > 0 {
+		return nil, apperr.NewValidationError("Request validation failed", errs)
+	}
+	return s.retailerRepo.Create(ctx, req, audit)
+}
+
+// UpdateRetailer updates a retailer after checking its affiliate and scraping
+// configuration
+func (s *CatalogService) UpdateRetailer(ctx context.Context, id string, req *domain.UpdateRetailerRequest, audit domain.AuditInfo) (*domain.Retailer, error) {
+	// The template is checked against the affiliate ID it will be used with
+	affiliateID := req.AffiliateID
+	if req.AffiliateURLTemplate != nil && affiliateID == nil {
+		current, err := s.retailerRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		affiliateID = current.AffiliateID
+	}
+
+	if errs := retailerConfigErrors(req.Slug, req.AntiBotLevel, req.AffiliateURLTemplate, affiliateID); len(errs) > 0 {
+		return nil, apperr.NewValidationError("Request validation failed", errs)
+	}
+	return s.retailerRepo.Update(ctx, id, req, audit)
+}
+
+// UpdateRetailerStatus activates or deactivates a retailer and sets its priority
+func (s *CatalogService) UpdateRetailerStatus(ctx context.Context, id string, req *domain.UpdateRetailerStatusRequest, audit domain.AuditInfo) (*domain.Retailer, error) {
+	return s.retailerRepo.Update(ctx, id, &domain.UpdateRetailerRequest{
+		Active:   req.Active,
+		Priority: req.Priority,
+	}, audit)
+}
+
+// DeleteRetailer deletes a retailer without offers
+func (s *CatalogService) DeleteRetailer(ctx context.Context, id string, audit domain.AuditInfo) error {
+	return s.retailerRepo.Delete(ctx, id, audit)
+}
+
+// ListRetailerAudit retrieves the change history of a retailer, newest first
+func (s *CatalogService) ListRetailerAudit(ctx context.Context, id string, params repository.ListParams) (*repository.PaginatedResult[domain.RetailerAuditEntry], error) {
+	return s.retailerRepo.ListAudit(ctx, id, params)
+}
+
+// validateSlug rejects explicit slugs that are not already in canonical form
+func validateSlug(slug *string) error {
+	if errs := slugErrors(slug); len(errs) > 0 {
+		return apperr.NewValidationError("Request validation failed", errs)
+	}
+	return nil
+}
+
+// slugErrors reports an explicit slug that is not in canonical form
+func slugErrors(slug *string) validator.Errors {
+	if slug == nil || repository.Slugify(*slug) == *slug {
+		return nil
+	}
+	return validator.Errors{{
+		Field:   "slug",
+		Rule:    "slug",
+		Message: "must contain only lowercase letters, digits and single dashes",
+	}}
+}
+
+// retailerConfigErrors checks the retailer fields the struct tags cannot:
+// slug form, anti-bot level and affiliate URL template
+func retailerConfigErrors(slug, antiBotLevel, template, affiliateID *string) validator.Errors {
+	errs := slugErrors(slug)
+	if antiBotLevel != nil && !domain.ValidAntiBotLevel(*antiBotLevel) {
+		errs = append(errs, validator.FieldError{
+			Field:   "antiBotLevel",
+			Rule:    "oneof",
+			Message: "must be one of: " + strings.Join(domain.AntiBotLevels, ", "),
+		})
+	}
+	if template != nil {
+		if msg := domain.CheckAffiliateURLTemplate(*template, affiliateID); msg != "" {
+			errs = append(errs, validator.FieldError{
+				Field:   "affiliateUrlTemplate",
+				Rule:    "template",
+				Message: msg,
+			})
+		}
+	}
+	return errs
+}
+
+// validateSchema rejects inconsistent attribute definitions
+func validateSchema(schema domain.AttributeSchema) error {
+	problems := schema.Problems()
+	if len(problems) == 0 {
+		return nil
+	}
+	errs := make(validator.Errors, len(problems))
+	for i, p := range problems {
+		errs[i] = validator.FieldError{
+			Field:   "attributeSchema." + p.Attribute,
+			Rule:    p.Rule,
+			Message: p.Message,
+		}
+	}
+	return apperr.NewValidationError("Request validation failed", errs)
+}
+
+// normalizeAttributes coerces attrs to the attribute schema of the category.
+// Attributes the schema does not declare are rejected or, under the quarantine
+// policy, returned in quarantined on top of the already quarantined kept.
+// Categories without a schema accept any attributes as they are.
+func (s *CatalogService) normalizeAttributes(ctx context.Context, categoryID string, attrs, kept map[string]interface{}) (clean, quarantined map[string]interface{}, err error) {
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, nil, apperr.NewInvalidReferenceError("category", "categoryId")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(category.AttributeSchema) == 0 {
+		return attrs, kept, nil
+	}
+
+	// Quarantined keys the schema now declares are checked like the others
+	quarantined = make(map[string]interface{}, len(kept))
+	if len(kept) > 0 {
+		merged := make(map[string]interface{}, len(attrs)+len(kept))
+		for name, v := range attrs {
+			merged[name] = v
+		}
+		for name, v := range kept {
+			if _, declared := category.AttributeSchema[name]; !declared {
+				quarantined[name] = v
+			} else if _, set := merged[name]; !set {
+				merged[name] = v
+			}
+		}
+		attrs = merged
+	}
+
+	clean, unknown, violations := category.AttributeSchema.Normalize(attrs)
+
+	errs := make(validator.Errors, 0, len(violations)+len(unknown))
+	for _, v := range violations {
+		errs = append(errs, validator.FieldError{
+			Field:   "attributes." + v.Attribute,
+			Rule:    v.Rule,
+			Message: v.Message,
+		})
+	}
+	if s.unknownAttributes == domain.UnknownAttributesReject {
+		for name := range unknown {
+			errs = append(errs, validator.FieldError{
+				Field:   "attributes." + name,
+				Rule:    "unknown",
+				Message: "is not an attribute of category " + category.Slug,
+			})
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return nil, nil, apperr.NewValidationError("Request validation failed", errs)
+	}
+
+	for name, v := range unknown {
+		quarantined[name] = v
+	}
+	return clean, quarantined, nil
+}
+
+// resolveAttributeFilters checks attribute filters against the attribute_schema
+// of the filtered category and converts their raw values to the declared types.
+// Unknown attributes and type mismatches are reported as validation errors.
+func (s *CatalogService) resolveAttributeFilters(ctx context.Context, params *repository.ListParams) error {
+	filters, _ := params.Filters[repository.FilterAttributes].([]domain.AttributeFilter)
+	if len(filters) == 0 {
+		return nil
+	}
+
+	categoryID, _ := params.Filters[repository.FilterCategoryID].(string)
+	if categoryID == "" {
+		return apperr.NewValidationError("Invalid query parameters", validator.Errors{{
+			Field:   "categoryId",
+			Rule:    "required",
+			Message: "is required when filtering on attributes",
+		}})
+	}
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return apperr.NewInvalidReferenceError("category", "categoryId")
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs validator.Errors
+	resolved := make([]domain.AttributeFilter, 0, len(filters))
+	for _, f := range filters {
+		field := "attr." + f.Attribute
+		raw, _ := f.Value.(string)
+
+		typ, ok := category.AttributeType(f.Attribute)
+		if !ok {
+			errs = append(errs, validator.FieldError{Field: field, Rule: "unknown", Message: "is not an attribute of category " + category.Slug})
+			continue
+		}
+		if typ != domain.AttributeTypeNumber && domain.IsRangeOperator(f.Operator) {
+			errs = append(errs, validator.FieldError{Field: field, Rule: "operator", Message: "range operators only apply to number attributes"})
+			continue
+		}
+
+		switch typ {
+		case domain.AttributeTypeNumber:
+			// ParseFloat also reads NaN and infinities, which no JSON
+			// number can be compared with
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				errs = append(errs, validator.FieldError{Field: field, Rule: "type", Message: "must be a number"})
+				continue
+			}
+			f.Value = n
+		case domain.AttributeTypeBoolean:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				errs = append(errs, validator.FieldError{Field: field, Rule: "type", Message: "must be true or false"})
+				continue
+			}
+			f.Value = b
+		default:
+			f.Value = raw
+		}
+		resolved = append(resolved, f)
+	}
+	if len(errs) > 0 {
+		return apperr.NewValidationError("Invalid query parameters", errs)
+	}
+
+	// Copy the filters so the caller's map is left untouched
+	typed := make(map[string]interface{}, len(params.Filters))
+	for k, v := range params.Filters {
+		typed[k] = v
+	}
+	typed[repository.FilterAttributes] = resolved
+	params.Filters = typed
+	return nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// statsRepo records the variant PriceStats is asked about
+type statsRepo struct {
+	repository.OfferRepository
+	variantID *string
+	asked     bool
+}
+
+func (r *statsRepo) PriceStats(ctx context.Context, productID string, variantID *string, current *float64, windows []int) ([]domain.PriceWindowStats, error) {
+	r.variantID, r.asked = variantID, true
+	median := 100.0
+	return []domain.PriceWindowStats{{Days: 90, Median: &median, Samples: 5}}, nil
+}
+
+func TestDealScore_RatesOneVariant(t *testing.T) {
+	black, white := "black-id", "white-id"
+	was := 150.0
+	offers := []domain.Offer{
+		{ID: "o1", VariantID: &black, RetailerID: "fnac", Price: 120, InStock: true, WasPrice: &was},
+		{ID: "o2", VariantID: &white, RetailerID: "darty", Price: 90, InStock: true, WasPrice: &was},
+		{ID: "o3", VariantID: &white, RetailerID: "fnac", Price: 80, InStock: false},
+		{ID: "o4", RetailerID: "ldlc", Price: 95, InStock: true, WasPrice: &was},
+	}
+
+	tests := []struct {
+		name          string
+		variantID     *string
+		offers        []domain.Offer
+		wantVariant   *string
+		wantPrice     float64
+		wantDiscounts []string
+	}{
+		{"best offer's variant", nil, offers, &white, 90, []string{"o2"}},
+		{"best offer without variant", nil, offers[2:], nil, 95, []string{"o4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &statsRepo{}
+			s := &CatalogService{offerRepo: repo}
+			score, err := s.dealScore(context.Background(), "product-id", tt.variantID, tt.offers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !repo.asked || !sameID(repo.variantID, tt.wantVariant) || !sameID(score.VariantID, tt.wantVariant) {
+				t.Errorf("rated variant %v, stats of %v, want %v", deref(score.VariantID), deref(repo.variantID), deref(tt.wantVariant))
+			}
+			if score.CurrentPrice == nil || *score.CurrentPrice != tt.wantPrice {
+				t.Errorf("current price = %v, want %v", score.CurrentPrice, tt.wantPrice)
+			}
+			var discounts []string
+			for _, d := range score.Discounts {
+				discounts = append(discounts, d.OfferID)
+			}
+			if !slices.Equal(discounts, tt.wantDiscounts) {
+				t.Errorf("discounts checked for %v, want %v", discounts, tt.wantDiscounts)
+			}
+		})
+	}
+}
+
+func sameID(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<none>"
+	}
+	return *s
+}
+
+// categoryRepo serves a single category
+type categoryRepo struct {
+	repository.CategoryRepository
+	category *domain.Category
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, id string) (*domain.Category, error) {
+	if id != r.category.ID {
+		return nil, apperr.NewNotFoundError("category")
+	}
+	return r.category, nil
+}
+
+func TestResolveAttributeFilters(t *testing.T) {
+	s := &CatalogService{categoryRepo: &categoryRepo{category: &domain.Category{
+		ID:   "phones-id",
+		Slug: "smartphones",
+		AttributeSchema: domain.AttributeSchema{
+			"ram_gb": {Type: domain.AttributeTypeNumber},
+			"5g":     {Type: domain.AttributeTypeBoolean},
+		},
+	}}}
+
+	tests := []struct {
+		name      string
+		attribute string
+		operator  string
+		raw       string
+		want      interface{}
+		wantRule  string
+	}{
+		{name: "number", attribute: "ram_gb", operator: domain.AttributeOpGte, raw: "8", want: 8.0},
+		{name: "negative number", attribute: "ram_gb", operator: domain.AttributeOpEq, raw: "-1.5", want: -1.5},
+		{name: "boolean", attribute: "5g", operator: domain.AttributeOpEq, raw: "true", want: true},
+		{name: "not a number", attribute: "ram_gb", operator: domain.AttributeOpEq, raw: "lots", wantRule: "type"},
+		{name: "NaN", attribute: "ram_gb", operator: domain.AttributeOpGte, raw: "NaN", wantRule: "type"},
+		{name: "infinity", attribute: "ram_gb", operator: domain.AttributeOpLte, raw: "Inf", wantRule: "type"},
+		{name: "signed infinity", attribute: "ram_gb", operator: domain.AttributeOpEq, raw: "+Infinity", wantRule: "type"},
+		{name: "overflow", attribute: "ram_gb", operator: domain.AttributeOpGte, raw: "1e999", wantRule: "type"},
+		{name: "not a boolean", attribute: "5g", operator: domain.AttributeOpEq, raw: "maybe", wantRule: "type"},
+		{name: "range on boolean", attribute: "5g", operator: domain.AttributeOpGt, raw: "true", wantRule: "operator"},
+		{name: "unknown attribute", attribute: "color", operator: domain.AttributeOpEq, raw: "black", wantRule: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := repository.ListParams{Filters: map[string]interface{}{
+				repository.FilterCategoryID: "phones-id",
+				repository.FilterAttributes: []domain.AttributeFilter{{Attribute: tt.attribute, Operator: tt.operator, Value: tt.raw}},
+			}}
+			err := s.resolveAttributeFilters(context.Background(), &params)
+
+			if tt.wantRule != "" {
+				var derr *apperr.Error
+				if !errors.As(err, &derr) || !errors.Is(err, apperr.ErrValidation) {
+					t.Fatalf("err = %v, want a validation error", err)
+				}
+				details, _ := derr.Details.(validator.Errors)
+				if len(details) != 1 || details[0].Field != "attr."+tt.attribute || details[0].Rule != tt.wantRule {
+					t.Errorf("details = %+v, want attr.%s %s", details, tt.attribute, tt.wantRule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			filters := params.Filters[repository.FilterAttributes].([]domain.AttributeFilter)
+			if filters[0].Value != tt.want {
+				t.Errorf("value = %#v, want %#v", filters[0].Value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+)
+
+// Schema re-validation tuning
+const (
+	schemaMigrationBatchSize = 500
+	// maxReportedProducts caps the stored list of non-conforming products;
+	// the count always covers all of them
+	maxReportedProducts = 1000
+	// schemaMigrationPollInterval picks up versions left pending by a restart
+	// or by another instance
+	schemaMigrationPollInterval = time.Minute
+)
+
+// schemaMigrator re-validates a category's products whenever a new version
+// of its attribute schema is stored, recording which products no longer
+// conform. Pending versions live in the database, so work survives restarts.
+type schemaMigrator struct {
+	categoryRepo repository.CategoryRepository
+	productRepo  repository.ProductRepository
+	wake         chan struct{}
+}
+
+func newSchemaMigrator(categoryRepo repository.CategoryRepository, productRepo repository.ProductRepository) *schemaMigrator {
+	return &schemaMigrator{
+		categoryRepo: categoryRepo,
+		productRepo:  productRepo,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// notify asks the migrator to look for pending versions without waiting for
+// the next poll
+func (m *schemaMigrator) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run processes pending versions until ctx is cancelled
+func (m *schemaMigrator) run(ctx context.Context) {
+	ticker := time.NewTicker(schemaMigrationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.processPending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+func (m *schemaMigrator) processPending(ctx context.Context) {
+	versions, err := m.categoryRepo.PendingSchemaVersions(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to list pending attribute schema versions")
+		}
+		return
+	}
+
+	for i := range versions {
+		if ctx.Err() != nil {
+			return
+		}
+		v := &versions[i]
+
+		claimed, err := m.categoryRepo.ClaimSchemaVersion(ctx, v.CategoryID, v.Version)
+		if err != nil {
+			log.Error().Err(err).Str("category", v.CategoryID).Int("version", v.Version).
+				Msg("Failed to claim attribute schema version")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		m.validate(ctx, v)
+	}
+}
+
+// validate checks every product of the category against v and stores the report
+func (m *schemaMigrator) validate(ctx context.Context, v *domain.CategorySchemaVersion) {
+	logger := log.With().Str("category", v.CategoryID).Int("version", v.Version).Logger()
+
+	v.CheckedCount, v.NonConformingCount, v.NonConforming = 0, 0, nil
+	after := ""
+	for {
+		products, err := m.productRepo.ListByCategoryAfter(ctx, v.CategoryID, after, schemaMigrationBatchSize)
+		if err != nil && ctx.Err() != nil {
+			// Shutting down: the run is taken over once it times out
+			return
+		}
+		if err != nil {
+			msg := err.Error()
+			v.Status, v.Error = domain.SchemaValidationFailed, &msg
+			logger.Error().Err(err).Msg("Attribute schema validation failed")
+			break
+		}
+
+		for _, p := range products {
+			v.CheckedCount++
+			violations := v.AttributeSchema.Check(p.Attributes)
+			if len(violations) == 0 {
+				continue
+			}
+			v.NonConformingCount++
+			if len(v.NonConforming) < maxReportedProducts {
+				v.NonConforming = append(v.NonConforming, domain.NonConformingProduct{
+					ProductID:  p.ID,
+					Name:       p.Name,
+					Violations: violations,
+				})
+			}
+		}
+
+		if len(products) < schemaMigrationBatchSize {
+			v.Status = domain.SchemaValidationCompleted
+			break
+		}
+		after = products[len(products)-1].ID
+	}
+
+	if err := m.categoryRepo.SaveSchemaValidation(ctx, v); err != nil {
+		logger.Error().Err(err).Msg("Failed to store attribute schema validation")
+		return
+	}
+
+	logger.Info().
+		Int("checked", v.CheckedCount).
+		Int("nonConforming", v.NonConformingCount).
+		Str("status", v.Status).
+		Msg("Attribute schema validation finished")
+}
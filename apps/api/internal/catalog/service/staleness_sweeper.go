@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/catalog/repository"
+)
+
+// stalenessSweepInterval is how often offers are checked against their
+// retailer's freshness window; windows are hours long, so a few minutes of
+// lag is harmless
+const stalenessSweepInterval = 5 * time.Minute
+
+// stalenessSweeper marks offers stale (and out of stock) once they have not
+// been scraped again within their retailer's freshness window. Re-ingesting
+// an offer makes it fresh again.
+type stalenessSweeper struct {
+	retailerRepo repository.RetailerRepository
+	offerRepo    repository.OfferRepository
+}
+
+func newStalenessSweeper(retailerRepo repository.RetailerRepository, offerRepo repository.OfferRepository) *stalenessSweeper {
+	return &stalenessSweeper{retailerRepo: retailerRepo, offerRepo: offerRepo}
+}
+
+// run sweeps until ctx is cancelled
+func (s *stalenessSweeper) run(ctx context.Context) {
+	ticker := time.NewTicker(stalenessSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		s.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep expires the stale offers of every retailer, active or not
+func (s *stalenessSweeper) sweep(ctx context.Context) {
+	retailers, err := s.allRetailers(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to list retailers for the staleness sweep")
+		}
+		return
+	}
+
+	for i := range retailers {
+		if ctx.Err() != nil {
+			return
+		}
+		r := &retailers[i]
+		window := r.FreshnessWindow()
+		expired, err := s.offerRepo.MarkStale(ctx, r.ID, window)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error().Err(err).Str("retailer", r.ID).Msg("Failed to expire stale offers")
+			}
+			continue
+		}
+		if expired > 0 {
+			log.Info().Str("retailer", r.ID).Dur("window", window).Int64("expired", expired).
+				Msg("Expired stale offers")
+		}
+	}
+}
+
+func (s *stalenessSweeper) allRetailers(ctx context.Context) ([]domain.Retailer, error) {
+	var retailers []domain.Retailer
+	for page := 1; ; page++ {
+		result, err := s.retailerRepo.List(ctx, repository.ListParams{Page: page, PerPage: repository.MaxPerPage})
+		if err != nil {
+			return nil, err
+		}
+		retailers = append(retailers, result.Items...)
+		if page >= result.TotalPages {
+			return retailers, nil
+		}
+	}
+}
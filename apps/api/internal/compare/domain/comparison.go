@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"math"
+	"time"
+
+	catalog "github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+)
+
+// Criterion directions
+const (
+	DirectionMaximize = "maximize"
+	DirectionMinimize = "minimize"
+)
+
+// AttributePrice is the criterion attribute measuring the best offer price
+// rather than a product attribute
+const AttributePrice = "price"
+
+// DefaultWeight is the weight of a criterion that does not set one
+const DefaultWeight = 1.0
+
+// ComparisonRequest represents a comparison request
+type ComparisonRequest struct {
+	CategoryID string                `json:"categoryId" validate:"required,uuid"`
+	Criteria   []ComparisonCriterion `json:"criteria" validate:"required,min=1,max=10"`
+	Filters    *ComparisonFilters    `json:"filters,omitempty"`
+	// Limit caps the number of dominated products returned; the frontier is
+	// always complete
+	Limit int `json:"limit,omitempty" validate:"omitempty,gte=1,lte=1000"`
+	// Scoring names the ScoringStrategy of the composite scores, DefaultScoring
+	// when empty
+	Scoring string `json:"scoring,omitempty" validate:"omitempty,oneof=weighted_sum topsis lexicographic"`
+	// Explain adds an Explanation to every product, listing up to
+	// ExplainLimit products, DefaultExplainLimit when unset
+	Explain      bool `json:"explain,omitempty"`
+	ExplainLimit int  `json:"explainLimit,omitempty" validate:"omitempty,gte=1,lte=10"`
+}
+
+// ComparisonCriterion represents a comparison criterion. Weight defaults to
+// DefaultWeight and Direction to DirectionMaximize.
+type ComparisonCriterion struct {
+	Attribute string   `json:"attribute" validate:"required,max=100"`
+	Weight    *float64 `json:"weight,omitempty" validate:"omitempty,gte=0"`
+	Direction string   `json:"direction,omitempty" validate:"omitempty,oneof=maximize minimize"`
+
+	// Values closer than Tolerance, in the attribute's unit, or than
+	// RelativeTolerance, a fraction of the larger of them, tie in the
+	// dominance check. At most one of them is set.
+	Tolerance         *float64 `json:"tolerance,omitempty" validate:"omitempty,gte=0"`
+	RelativeTolerance *float64 `json:"relativeTolerance,omitempty" validate:"omitempty,gte=0,lte=1"`
+}
+
+// WithDefaults returns the criterion with its defaults applied
+func (c ComparisonCriterion) WithDefaults() ComparisonCriterion {
+	if c.Weight == nil {
+		w := DefaultWeight
+		c.Weight = &w
+	}
+	if c.Direction == "" {
+		c.Direction = DirectionMaximize
+	}
+	return c
+}
+
+// weight returns the criterion weight, DefaultWeight when unset
+func (c ComparisonCriterion) weight() float64 {
+	if c.Weight == nil {
+		return DefaultWeight
+	}
+	return *c.Weight
+}
+
+// maximize reports whether higher values are better
+func (c ComparisonCriterion) maximize() bool {
+	return c.Direction != DirectionMinimize
+}
+
+// HasTolerance reports whether near-equal values of the criterion tie
+func (c ComparisonCriterion) HasTolerance() bool {
+	return (c.Tolerance != nil && *c.Tolerance > 0) || (c.RelativeTolerance != nil && *c.RelativeTolerance > 0)
+}
+
+// tolerance returns how far apart a and b may be and still tie
+func (c ComparisonCriterion) tolerance(a, b float64) float64 {
+	switch {
+	case c.Tolerance != nil:
+		return *c.Tolerance
+	case c.RelativeTolerance != nil:
+		return *c.RelativeTolerance * math.Max(math.Abs(a), math.Abs(b))
+	}
+	return 0
+}
+
+// ComparisonFilters represents comparison filters
+type ComparisonFilters struct {
+	MinPrice    *float64 `json:"minPrice,omitempty" validate:"omitempty,gte=0"`
+	MaxPrice    *float64 `json:"maxPrice,omitempty" validate:"omitempty,gte=0"`
+	Retailers   []string `json:"retailers,omitempty" validate:"omitempty,max=50,dive,max=50"`
+	Brands      []string `json:"brands,omitempty" validate:"omitempty,max=50,dive,max=100"`
+	InStockOnly bool     `json:"inStockOnly,omitempty"`
+
+	// IncludeStale keeps offers that outlived their retailer's freshness
+	// window; they are left out of the comparison by default
+	IncludeStale bool `json:"includeStale,omitempty"`
+}
+
+// Candidate is a product taking part in a comparison, with the best offer
+// matching the filters
+type Candidate struct {
+	Product   catalog.Product
+	BestPrice *catalog.OfferWithRetailer
+}
+
+// ParetoProduct is a compared product with its Pareto ranking. Normalized
+// scores are in [0, weight] for every criterion, higher being better. Rank is
+// the product's front, 1 being the Pareto frontier, and CrowdingDistance its
+// isolation within that front. Score is the composite score of the scoring
+// strategy and ScoreRank the product's place by that score, ties sharing it.
+// ToleratedBy lists the products that would dominate the product if the
+// criteria had no tolerance; KeptByTolerance is set when the product is on
+// the frontier only thanks to it. Explanation is set when the request asks
+// for explanations.
+type ParetoProduct struct {
+	Product          catalog.Product            `json:"product"`
+	BestPrice        *catalog.OfferWithRetailer `json:"bestPrice"`
+	NormalizedScores map[string]float64         `json:"normalizedScores"`
+	ParetoOptimal    bool                       `json:"paretoOptimal"`
+	DominatedBy      []string                   `json:"dominatedBy"`
+	Rank             int                        `json:"rank"`
+	CrowdingDistance CrowdingDistance           `json:"crowdingDistance"`
+	Score            float64                    `json:"score"`
+	ScoreRank        int                        `json:"scoreRank"`
+	ToleratedBy      []string                   `json:"toleratedBy,omitempty"`
+	KeptByTolerance  bool                       `json:"keptByTolerance,omitempty"`
+	Explanation      *Explanation               `json:"explanation,omitempty"`
+}
+
+// ComparisonResult is the outcome of a comparison
+type ComparisonResult struct {
+	Criteria       []ComparisonCriterion `json:"criteria"`
+	Scoring        string                `json:"scoring"`
+	ParetoFrontier []ParetoProduct       `json:"paretoFrontier"`
+	Dominated      []ParetoProduct       `json:"dominated"`
+	TotalProducts  int                   `json:"totalProducts"`
+	ComputedAt     time.Time             `json:"computedAt"`
+}
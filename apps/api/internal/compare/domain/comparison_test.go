@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComparisonCriterion_WithDefaults(t *testing.T) {
+	c := ComparisonCriterion{Attribute: "battery"}.WithDefaults()
+	if c.Weight == nil || *c.Weight != DefaultWeight || c.Direction != DirectionMaximize || !c.maximize() {
+		t.Errorf("defaults = %+v, want weight %v and %s", c, DefaultWeight, DirectionMaximize)
+	}
+
+	set := criterion("price", 0, DirectionMinimize).WithDefaults()
+	if *set.Weight != 0 || set.Direction != DirectionMinimize || set.maximize() {
+		t.Errorf("explicit values = %+v, want them kept", set)
+	}
+
+	// The unexported accessors apply the same defaults without WithDefaults
+	if bare := (ComparisonCriterion{Attribute: "battery"}); bare.weight() != DefaultWeight || !bare.maximize() {
+		t.Errorf("bare criterion weight %v, maximize %v", bare.weight(), bare.maximize())
+	}
+}
+
+func TestComparisonCriterion_Tolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		criterion ComparisonCriterion
+		a, b      float64
+		has       bool
+		want      float64
+	}{
+		{name: "none", a: 100, b: 110},
+		{name: "zero", criterion: ComparisonCriterion{Tolerance: ptr(0)}, a: 100, b: 110},
+		{name: "absolute", criterion: ComparisonCriterion{Tolerance: ptr(5)}, a: 100, b: 110, has: true, want: 5},
+		{name: "relative", criterion: ComparisonCriterion{RelativeTolerance: ptr(0.1)}, a: 100, b: 110, has: true, want: 11},
+		{name: "relative, negative values", criterion: ComparisonCriterion{RelativeTolerance: ptr(0.1)}, a: -200, b: 110, has: true, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.criterion.HasTolerance(); got != tt.has {
+				t.Errorf("HasTolerance = %v, want %v", got, tt.has)
+			}
+			if got := tt.criterion.tolerance(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("tolerance(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultExplainLimit is the number of products an explanation lists when
+// the request does not say
+const DefaultExplainLimit = 3
+
+// Criterion outcomes, from the point of view of the other product
+const (
+	OutcomeBetter = "better"
+	OutcomeEqual  = "equal"
+	OutcomeWorse  = "worse"
+)
+
+// CriterionDelta compares another product with the explained one on a
+// criterion. Delta is the other product's value minus the explained one's;
+// values within the criterion's tolerance are equal.
+type CriterionDelta struct {
+	Attribute string  `json:"attribute"`
+	Value     float64 `json:"value"`
+	Delta     float64 `json:"delta"`
+	Outcome   string  `json:"outcome"`
+}
+
+// ProductComparison compares another product with the explained one
+type ProductComparison struct {
+	ProductID   string           `json:"productId"`
+	ProductName string           `json:"productName"`
+	Deltas      []CriterionDelta `json:"deltas"`
+}
+
+// Explanation tells why a product is, or is not, on the Pareto frontier.
+// A dominated product lists its best dominators, which are better or equal
+// on every criterion. A frontier product lists the criteria it is the
+// single best of the frontier on, and its trade-offs against the closest
+// frontier products: it wins where they are worse.
+type Explanation struct {
+	DominatedBy []ProductComparison `json:"dominatedBy,omitempty"`
+	BestOn      []string            `json:"bestOn,omitempty"`
+	TradeOffs   []ProductComparison `json:"tradeOffs,omitempty"`
+}
+
+// explainer builds the explanations of a comparison
+type explainer struct {
+	criteria   []ComparisonCriterion
+	candidates []Candidate
+	matrix     [][]float64
+	normalized [][]float64
+	ranks      []int
+	scoreRanks []int
+	limit      int
+}
+
+// dominated explains row i, dominated by the dominators rows: the best
+// ranked of them come first
+func (e *explainer) dominated(i int, dominators []int) *Explanation {
+	sorted := append([]int(nil), dominators...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if e.ranks[sorted[a]] != e.ranks[sorted[b]] {
+			return e.ranks[sorted[a]] < e.ranks[sorted[b]]
+		}
+		return e.scoreRanks[sorted[a]] < e.scoreRanks[sorted[b]]
+	})
+	if len(sorted) > e.limit {
+		sorted = sorted[:e.limit]
+	}
+
+	x := &Explanation{DominatedBy: make([]ProductComparison, len(sorted))}
+	for n, k := range sorted {
+		x.DominatedBy[n] = e.compare(i, k)
+	}
+	return x
+}
+
+// frontier explains row i, one of the frontier rows
+func (e *explainer) frontier(i int, frontier []int) *Explanation {
+	x := &Explanation{}
+
+	for j, c := range e.criteria {
+		best := len(frontier) > 1
+		for _, k := range frontier {
+			if k != i && e.outcome(c, e.matrix[k][j], e.matrix[i][j]) != OutcomeWorse {
+				best = false
+				break
+			}
+		}
+		if best {
+			x.BestOn = append(x.BestOn, c.Attribute)
+		}
+	}
+
+	others := make([]int, 0, len(frontier))
+	for _, k := range frontier {
+		if k != i {
+			others = append(others, k)
+		}
+	}
+	sort.SliceStable(others, func(a, b int) bool {
+		return e.distance(i, others[a]) < e.distance(i, others[b])
+	})
+	if len(others) > e.limit {
+		others = others[:e.limit]
+	}
+	for _, k := range others {
+		x.TradeOffs = append(x.TradeOffs, e.compare(i, k))
+	}
+	return x
+}
+
+// compare compares row k with the explained row i
+func (e *explainer) compare(i, k int) ProductComparison {
+	pc := ProductComparison{
+		ProductID:   e.candidates[k].Product.ID,
+		ProductName: e.candidates[k].Product.Name,
+		Deltas:      make([]CriterionDelta, len(e.criteria)),
+	}
+	for j, c := range e.criteria {
+		pc.Deltas[j] = CriterionDelta{
+			Attribute: c.Attribute,
+			Value:     e.matrix[k][j],
+			Delta:     e.matrix[k][j] - e.matrix[i][j],
+			Outcome:   e.outcome(c, e.matrix[k][j], e.matrix[i][j]),
+		}
+	}
+	return pc
+}
+
+// outcome rates value against the explained product's on criterion c
+func (e *explainer) outcome(c ComparisonCriterion, value, explained float64) string {
+	d := value - explained
+	if !c.maximize() {
+		d = -d
+	}
+	switch {
+	case d == 0 || math.Abs(d) <= c.tolerance(value, explained):
+		return OutcomeEqual
+	case d > 0:
+		return OutcomeBetter
+	default:
+		return OutcomeWorse
+	}
+}
+
+// distance is the Euclidean distance between two rows' normalized scores
+func (e *explainer) distance(a, b int) float64 {
+	sum := 0.0
+	for j := range e.criteria {
+		d := e.normalized[a][j] - e.normalized[b][j]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare_Explanations(t *testing.T) {
+	criteria := []ComparisonCriterion{
+		criterion("battery", 1, DirectionMaximize),
+		criterion("price", 1, DirectionMinimize),
+	}
+	cs := candidates(
+		map[string]interface{}{"battery": 5000.0, "price": 700.0},
+		map[string]interface{}{"battery": 4000.0, "price": 400.0},
+		map[string]interface{}{"battery": 3000.0, "price": 300.0},
+		map[string]interface{}{"battery": 3500.0, "price": 450.0},
+		map[string]interface{}{"battery": 2000.0, "price": 800.0},
+	)
+	for i := range cs {
+		cs[i].Product.Name = "Phone " + cs[i].Product.ID
+	}
+
+	result := Compare(criteria, cs, CompareOptions{Explain: 1})
+	explanations := map[string]*Explanation{}
+	for _, p := range append(result.ParetoFrontier, result.Dominated...) {
+		explanations[p.Product.ID] = p.Explanation
+	}
+
+	tests := []struct {
+		id   string
+		want *Explanation
+	}{
+		{
+			// p1 is the closest frontier product to p0 once normalized:
+			// cheaper, with a smaller battery
+			id: "p0",
+			want: &Explanation{
+				BestOn: []string{"battery"},
+				TradeOffs: []ProductComparison{{
+					ProductID:   "p1",
+					ProductName: "Phone p1",
+					Deltas: []CriterionDelta{
+						{Attribute: "battery", Value: 4000, Delta: -1000, Outcome: OutcomeWorse},
+						{Attribute: "price", Value: 400, Delta: -300, Outcome: OutcomeBetter},
+					},
+				}},
+			},
+		},
+		{
+			// p1 is best on nothing; p2 is closer to it than p0
+			id: "p1",
+			want: &Explanation{
+				TradeOffs: []ProductComparison{{
+					ProductID:   "p2",
+					ProductName: "Phone p2",
+					Deltas: []CriterionDelta{
+						{Attribute: "battery", Value: 3000, Delta: -1000, Outcome: OutcomeWorse},
+						{Attribute: "price", Value: 300, Delta: -100, Outcome: OutcomeBetter},
+					},
+				}},
+			},
+		},
+		{
+			id: "p3",
+			want: &Explanation{
+				DominatedBy: []ProductComparison{{
+					ProductID:   "p1",
+					ProductName: "Phone p1",
+					Deltas: []CriterionDelta{
+						{Attribute: "battery", Value: 4000, Delta: 500, Outcome: OutcomeBetter},
+						{Attribute: "price", Value: 400, Delta: -50, Outcome: OutcomeBetter},
+					},
+				}},
+			},
+		},
+		{
+			// Of its four dominators, the frontier one with the best
+			// composite score comes first
+			id: "p4",
+			want: &Explanation{
+				DominatedBy: []ProductComparison{{
+					ProductID:   "p1",
+					ProductName: "Phone p1",
+					Deltas: []CriterionDelta{
+						{Attribute: "battery", Value: 4000, Delta: 2000, Outcome: OutcomeBetter},
+						{Attribute: "price", Value: 400, Delta: -400, Outcome: OutcomeBetter},
+					},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := explanations[tt.id]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("explanation = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Dominators are ordered by front, then by score rank
+	result = Compare(criteria, cs, CompareOptions{Explain: DefaultExplainLimit})
+	var got []string
+	for _, p := range result.Dominated {
+		if p.Product.ID == "p4" {
+			for _, d := range p.Explanation.DominatedBy {
+				got = append(got, d.ProductID)
+			}
+		}
+	}
+	if want := []string{"p1", "p2", "p0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("p4 dominated by %v, want %v", got, want)
+	}
+
+	for _, p := range Compare(criteria, cs, CompareOptions{}).ParetoFrontier {
+		if p.Explanation != nil {
+			t.Errorf("%s explained without being asked to", p.Product.ID)
+		}
+	}
+}
+
+func TestCompare_ExplainsSingleFrontierProduct(t *testing.T) {
+	criteria := []ComparisonCriterion{criterion("battery", 1, DirectionMaximize)}
+	result := Compare(criteria, candidates(
+		map[string]interface{}{"battery": 5000.0},
+		map[string]interface{}{"battery": 4000.0},
+	), CompareOptions{Explain: DefaultExplainLimit})
+
+	// Being the only frontier product, it is not best against anyone
+	if x := result.ParetoFrontier[0].Explanation; x == nil || x.BestOn != nil || x.TradeOffs != nil {
+		t.Errorf("explanation = %+v, want an empty one", x)
+	}
+}
+
+func TestExplainer_Outcome(t *testing.T) {
+	battery := criterion("battery", 1, DirectionMaximize)
+	battery.Tolerance = ptr(100.0)
+	price := criterion("price", 1, DirectionMinimize)
+
+	e := &explainer{}
+	tests := []struct {
+		criterion        ComparisonCriterion
+		value, explained float64
+		want             string
+	}{
+		{battery, 5200, 5000, OutcomeBetter},
+		{battery, 5050, 5000, OutcomeEqual},
+		{battery, 4950, 5000, OutcomeEqual},
+		{battery, 4800, 5000, OutcomeWorse},
+		{price, 400, 500, OutcomeBetter},
+		{price, 500, 500, OutcomeEqual},
+		{price, 600, 500, OutcomeWorse},
+	}
+	for _, tt := range tests {
+		if got := e.outcome(tt.criterion, tt.value, tt.explained); got != tt.want {
+			t.Errorf("outcome(%s, %v, %v) = %s, want %s", tt.criterion.Attribute, tt.value, tt.explained, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// RankFrontier is the front rank of the Pareto frontier
+const RankFrontier = 1
+
+// CrowdingDistance measures how isolated a product is within its front: the
+// larger, the more it stands out from its neighbours. The boundary products
+// of a front are infinitely far and encode as null in JSON.
+type CrowdingDistance float64
+
+// IsBoundary reports whether the distance is infinite
+func (d CrowdingDistance) IsBoundary() bool {
+	return math.IsInf(float64(d), 1)
+}
+
+// MarshalJSON implements json.Marshaler
+func (d CrowdingDistance) MarshalJSON() ([]byte, error) {
+	if d.IsBoundary() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(d))
+}
+
+// CriterionValue returns the value of attribute for a candidate. Like the
+// Python workers' calculator, missing and non-numeric values count as 0 and
+// booleans as 1 or 0.
+func CriterionValue(c *Candidate, attribute string) float64 {
+	if attribute == AttributePrice && c.BestPrice != nil {
+		return c.BestPrice.Price
+	}
+
+	switch v := c.Product.Attributes[attribute].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Matrix builds the criteria matrix: one row per candidate, one column per
+// criterion
+func Matrix(criteria []ComparisonCriterion, candidates []Candidate) [][]float64 {
+	matrix := make([][]float64, len(candidates))
+	for i := range candidates {
+		row := make([]float64, len(criteria))
+		for j, c := range criteria {
+			row[j] = CriterionValue(&candidates[i], c.Attribute)
+		}
+		matrix[i] = row
+	}
+	return matrix
+}
+
+// Dominates reports whether row a dominates row b: a is at least as good on
+// every criterion and strictly better on one. Values within the tolerance
+// of a criterion tie.
+func Dominates(criteria []ComparisonCriterion, a, b []float64) bool {
+	return dominates(criteria, a, b, true)
+}
+
+// dominates implements Dominates, ignoring tolerances unless tolerant is set
+func dominates(criteria []ComparisonCriterion, a, b []float64, tolerant bool) bool {
+	better := false
+	for j, c := range criteria {
+		d := a[j] - b[j]
+		if !c.maximize() {
+			d = -d
+		}
+		if tolerant && math.Abs(d) <= c.tolerance(a[j], b[j]) {
+			continue
+		}
+		if d < 0 {
+			return false
+		}
+		if d > 0 {
+			better = true
+		}
+	}
+	return better
+}
+
+// equalRows reports whether two rows have the same value on every criterion
+func equalRows(a, b []float64) bool {
+	for j := range a {
+		if a[j] != b[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// Dominators returns, for every row, the indices of the rows dominating it.
+// A row identical to an earlier one is reported as dominated by the first of
+// them, so that the frontier holds distinct rows only, as paretoset does with
+// distinct=True.
+func Dominators(criteria []ComparisonCriterion, matrix [][]float64) [][]int {
+	dominators := make([][]int, len(matrix))
+	for i := range matrix {
+		for k := range matrix {
+			if k == i {
+				continue
+			}
+			if Dominates(criteria, matrix[k], matrix[i]) || (k < i && equalRows(matrix[k], matrix[i])) {
+				dominators[i] = append(dominators[i], k)
+			}
+		}
+	}
+	return dominators
+}
+
+// ToleratedDominators returns, for every row, the indices of the rows that
+// dominate it when tolerances are ignored but not when they apply
+func ToleratedDominators(criteria []ComparisonCriterion, matrix [][]float64) [][]int {
+	tolerated := make([][]int, len(matrix))
+	for i := range matrix {
+		for k := range matrix {
+			if k != i && dominates(criteria, matrix[k], matrix[i], false) && !Dominates(criteria, matrix[k], matrix[i]) {
+				tolerated[i] = append(tolerated[i], k)
+			}
+		}
+	}
+	return tolerated
+}
+
+// hasTolerance reports whether any criterion has a tolerance
+func hasTolerance(criteria []ComparisonCriterion) bool {
+	for _, c := range criteria {
+		if c.HasTolerance() {
+			return true
+		}
+	}
+	return false
+}
+
+// Fronts assigns every row its front rank by successive non-dominated
+// sorting: rank 1 is the Pareto frontier, rank 2 the frontier of the rows
+// left once it is removed, and so on. dominators is the output of Dominators.
+// Tolerances make dominance intransitive: rows caught in a dominance cycle
+// share the rank following the last front.
+func Fronts(dominators [][]int) []int {
+	remaining := make([]int, len(dominators))
+	dominated := make([][]int, len(dominators))
+	var front []int
+	for i, ds := range dominators {
+		remaining[i] = len(ds)
+		for _, k := range ds {
+			dominated[k] = append(dominated[k], i)
+		}
+		if remaining[i] == 0 {
+			front = append(front, i)
+		}
+	}
+
+	ranks := make([]int, len(dominators))
+	rank := RankFrontier
+	for ; len(front) > 0; rank++ {
+		var next []int
+		for _, k := range front {
+			ranks[k] = rank
+			for _, i := range dominated[k] {
+				remaining[i]--
+				if remaining[i] == 0 {
+					next = append(next, i)
+				}
+			}
+		}
+		front = next
+	}
+	for i := range ranks {
+		if ranks[i] == 0 {
+			ranks[i] = rank
+		}
+	}
+	return ranks
+}
+
+// CrowdingDistances returns the crowding distance of every row within its
+// front: the sum over criteria of the gap between its two neighbours,
+// relative to the spread of the front. The rows at either end of a criterion
+// are boundary rows.
+func CrowdingDistances(matrix [][]float64, ranks []int) []CrowdingDistance {
+	fronts := map[int][]int{}
+	for i, rank := range ranks {
+		fronts[rank] = append(fronts[rank], i)
+	}
+
+	distances := make([]CrowdingDistance, len(matrix))
+	for _, front := range fronts {
+		if len(front) < 3 {
+			for _, i := range front {
+				distances[i] = CrowdingDistance(math.Inf(1))
+			}
+			continue
+		}
+
+		for j := range matrix[front[0]] {
+			sorted := append([]int(nil), front...)
+			sort.SliceStable(sorted, func(a, b int) bool {
+				return matrix[sorted[a]][j] < matrix[sorted[b]][j]
+			})
+			first, last := sorted[0], sorted[len(sorted)-1]
+			distances[first] = CrowdingDistance(math.Inf(1))
+			distances[last] = CrowdingDistance(math.Inf(1))
+
+			spread := matrix[last][j] - matrix[first][j]
+			if spread == 0 {
+				continue
+			}
+			for k := 1; k < len(sorted)-1; k++ {
+				gap := matrix[sorted[k+1]][j] - matrix[sorted[k-1]][j]
+				distances[sorted[k]] += CrowdingDistance(gap / spread)
+			}
+		}
+	}
+	return distances
+}
+
+// NormalizeScores min-max normalizes every criterion to [0, 1], scales it by
+// the criterion weight and inverts minimized criteria, so that higher is
+// always better. A criterion with no spread scores 0 (weight when minimized).
+func NormalizeScores(criteria []ComparisonCriterion, matrix [][]float64) []map[string]float64 {
+	normalized := normalizeMatrix(criteria, matrix)
+	scores := make([]map[string]float64, len(matrix))
+	for i, row := range normalized {
+		s := make(map[string]float64, len(criteria))
+		for j, c := range criteria {
+			s[c.Attribute] = row[j]
+		}
+		scores[i] = s
+	}
+	return scores
+}
+
+// normalizeMatrix computes the normalized scores of NormalizeScores, by
+// criterion index
+func normalizeMatrix(criteria []ComparisonCriterion, matrix [][]float64) [][]float64 {
+	mins := make([]float64, len(criteria))
+	ranges := make([]float64, len(criteria))
+	for j := range criteria {
+		lo, hi := 0.0, 0.0
+		for i, row := range matrix {
+			if i == 0 || row[j] < lo {
+				lo = row[j]
+			}
+			if i == 0 || row[j] > hi {
+				hi = row[j]
+			}
+		}
+		mins[j], ranges[j] = lo, hi-lo
+		if ranges[j] == 0 {
+			ranges[j] = 1
+		}
+	}
+
+	normalized := make([][]float64, len(matrix))
+	for i, row := range matrix {
+		n := make([]float64, len(criteria))
+		for j, c := range criteria {
+			w := c.weight()
+			n[j] = (row[j] - mins[j]) / ranges[j] * w
+			if !c.maximize() {
+				n[j] = w - n[j]
+			}
+		}
+		normalized[i] = n
+	}
+	return normalized
+}
+
+// CompareOptions tunes Compare
+type CompareOptions struct {
+	// Scoring computes the composite scores, DefaultScoring when nil
+	Scoring ScoringStrategy
+	// Limit caps the dominated products returned, 0 meaning no cap
+	Limit int
+	// Explain is the number of products each explanation lists, 0 meaning
+	// no explanations
+	Explain int
+}
+
+// Compare ranks the candidates into successive Pareto fronts and scores them
+// with the scoring strategy. Products are ordered by front rank, then by
+// decreasing crowding distance, ties keeping the candidates' order.
+func Compare(criteria []ComparisonCriterion, candidates []Candidate, opts CompareOptions) *ComparisonResult {
+	scoring := opts.Scoring
+	if scoring == nil {
+		scoring = Scoring(DefaultScoring)
+	}
+
+	result := &ComparisonResult{
+		Criteria:       criteria,
+		Scoring:        scoring.Name(),
+		ParetoFrontier: []ParetoProduct{},
+		Dominated:      []ParetoProduct{},
+		TotalProducts:  len(candidates),
+		ComputedAt:     time.Now().UTC(),
+	}
+
+	matrix := Matrix(criteria, candidates)
+	dominators := Dominators(criteria, matrix)
+	ranks := Fronts(dominators)
+	crowding := CrowdingDistances(matrix, ranks)
+	scores := NormalizeScores(criteria, matrix)
+	composite := scoring.Score(criteria, matrix)
+	scoreRanks := ScoreRanks(composite)
+	var tolerated [][]int
+	if hasTolerance(criteria) {
+		tolerated = ToleratedDominators(criteria, matrix)
+	}
+
+	var (
+		explain  *explainer
+		frontier []int
+	)
+	if opts.Explain > 0 {
+		explain = &explainer{
+			criteria:   criteria,
+			candidates: candidates,
+			matrix:     matrix,
+			normalized: normalizeMatrix(criteria, matrix),
+			ranks:      ranks,
+			scoreRanks: scoreRanks,
+			limit:      opts.Explain,
+		}
+		for i, rank := range ranks {
+			if rank == RankFrontier {
+				frontier = append(frontier, i)
+			}
+		}
+	}
+
+	products := make([]ParetoProduct, len(candidates))
+	for i := range candidates {
+		p := ParetoProduct{
+			Product:          candidates[i].Product,
+			BestPrice:        candidates[i].BestPrice,
+			NormalizedScores: scores[i],
+			ParetoOptimal:    ranks[i] == RankFrontier,
+			DominatedBy:      make([]string, len(dominators[i])),
+			Rank:             ranks[i],
+			CrowdingDistance: crowding[i],
+			Score:            composite[i],
+			ScoreRank:        scoreRanks[i],
+		}
+		for k, d := range dominators[i] {
+			p.DominatedBy[k] = candidates[d].Product.ID
+		}
+		if tolerated != nil && len(tolerated[i]) > 0 {
+			p.ToleratedBy = make([]string, len(tolerated[i]))
+			for k, d := range tolerated[i] {
+				p.ToleratedBy[k] = candidates[d].Product.ID
+			}
+			p.KeptByTolerance = p.ParetoOptimal
+		}
+		if explain != nil {
+			if p.ParetoOptimal {
+				p.Explanation = explain.frontier(i, frontier)
+			} else {
+				p.Explanation = explain.dominated(i, dominators[i])
+			}
+		}
+		products[i] = p
+	}
+	sort.SliceStable(products, func(a, b int) bool {
+		if products[a].Rank != products[b].Rank {
+			return products[a].Rank < products[b].Rank
+		}
+		return products[a].CrowdingDistance > products[b].CrowdingDistance
+	})
+
+	for _, p := range products {
+		if p.ParetoOptimal {
+			result.ParetoFrontier = append(result.ParetoFrontier, p)
+		} else if opts.Limit == 0 || len(result.Dominated) < opts.Limit {
+			result.Dominated = append(result.Dominated, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,500 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	catalog "github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+)
+
+// The fixtures of TestCompare_MatchesWorkersCalculator were produced by the
+// Python workers' ParetoCalculator (apps/workers/src/pareto/calculator.py):
+// its frontier and dominated indices, and its normalized scores rounded to
+// six decimals. Both sides must agree on the frontier they report.
+
+// criterion builds a criterion with an explicit weight and direction
+func criterion(attribute string, weight float64, direction string) ComparisonCriterion {
+	return ComparisonCriterion{Attribute: attribute, Weight: &weight, Direction: direction}
+}
+
+// candidates builds one candidate per attribute set, with IDs p0, p1, ...
+func candidates(attrs ...map[string]interface{}) []Candidate {
+	cs := make([]Candidate, len(attrs))
+	for i, a := range attrs {
+		cs[i] = Candidate{Product: catalog.Product{ID: fmt.Sprintf("p%d", i), Attributes: a}}
+	}
+	return cs
+}
+
+// productIDs returns the IDs of products, sorted
+func productIDs(products []ParetoProduct) []string {
+	ids := []string{}
+	for _, p := range products {
+		ids = append(ids, p.Product.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestCompare_MatchesWorkersCalculator(t *testing.T) {
+	tests := []struct {
+		name       string
+		criteria   []ComparisonCriterion
+		candidates []Candidate
+		frontier   []string
+		dominated  []string
+		scores     []map[string]float64
+	}{
+		{
+			name: "distinct rows, mixed directions",
+			criteria: []ComparisonCriterion{
+				criterion("battery", 2, DirectionMaximize),
+				criterion("weight", 1, DirectionMinimize),
+				criterion("storage", 0.5, DirectionMaximize),
+			},
+			candidates: candidates(
+				map[string]interface{}{"battery": 5000.0, "weight": 200.0, "storage": 128.0},
+				map[string]interface{}{"battery": 4000.0, "weight": 180.0, "storage": 256.0},
+				map[string]interface{}{"battery": 4500.0, "weight": 210.0, "storage": 128.0},
+				map[string]interface{}{"battery": 3000.0, "weight": 150.0, "storage": 64.0},
+				map[string]interface{}{"battery": 3000.0, "weight": 160.0, "storage": 64.0},
+			),
+			frontier:  []string{"p0", "p1", "p3"},
+			dominated: []string{"p2", "p4"},
+			scores: []map[string]float64{
+				{"battery": 2, "weight": 0.166667, "storage": 0.166667},
+				{"battery": 1, "weight": 0.5, "storage": 0.5},
+				{"battery": 1.5, "weight": 0, "storage": 0.166667},
+				{"battery": 0, "weight": 1, "storage": 0},
+				{"battery": 0, "weight": 0.833333, "storage": 0},
+			},
+		},
+		{
+			// Like paretoset with distinct=True, only the first of identical
+			// rows is on the frontier
+			name: "duplicate rows",
+			criteria: []ComparisonCriterion{
+				criterion("battery", 1, DirectionMaximize),
+				criterion("weight", 1, DirectionMinimize),
+			},
+			candidates: candidates(
+				map[string]interface{}{"battery": 4000.0, "weight": 180.0},
+				map[string]interface{}{"battery": 5000.0, "weight": 200.0},
+				map[string]interface{}{"battery": 4000.0, "weight": 180.0},
+				map[string]interface{}{"battery": 5000.0, "weight": 200.0},
+				map[string]interface{}{"battery": 3000.0, "weight": 190.0},
+			),
+			frontier:  []string{"p0", "p1"},
+			dominated: []string{"p2", "p3", "p4"},
+			scores: []map[string]float64{
+				{"battery": 0.5, "weight": 1},
+				{"battery": 1, "weight": 0},
+				{"battery": 0.5, "weight": 1},
+				{"battery": 1, "weight": 0},
+				{"battery": 0, "weight": 0.5},
+			},
+		},
+		{
+			// A criterion without spread normalizes to 0, so to its full
+			// weight when minimized
+			name: "no spread",
+			criteria: []ComparisonCriterion{
+				criterion("battery", 1, DirectionMaximize),
+				criterion("weight", 3, DirectionMinimize),
+			},
+			candidates: candidates(
+				map[string]interface{}{"battery": 4000.0, "weight": 180.0},
+				map[string]interface{}{"battery": 5000.0, "weight": 180.0},
+			),
+			frontier:  []string{"p1"},
+			dominated: []string{"p0"},
+			scores: []map[string]float64{
+				{"battery": 0, "weight": 3},
+				{"battery": 1, "weight": 3},
+			},
+		},
+		{
+			// Missing, null and non-numeric values count as 0, booleans as 1 or 0
+			name: "missing and non-numeric values",
+			criteria: []ComparisonCriterion{
+				criterion("battery", 1, DirectionMaximize),
+				criterion("nfc", 1, DirectionMaximize),
+				criterion("weight", 1, DirectionMinimize),
+			},
+			candidates: candidates(
+				map[string]interface{}{"battery": 4000.0, "nfc": true, "weight": 180.0},
+				map[string]interface{}{"battery": "5000 mAh", "nfc": false, "weight": 170.0},
+				map[string]interface{}{"nfc": true, "weight": nil},
+				map[string]interface{}{"battery": 4000, "weight": 190.0},
+			),
+			frontier:  []string{"p0", "p2"},
+			dominated: []string{"p1", "p3"},
+			scores: []map[string]float64{
+				{"battery": 1, "nfc": 1, "weight": 0.052632},
+				{"battery": 0, "nfc": 0, "weight": 0.105263},
+				{"battery": 0, "nfc": 1, "weight": 1},
+				{"battery": 1, "nfc": 0, "weight": 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Compare(tt.criteria, tt.candidates, CompareOptions{})
+
+			if got := productIDs(result.ParetoFrontier); !slices.Equal(got, tt.frontier) {
+				t.Errorf("frontier = %v, want %v", got, tt.frontier)
+			}
+			if got := productIDs(result.Dominated); !slices.Equal(got, tt.dominated) {
+				t.Errorf("dominated = %v, want %v", got, tt.dominated)
+			}
+			if result.TotalProducts != len(tt.candidates) {
+				t.Errorf("total = %d, want %d", result.TotalProducts, len(tt.candidates))
+			}
+
+			for _, p := range slices.Concat(result.ParetoFrontier, result.Dominated) {
+				var i int
+				fmt.Sscanf(p.Product.ID, "p%d", &i)
+				for attr, want := range tt.scores[i] {
+					if got := p.NormalizedScores[attr]; math.Abs(got-want) > 1e-6 {
+						t.Errorf("%s %s score = %v, want %v", p.Product.ID, attr, got, want)
+					}
+				}
+				if p.ParetoOptimal != (p.Rank == RankFrontier) || p.ParetoOptimal != (len(p.DominatedBy) == 0) {
+					t.Errorf("%s: paretoOptimal %v, rank %d, dominated by %v", p.Product.ID, p.ParetoOptimal, p.Rank, p.DominatedBy)
+				}
+			}
+		})
+	}
+}
+
+func TestDominators(t *testing.T) {
+	criteria := []ComparisonCriterion{
+		criterion("battery", 1, DirectionMaximize),
+		criterion("weight", 1, DirectionMinimize),
+	}
+	tests := []struct {
+		name   string
+		matrix [][]float64
+		want   [][]int
+	}{
+		{
+			name:   "chain",
+			matrix: [][]float64{{3, 1}, {2, 2}, {1, 3}},
+			want:   [][]int{nil, {0}, {0, 1}},
+		},
+		{
+			name:   "trade-off",
+			matrix: [][]float64{{3, 3}, {1, 1}},
+			want:   [][]int{nil, nil},
+		},
+		{
+			// Equal on one criterion, better on the other
+			name:   "weakly better",
+			matrix: [][]float64{{3, 2}, {3, 1}},
+			want:   [][]int{{1}, nil},
+		},
+		{
+			// Identical rows: the later ones are reported as dominated by
+			// the first, not the other way round
+			name:   "duplicates",
+			matrix: [][]float64{{2, 2}, {2, 2}, {2, 2}},
+			want:   [][]int{nil, {0}, {0, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Dominators(criteria, tt.matrix)
+			for i := range tt.want {
+				if !slices.Equal(got[i], tt.want[i]) {
+					t.Errorf("dominators = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCriterionValue(t *testing.T) {
+	c := &Candidate{
+		Product: catalog.Product{Attributes: map[string]interface{}{
+			"float": 6.1, "int": 128, "int64": int64(5000), "true": true, "false": false,
+			"string": "6.1", "null": nil, "list": []interface{}{1.0},
+		}},
+		BestPrice: &catalog.OfferWithRetailer{Offer: catalog.Offer{Price: 499.99}},
+	}
+	want := map[string]float64{
+		"float": 6.1, "int": 128, "int64": 5000, "true": 1, "false": 0,
+		"string": 0, "null": 0, "list": 0, "missing": 0, AttributePrice: 499.99,
+	}
+	for attr, w := range want {
+		if got := CriterionValue(c, attr); got != w {
+			t.Errorf("CriterionValue(%s) = %v, want %v", attr, got, w)
+		}
+	}
+
+	// Without an offer, price is read from the attributes like any other
+	noOffer := &Candidate{Product: catalog.Product{Attributes: map[string]interface{}{AttributePrice: 10.0}}}
+	if got := CriterionValue(noOffer, AttributePrice); got != 10 {
+		t.Errorf("CriterionValue(price) without offer = %v, want 10", got)
+	}
+}
+
+func TestNormalizeScores(t *testing.T) {
+	criteria := []ComparisonCriterion{
+		{Attribute: "battery"},
+		criterion("price", 2, DirectionMinimize),
+		criterion("weight", 0, DirectionMaximize),
+	}
+	matrix := [][]float64{{1000, 100, 5}, {3000, 300, 5}, {2000, 150, 1}}
+	want := []map[string]float64{
+		{"battery": 0, "price": 2, "weight": 0},
+		{"battery": 1, "price": 0, "weight": 0},
+		{"battery": 0.5, "price": 1.5, "weight": 0},
+	}
+	got := NormalizeScores(criteria, matrix)
+	for i := range want {
+		for attr, w := range want[i] {
+			if math.Abs(got[i][attr]-w) > 1e-9 {
+				t.Errorf("row %d %s = %v, want %v", i, attr, got[i][attr], w)
+			}
+		}
+	}
+}
+
+func TestFronts(t *testing.T) {
+	criteria := []ComparisonCriterion{
+		criterion("battery", 1, DirectionMaximize),
+		criterion("weight", 1, DirectionMinimize),
+	}
+	tests := []struct {
+		name       string
+		dominators [][]int
+		want       []int
+	}{
+		{
+			name:       "successive fronts",
+			dominators: Dominators(criteria, [][]float64{{5, 2}, {3, 1}, {4, 3}, {2, 2}, {1, 4}}),
+			want:       []int{1, 1, 2, 2, 3},
+		},
+		{
+			name:       "chain",
+			dominators: [][]int{nil, {0}, {0, 1}},
+			want:       []int{1, 2, 3},
+		},
+		{
+			name:       "all on the frontier",
+			dominators: [][]int{nil, nil},
+			want:       []int{1, 1},
+		},
+		{
+			// Rows 1, 2 and 3 dominate each other in turn
+			name:       "dominance cycle",
+			dominators: [][]int{nil, {2}, {3}, {1}, {0}},
+			want:       []int{1, 3, 3, 3, 2},
+		},
+		{
+			name:       "no rows",
+			dominators: nil,
+			want:       []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fronts(tt.dominators); !slices.Equal(got, tt.want) {
+				t.Errorf("Fronts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrowdingDistances(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name   string
+		matrix [][]float64
+		ranks  []int
+		want   []float64
+	}{
+		{
+			// Row 1: 4/10 on the first criterion, 5/10 on the second.
+			// Row 2: 9/10 and 6/10.
+			name:   "one front",
+			matrix: [][]float64{{0, 10}, {1, 6}, {4, 5}, {10, 0}},
+			ranks:  []int{1, 1, 1, 1},
+			want:   []float64{inf, 0.9, 1.5, inf},
+		},
+		{
+			name:   "fronts are independent",
+			matrix: [][]float64{{0, 10}, {5, 4}, {10, 0}, {1, 1}, {2, 2}},
+			ranks:  []int{1, 1, 1, 2, 2},
+			want:   []float64{inf, 2, inf, inf, inf},
+		},
+		{
+			// The second criterion has no spread and adds nothing
+			name:   "no spread",
+			matrix: [][]float64{{1, 5}, {2, 5}, {3, 5}},
+			ranks:  []int{1, 1, 1},
+			want:   []float64{inf, 1, inf},
+		},
+		{
+			name:   "front of two",
+			matrix: [][]float64{{1, 2}, {2, 1}},
+			ranks:  []int{1, 1},
+			want:   []float64{inf, inf},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CrowdingDistances(tt.matrix, tt.ranks)
+			for i, want := range tt.want {
+				if g := float64(got[i]); g != want && math.Abs(g-want) > 1e-9 {
+					t.Errorf("distances = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCrowdingDistance_MarshalJSON(t *testing.T) {
+	for d, want := range map[CrowdingDistance]string{
+		CrowdingDistance(math.Inf(1)): "null",
+		0.5:                           "0.5",
+		0:                             "0",
+	} {
+		got, err := json.Marshal(d)
+		if err != nil || string(got) != want {
+			t.Errorf("Marshal(%v) = %s, %v, want %s", float64(d), got, err, want)
+		}
+	}
+}
+
+func TestCompare_OrdersByFrontThenCrowding(t *testing.T) {
+	criteria := []ComparisonCriterion{
+		criterion("battery", 1, DirectionMaximize),
+		criterion("storage", 1, DirectionMaximize),
+	}
+	result := Compare(criteria, candidates(
+		map[string]interface{}{"battery": 0.0, "storage": 10.0},
+		map[string]interface{}{"battery": 1.0, "storage": 6.0},
+		map[string]interface{}{"battery": 4.0, "storage": 5.0},
+		map[string]interface{}{"battery": 10.0, "storage": 0.0},
+		map[string]interface{}{"battery": 0.0, "storage": 5.0},
+	), CompareOptions{})
+
+	// Boundary products first, in the candidates' order, then the most
+	// isolated one
+	var got []string
+	for _, p := range result.ParetoFrontier {
+		got = append(got, p.Product.ID)
+	}
+	if want := []string{"p0", "p3", "p2", "p1"}; !slices.Equal(got, want) {
+		t.Errorf("frontier order = %v, want %v", got, want)
+	}
+	if len(result.Dominated) != 1 || result.Dominated[0].Rank != 2 || !result.Dominated[0].CrowdingDistance.IsBoundary() {
+		t.Errorf("dominated = %+v, want p4 alone on front 2", result.Dominated)
+	}
+}
+
+func TestToleratedDominators(t *testing.T) {
+	battery := criterion("battery", 1, DirectionMaximize)
+	battery.Tolerance = ptr(100.0)
+	price := criterion("price", 1, DirectionMinimize)
+	price.RelativeTolerance = ptr(0.05)
+
+	tests := []struct {
+		name       string
+		criteria   []ComparisonCriterion
+		matrix     [][]float64
+		dominators [][]int
+		tolerated  [][]int
+	}{
+		{
+			// 50 mAh is within the tolerance, 200 mAh is not
+			name:       "absolute tolerance",
+			criteria:   []ComparisonCriterion{battery, criterion("price", 1, DirectionMinimize)},
+			matrix:     [][]float64{{5000, 500}, {4950, 500}, {4800, 500}},
+			dominators: [][]int{nil, nil, {0, 1}},
+			tolerated:  [][]int{nil, {0}, nil},
+		},
+		{
+			// 4 is within 5% of 104, 6 is not within 5% of 110
+			name:       "relative tolerance",
+			criteria:   []ComparisonCriterion{criterion("battery", 1, DirectionMaximize), price},
+			matrix:     [][]float64{{4000, 100}, {4000, 104}, {4000, 110}},
+			dominators: [][]int{nil, nil, {0, 1}},
+			tolerated:  [][]int{nil, {0}, nil},
+		},
+		{
+			name:       "no tolerance",
+			criteria:   []ComparisonCriterion{criterion("battery", 1, DirectionMaximize), criterion("price", 1, DirectionMinimize)},
+			matrix:     [][]float64{{5000, 500}, {4950, 500}},
+			dominators: [][]int{nil, {0}},
+			tolerated:  [][]int{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dominators := Dominators(tt.criteria, tt.matrix)
+			tolerated := ToleratedDominators(tt.criteria, tt.matrix)
+			for i := range tt.matrix {
+				if !slices.Equal(dominators[i], tt.dominators[i]) {
+					t.Errorf("dominators = %v, want %v", dominators, tt.dominators)
+				}
+				if !slices.Equal(tolerated[i], tt.tolerated[i]) {
+					t.Errorf("tolerated = %v, want %v", tolerated, tt.tolerated)
+				}
+			}
+		})
+	}
+}
+
+func TestCompare_Tolerance(t *testing.T) {
+	battery := criterion("battery", 1, DirectionMaximize)
+	battery.Tolerance = ptr(100.0)
+	criteria := []ComparisonCriterion{battery, criterion("price", 1, DirectionMinimize)}
+
+	products := map[string]ParetoProduct{}
+	result := Compare(criteria, candidates(
+		map[string]interface{}{"battery": 5000.0, "price": 500.0},
+		map[string]interface{}{"battery": 4950.0, "price": 500.0},
+		map[string]interface{}{"battery": 4800.0, "price": 500.0},
+	), CompareOptions{})
+	for _, p := range slices.Concat(result.ParetoFrontier, result.Dominated) {
+		products[p.Product.ID] = p
+	}
+
+	// p1 stays on the frontier only because 50 mAh less is tolerated
+	if p := products["p1"]; !p.ParetoOptimal || !p.KeptByTolerance || !slices.Equal(p.ToleratedBy, []string{"p0"}) {
+		t.Errorf("p1 = %+v, want kept by tolerance of p0", p)
+	}
+	for _, id := range []string{"p0", "p2"} {
+		if p := products[id]; p.KeptByTolerance || p.ToleratedBy != nil {
+			t.Errorf("%s = %+v, want no tolerance", id, p)
+		}
+	}
+
+	// A product that would be dominated without tolerances, and is
+	// dominated by another one with them, is not kept
+	result = Compare(criteria, candidates(
+		map[string]interface{}{"battery": 5000.0, "price": 500.0},
+		map[string]interface{}{"battery": 4950.0, "price": 500.0},
+		map[string]interface{}{"battery": 4950.0, "price": 400.0},
+	), CompareOptions{})
+	if got := productIDs(result.ParetoFrontier); !slices.Equal(got, []string{"p2"}) {
+		t.Fatalf("frontier = %v, want [p2]", got)
+	}
+	for _, p := range result.Dominated {
+		products[p.Product.ID] = p
+	}
+	if p := products["p1"]; p.ParetoOptimal || p.KeptByTolerance || !slices.Equal(p.ToleratedBy, []string{"p0"}) {
+		t.Errorf("p1 = %+v, want tolerated by p0 but not kept", p)
+	}
+}
+
+// ptr returns a pointer to v
+func ptr(v float64) *float64 { return &v }
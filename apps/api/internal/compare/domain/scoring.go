@@ -0,0 +1,203 @@
+package domain
+
+import (
+	"math"
+	"sort"
+)
+
+// Scoring strategy names
+const (
+	ScoringWeightedSum   = "weighted_sum"
+	ScoringTOPSIS        = "topsis"
+	ScoringLexicographic = "lexicographic"
+)
+
+// DefaultScoring is the strategy of a request that does not pick one
+const DefaultScoring = ScoringWeightedSum
+
+// ScoringStrategy turns the criteria matrix into one composite score per
+// row, higher being better
+type ScoringStrategy interface {
+	Name() string
+	Score(criteria []ComparisonCriterion, matrix [][]float64) []float64
+}
+
+// ScoringStrategies lists the strategies by name
+var ScoringStrategies = map[string]ScoringStrategy{
+	ScoringWeightedSum:   WeightedSum{},
+	ScoringTOPSIS:        TOPSIS{},
+	ScoringLexicographic: Lexicographic{},
+}
+
+// Scoring returns the strategy called name, DefaultScoring when name is empty
+// or unknown
+func Scoring(name string) ScoringStrategy {
+	if s, ok := ScoringStrategies[name]; ok {
+		return s
+	}
+	return ScoringStrategies[DefaultScoring]
+}
+
+// ScoreRanks ranks the scores from the highest: equal scores share a rank
+// and the next rank skips accordingly (1, 2, 2, 4)
+func ScoreRanks(scores []float64) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	ranks := make([]int, len(scores))
+	for pos, i := range order {
+		if pos > 0 && scores[i] == scores[order[pos-1]] {
+			ranks[i] = ranks[order[pos-1]]
+			continue
+		}
+		ranks[i] = pos + 1
+	}
+	return ranks
+}
+
+// totalWeight returns the sum of the criteria weights
+func totalWeight(criteria []ComparisonCriterion) float64 {
+	total := 0.0
+	for _, c := range criteria {
+		total += c.weight()
+	}
+	return total
+}
+
+// WeightedSum scores a product with the weighted mean of its normalized
+// scores, in [0, 1]
+type WeightedSum struct{}
+
+// Name implements ScoringStrategy
+func (WeightedSum) Name() string { return ScoringWeightedSum }
+
+// Score implements ScoringStrategy
+func (WeightedSum) Score(criteria []ComparisonCriterion, matrix [][]float64) []float64 {
+	total := totalWeight(criteria)
+	scores := make([]float64, len(matrix))
+	if total == 0 {
+		return scores
+	}
+	for i, normalized := range normalizeMatrix(criteria, matrix) {
+		for _, v := range normalized {
+			scores[i] += v
+		}
+		scores[i] /= total
+	}
+	return scores
+}
+
+// TOPSIS scores a product with its relative closeness to the ideal product,
+// in [0, 1]: the best value of every criterion, as opposed to the worst.
+// Values are vector-normalized and weighted by the share of each criterion
+// in the total weight.
+type TOPSIS struct{}
+
+// Name implements ScoringStrategy
+func (TOPSIS) Name() string { return ScoringTOPSIS }
+
+// Score implements ScoringStrategy
+func (TOPSIS) Score(criteria []ComparisonCriterion, matrix [][]float64) []float64 {
+	scores := make([]float64, len(matrix))
+	total := totalWeight(criteria)
+	if len(matrix) == 0 || total == 0 {
+		return scores
+	}
+
+	weighted := make([][]float64, len(matrix))
+	for i := range matrix {
+		weighted[i] = make([]float64, len(criteria))
+	}
+	ideal := make([]float64, len(criteria))
+	worst := make([]float64, len(criteria))
+	for j, c := range criteria {
+		norm := 0.0
+		for _, row := range matrix {
+			norm += row[j] * row[j]
+		}
+		norm = math.Sqrt(norm)
+		if norm == 0 {
+			norm = 1
+		}
+
+		w := c.weight() / total
+		for i, row := range matrix {
+			v := row[j] / norm * w
+			weighted[i][j] = v
+			best, bad := v > ideal[j], v < worst[j]
+			if !c.maximize() {
+				best, bad = bad, best
+			}
+			if i == 0 || best {
+				ideal[j] = v
+			}
+			if i == 0 || bad {
+				worst[j] = v
+			}
+		}
+	}
+
+	for i, row := range weighted {
+		toIdeal, toWorst := 0.0, 0.0
+		for j, v := range row {
+			toIdeal += (v - ideal[j]) * (v - ideal[j])
+			toWorst += (v - worst[j]) * (v - worst[j])
+		}
+		toIdeal, toWorst = math.Sqrt(toIdeal), math.Sqrt(toWorst)
+		if toIdeal+toWorst == 0 {
+			// Every product is identical: neither closer to the ideal nor
+			// to the worst
+			scores[i] = 0.5
+			continue
+		}
+		scores[i] = toWorst / (toIdeal + toWorst)
+	}
+	return scores
+}
+
+// Lexicographic compares products on the first criterion, falling back to
+// the next one on ties, and ignores weights. A product scores the share of
+// the other products it beats, in [0, 1].
+type Lexicographic struct{}
+
+// Name implements ScoringStrategy
+func (Lexicographic) Name() string { return ScoringLexicographic }
+
+// Score implements ScoringStrategy
+func (Lexicographic) Score(criteria []ComparisonCriterion, matrix [][]float64) []float64 {
+	scores := make([]float64, len(matrix))
+	if len(matrix) < 2 {
+		for i := range scores {
+			scores[i] = 1
+		}
+		return scores
+	}
+
+	for i := range matrix {
+		beaten := 0
+		for k := range matrix {
+			if k != i && lexicographicallyBetter(criteria, matrix[i], matrix[k]) {
+				beaten++
+			}
+		}
+		scores[i] = float64(beaten) / float64(len(matrix)-1)
+	}
+	return scores
+}
+
+// lexicographicallyBetter reports whether row a beats row b on the first
+// criterion they differ on
+func lexicographicallyBetter(criteria []ComparisonCriterion, a, b []float64) bool {
+	for j, c := range criteria {
+		if a[j] == b[j] {
+			continue
+		}
+		return (a[j] > b[j]) == c.maximize()
+	}
+	return false
+}
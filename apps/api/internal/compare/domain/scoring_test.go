@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// The TOPSIS fixtures were cross-checked with a textbook implementation:
+// vector normalization, weights scaled to sum to 1, relative closeness to
+// the ideal solution.
+
+func TestScoring(t *testing.T) {
+	for name, want := range map[string]string{
+		ScoringWeightedSum:   ScoringWeightedSum,
+		ScoringTOPSIS:        ScoringTOPSIS,
+		ScoringLexicographic: ScoringLexicographic,
+		"":                   DefaultScoring,
+		"unknown":            DefaultScoring,
+	} {
+		if got := Scoring(name).Name(); got != want {
+			t.Errorf("Scoring(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestScoringStrategies(t *testing.T) {
+	batteryAndPrice := []ComparisonCriterion{
+		criterion("battery", 2, DirectionMaximize),
+		criterion("price", 1, DirectionMinimize),
+	}
+	tests := []struct {
+		name     string
+		scoring  string
+		criteria []ComparisonCriterion
+		matrix   [][]float64
+		want     []float64
+	}{
+		{
+			name:     "weighted sum",
+			scoring:  ScoringWeightedSum,
+			criteria: batteryAndPrice,
+			matrix:   [][]float64{{4000, 500}, {5000, 700}, {3000, 300}},
+			want:     []float64{0.5, 0.666667, 0.333333},
+		},
+		{
+			name:     "weighted sum without weights",
+			scoring:  ScoringWeightedSum,
+			criteria: []ComparisonCriterion{criterion("battery", 0, DirectionMaximize)},
+			matrix:   [][]float64{{4000}, {5000}},
+			want:     []float64{0, 0},
+		},
+		{
+			name:     "topsis",
+			scoring:  ScoringTOPSIS,
+			criteria: batteryAndPrice,
+			matrix:   [][]float64{{4000, 500}, {5000, 700}, {3000, 300}},
+			want:     []float64{0.5, 0.563015, 0.436985},
+		},
+		{
+			name:    "topsis, mixed directions",
+			scoring: ScoringTOPSIS,
+			criteria: []ComparisonCriterion{
+				criterion("battery", 2, DirectionMaximize),
+				criterion("weight", 1, DirectionMinimize),
+				criterion("storage", 0.5, DirectionMaximize),
+			},
+			matrix: [][]float64{{5000, 200, 128}, {4000, 180, 256}, {4500, 210, 128}, {3000, 150, 64}},
+			want:   []float64{0.669747, 0.60867, 0.56763, 0.222088},
+		},
+		{
+			// A column of zeros normalizes to 0 instead of dividing by zero
+			name:     "topsis, zero column",
+			scoring:  ScoringTOPSIS,
+			criteria: []ComparisonCriterion{{Attribute: "nfc"}, {Attribute: "storage"}},
+			matrix:   [][]float64{{0, 1}, {0, 3}},
+			want:     []float64{0, 1},
+		},
+		{
+			name:     "topsis, identical rows",
+			scoring:  ScoringTOPSIS,
+			criteria: batteryAndPrice,
+			matrix:   [][]float64{{4000, 500}, {4000, 500}},
+			want:     []float64{0.5, 0.5},
+		},
+		{
+			name:     "topsis without weights",
+			scoring:  ScoringTOPSIS,
+			criteria: []ComparisonCriterion{criterion("battery", 0, DirectionMaximize)},
+			matrix:   [][]float64{{4000}, {5000}},
+			want:     []float64{0, 0},
+		},
+		{
+			// Battery first, price breaking ties; rows 1 and 3 tie and beat
+			// neither each other
+			name:     "lexicographic",
+			scoring:  ScoringLexicographic,
+			criteria: batteryAndPrice,
+			matrix:   [][]float64{{5000, 700}, {5000, 500}, {4000, 100}, {5000, 500}},
+			want:     []float64{1.0 / 3, 2.0 / 3, 0, 2.0 / 3},
+		},
+		{
+			name:    "lexicographic ignores weights",
+			scoring: ScoringLexicographic,
+			criteria: []ComparisonCriterion{
+				criterion("battery", 0, DirectionMaximize),
+				criterion("price", 10, DirectionMinimize),
+			},
+			matrix: [][]float64{{4000, 100}, {5000, 700}},
+			want:   []float64{0, 1},
+		},
+		{
+			name:     "lexicographic, single row",
+			scoring:  ScoringLexicographic,
+			criteria: batteryAndPrice,
+			matrix:   [][]float64{{4000, 500}},
+			want:     []float64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Scoring(tt.scoring).Score(tt.criteria, tt.matrix)
+			if len(got) != len(tt.want) {
+				t.Fatalf("scores = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 1e-6 {
+					t.Errorf("scores = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestScoreRanks(t *testing.T) {
+	tests := []struct {
+		scores []float64
+		want   []int
+	}{
+		{scores: []float64{0.5, 0.9, 0.5, 0.1}, want: []int{2, 1, 2, 4}},
+		{scores: []float64{1, 1, 1}, want: []int{1, 1, 1}},
+		{scores: []float64{0.1, 0.2, 0.3}, want: []int{3, 2, 1}},
+		{scores: nil, want: []int{}},
+	}
+	for _, tt := range tests {
+		if got := ScoreRanks(tt.scores); !slices.Equal(got, tt.want) {
+			t.Errorf("ScoreRanks(%v) = %v, want %v", tt.scores, got, tt.want)
+		}
+	}
+}
+
+func TestCompare_Scoring(t *testing.T) {
+	criteria := []ComparisonCriterion{
+		criterion("battery", 2, DirectionMaximize),
+		criterion("price", 1, DirectionMinimize),
+	}
+	cs := candidates(
+		map[string]interface{}{"battery": 4000.0, "price": 500.0},
+		map[string]interface{}{"battery": 5000.0, "price": 700.0},
+		map[string]interface{}{"battery": 3000.0, "price": 300.0},
+	)
+	result := Compare(criteria, cs, CompareOptions{Scoring: Scoring(ScoringTOPSIS)})
+	if result.Scoring != ScoringTOPSIS {
+		t.Errorf("scoring = %s, want %s", result.Scoring, ScoringTOPSIS)
+	}
+	want := map[string]int{"p0": 2, "p1": 1, "p2": 3}
+	if len(result.ParetoFrontier) != len(want) {
+		t.Fatalf("frontier = %+v, want every product", result.ParetoFrontier)
+	}
+	for _, p := range result.ParetoFrontier {
+		if p.ScoreRank != want[p.Product.ID] {
+			t.Errorf("%s score %v ranks %d, want %d", p.Product.ID, p.Score, p.ScoreRank, want[p.Product.ID])
+		}
+	}
+
+	if got := Compare(criteria, cs, CompareOptions{}).Scoring; got != DefaultScoring {
+		t.Errorf("default scoring = %s, want %s", got, DefaultScoring)
+	}
+}
@@ -1,20 +1,28 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
-	"github.com/clumineau/pareto/apps/api/internal/shared/cache"
-	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/httpx"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
 )
 
+// CompareService is the comparison business logic used by the handlers
+type CompareService interface {
+	Compare(ctx context.Context, req *domain.ComparisonRequest) (*domain.ComparisonResult, error)
+}
+
 // NewRouter creates a new comparison router
-func NewRouter(db *database.DB, redis *cache.Client) http.Handler {
+func NewRouter(svc CompareService) http.Handler {
 	r := chi.NewRouter()
 
-	h := &CompareHandler{db: db, cache: redis}
+	h := &CompareHandler{svc: svc}
 
 	r.Post("/", h.Compare)
 
@@ -23,81 +31,33 @@ func NewRouter(db *database.DB, redis *cache.Client) http.Handler {
 
 // CompareHandler handles comparison requests
 type CompareHandler struct {
-	db    *database.DB
-	cache *cache.Client
-}
-
-// ComparisonRequest represents a comparison request
-type ComparisonRequest struct {
-	CategoryID string              `json:"categoryId"`
-	Criteria   []ComparisonCriterion `json:"criteria"`
-	Filters    *ComparisonFilters  `json:"filters,omitempty"`
-	Limit      int                 `json:"limit,omitempty"`
-}
-
-// ComparisonCriterion represents a comparison criterion
-type ComparisonCriterion struct {
-	Attribute string  `json:"attribute"`
-	Weight    float64 `json:"weight"`
-	Direction string  `json:"direction"`
-}
-
-// ComparisonFilters represents comparison filters
-type ComparisonFilters struct {
-	MinPrice    *float64 `json:"minPrice,omitempty"`
-	MaxPrice    *float64 `json:"maxPrice,omitempty"`
-	Retailers   []string `json:"retailers,omitempty"`
-	Brands      []string `json:"brands,omitempty"`
-	InStockOnly bool     `json:"inStockOnly,omitempty"`
+	svc CompareService
 }
 
-// Compare performs Pareto comparison
+// Compare performs Pareto comparison. The explain=true query parameter is a
+// shorthand for the request's explain field.
 func (h *CompareHandler) Compare(w http.ResponseWriter, r *http.Request) {
-	var req ComparisonRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	var req domain.ComparisonRequest
+	if !httpx.DecodeAndValidate(w, r, &req) {
 		return
 	}
 
-	// Validate request
-	if req.CategoryID == "" {
-		respondError(w, http.StatusBadRequest, "categoryId is required")
-		return
+	if v := r.URL.Query().Get("explain"); v != "" {
+		explain, err := strconv.ParseBool(v)
+		if err != nil {
+			httpx.RespondServiceError(w, r, apperr.NewValidationError("Invalid query parameters", validator.Errors{
+				{Field: "explain", Rule: "type", Message: "must be true or false"},
+			}))
+			return
+		}
+		req.Explain = req.Explain || explain
 	}
-	if len(req.Criteria) == 0 {
-		respondError(w, http.StatusBadRequest, "At least one criterion is required")
+
+	result, err := h.svc.Compare(r.Context(), &req)
+	if err != nil {
+		httpx.RespondServiceError(w, r, err)
 		return
 	}
 
-	// TODO: Implement Pareto comparison with Python workers via Redis queue
-	// For now, return a placeholder response
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"criteria":       req.Criteria,
-		"paretoFrontier": []interface{}{},
-		"dominated":      []interface{}{},
-		"totalProducts":  0,
-		"computedAt":     "2025-12-01T00:00:00Z",
-	})
-}
-
-// Helper functions
-
-func respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    data,
-	})
-}
-
-func respondError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": false,
-		"error": map[string]string{
-			"message": message,
-		},
-	})
+	httpx.RespondJSON(w, http.StatusOK, result)
 }
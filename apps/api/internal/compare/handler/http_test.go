@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// recordingService records the request it is given
+type recordingService struct {
+	req *domain.ComparisonRequest
+}
+
+func (s *recordingService) Compare(ctx context.Context, req *domain.ComparisonRequest) (*domain.ComparisonResult, error) {
+	s.req = req
+	return &domain.ComparisonResult{}, nil
+}
+
+func TestCompare_ExplainQuery(t *testing.T) {
+	const body = `{"categoryId": "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "criteria": [{"attribute": "price"}]%s}`
+
+	tests := []struct {
+		name        string
+		query       string
+		bodyExplain bool
+		wantStatus  int
+		wantExplain bool
+	}{
+		{name: "absent", wantStatus: http.StatusOK},
+		{name: "true", query: "?explain=true", wantStatus: http.StatusOK, wantExplain: true},
+		{name: "1", query: "?explain=1", wantStatus: http.StatusOK, wantExplain: true},
+		{name: "false", query: "?explain=false", wantStatus: http.StatusOK},
+		// The query parameter only turns explanations on
+		{name: "false with the body field", query: "?explain=false", bodyExplain: true, wantStatus: http.StatusOK, wantExplain: true},
+		{name: "absent with the body field", bodyExplain: true, wantStatus: http.StatusOK, wantExplain: true},
+		{name: "invalid", query: "?explain=maybe", wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extra := ""
+			if tt.bodyExplain {
+				extra = `, "explain": true`
+			}
+			svc := &recordingService{}
+			req := httptest.NewRequest(http.MethodPost, "/"+tt.query, strings.NewReader(strings.Replace(body, "%s", extra, 1)))
+			rec := httptest.NewRecorder()
+			NewRouter(svc).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var resp struct {
+					Error struct {
+						Code    string                 `json:"code"`
+						Details []validator.FieldError `json:"details"`
+					} `json:"error"`
+				}
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+				if d := resp.Error.Details; resp.Error.Code != apperr.CodeValidationFailed || len(d) != 1 || d[0].Field != "explain" {
+					t.Errorf("error = %+v, want a validation error on explain", resp.Error)
+				}
+				if svc.req != nil {
+					t.Error("an invalid query reached the service")
+				}
+				return
+			}
+			if svc.req == nil || svc.req.Explain != tt.wantExplain {
+				t.Errorf("request = %+v, want explain %v", svc.req, tt.wantExplain)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+)
+
+// Repository defines the interface for comparison data access
+type Repository interface {
+	CategoryExists(ctx context.Context, categoryID string) (bool, error)
+
+	// Candidates returns the active products of a category that have an
+	// offer matching filters, each with the cheapest of those offers
+	Candidates(ctx context.Context, categoryID string, filters *domain.ComparisonFilters) ([]domain.Candidate, error)
+}
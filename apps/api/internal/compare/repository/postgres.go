@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	catalog "github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// candidateColumns is the column list used to scan a domain.Candidate: the
+// product (alias "p"), its best offer ("best") and that offer's retailer ("r")
+const candidateColumns = `p.id, p.category_id, p.name, p.slug, p.brand, p.model, p.ean, p.sku,
+	p.image_url, COALESCE(p.images, '[]'::jsonb), COALESCE(p.attributes, '{}'::jsonb),
+	p.source, p.source_url, p.description, COALESCE(p.active, true),
+	COALESCE(p.created_at, NOW()), COALESCE(p.updated_at, NOW()), p.scraped_at,
+	best.id, best.product_id, best.variant_id, best.retailer_id, best.price,
+	COALESCE(best.shipping, 0), COALESCE(best.currency, 'EUR'), best.was_price, best.discount_percent,
+	best.url, best.affiliate_url, COALESCE(best.in_stock, true), best.stock_quantity, best.delivery_days,
+	best.seller_name, COALESCE(best.is_marketplace, false), COALESCE(best.scraped_at, NOW()),
+	COALESCE(best.created_at, NOW()), COALESCE(best.updated_at, NOW()),
+	best.stale_at, CASE WHEN best.stale_at IS NULL THEN 'fresh' ELSE 'stale' END,
+	r.id, r.name, r.slug, r.website_url, r.logo_url,
+	r.affiliate_network, r.affiliate_id, r.affiliate_url_template,
+	COALESCE(r.rate_limit_ms, 2000), COALESCE(r.anti_bot_level, 'medium'),
+	COALESCE(r.active, true), COALESCE(r.priority, 0), r.freshness_window_hours,
+	COALESCE(r.created_at, NOW()), COALESCE(r.updated_at, NOW())`
+
+// PostgresRepository implements Repository on top of pgx
+type PostgresRepository struct {
+	db *database.DB
+}
+
+// NewPostgresRepository creates a new Postgres-backed comparison repository
+func NewPostgresRepository(db *database.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// CategoryExists reports whether the category exists
+func (r *PostgresRepository) CategoryExists(ctx context.Context, categoryID string) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, categoryID).Scan(&exists)
+	return exists, err
+}
+
+// Candidates returns the active products of a category with their best
+// offer, cheapest first. Offers out of stock are only picked when no offer
+// in stock matches the filters; stale offers are left out unless
+// filters.IncludeStale is set.
+func (r *PostgresRepository) Candidates(ctx context.Context, categoryID string, filters *domain.ComparisonFilters) ([]domain.Candidate, error) {
+	if filters == nil {
+		filters = &domain.ComparisonFilters{}
+	}
+
+	var (
+		conds []string
+		args  = []any{categoryID}
+	)
+	arg := func(value any) int {
+		args = append(args, value)
+		return len(args)
+	}
+
+	if !filters.IncludeStale {
+		conds = append(conds, "o.stale_at IS NULL")
+	}
+	if filters.InStockOnly {
+		conds = append(conds, "COALESCE(o.in_stock, true)")
+	}
+	if filters.MinPrice != nil {
+		conds = append(conds, fmt.Sprintf("o.price >= $%d", arg(*filters.MinPrice)))
+	}
+	if filters.MaxPrice != nil {
+		conds = append(conds, fmt.Sprintf("o.price <= $%d", arg(*filters.MaxPrice)))
+	}
+	if len(filters.Retailers) > 0 {
+		conds = append(conds, fmt.Sprintf("o.retailer_id = ANY($%d)", arg(filters.Retailers)))
+	}
+
+	productConds := []string{"p.category_id = $1", "COALESCE(p.active, true)"}
+	if len(filters.Brands) > 0 {
+		brands := make([]string, len(filters.Brands))
+		for i, b := range filters.Brands {
+			brands[i] = strings.ToLower(b)
+		}
+		productConds = append(productConds, fmt.Sprintf("LOWER(p.brand) = ANY($%d)", arg(brands)))
+	}
+
+	offerCond := "true"
+	if len(conds) > 0 {
+		offerCond = strings.Join(conds, " AND ")
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+candidateColumns+`
+		FROM products p
+		JOIN LATERAL (
+			SELECT o.* FROM offers o
+			WHERE o.product_id = p.id AND `+offerCond+`
+			ORDER BY COALESCE(o.in_stock, true) DESC, o.price, COALESCE(o.shipping, 0)
+			LIMIT 1
+		) best ON true
+		JOIN retailers r ON r.id = best.retailer_id
+		WHERE `+strings.Join(productConds, " AND ")+`
+		ORDER BY best.price, p.name, p.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []domain.Candidate{}
+	for rows.Next() {
+		var c domain.Candidate
+		if err := scanCandidate(rows, &c); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+func scanCandidate(row pgx.Row, c *domain.Candidate) error {
+	p := &c.Product
+	c.BestPrice = &catalog.OfferWithRetailer{}
+	o, rt := &c.BestPrice.Offer, &c.BestPrice.Retailer
+	return row.Scan(
+		&p.ID, &p.CategoryID, &p.Name, &p.Slug, &p.Brand, &p.Model, &p.EAN, &p.SKU,
+		&p.ImageURL, &p.Images, &p.Attributes,
+		&p.Source, &p.SourceURL, &p.Description, &p.Active,
+		&p.CreatedAt, &p.UpdatedAt, &p.ScrapedAt,
+		&o.ID, &o.ProductID, &o.VariantID, &o.RetailerID, &o.Price,
+		&o.Shipping, &o.Currency, &o.WasPrice, &o.DiscountPercent,
+		&o.URL, &o.AffiliateURL, &o.InStock, &o.StockQuantity, &o.DeliveryDays,
+		&o.SellerName, &o.IsMarketplace, &o.ScrapedAt,
+		&o.CreatedAt, &o.UpdatedAt,
+		&o.StaleAt, &o.Freshness,
+		&rt.ID, &rt.Name, &rt.Slug, &rt.WebsiteURL, &rt.LogoURL,
+		&rt.AffiliateNetwork, &rt.AffiliateID, &rt.AffiliateURLTemplate,
+		&rt.RateLimitMs, &rt.AntiBotLevel,
+		&rt.Active, &rt.Priority, &rt.FreshnessWindowHours,
+		&rt.CreatedAt, &rt.UpdatedAt,
+	)
+}
+
+// Compile-time interface check
+var _ Repository = (*PostgresRepository)(nil)
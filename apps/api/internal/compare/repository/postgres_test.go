@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	catalog "github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+	"github.com/clumineau/pareto/apps/api/internal/shared/database/dbtest"
+)
+
+// insertProduct adds a smartphone and returns its ID
+func insertProduct(t *testing.T, db *database.DB, name string) string {
+	t.Helper()
+	var id string
+	err := db.Pool.QueryRow(context.Background(), `
+		INSERT INTO products (category_id, name, slug, brand, model)
+		VALUES ($1, $2, lower($2), 'Acme', $2)
+		RETURNING id`, dbtest.SmartphonesCategoryID, name).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// insertOffer adds an in-stock offer, stale when stale is set
+func insertOffer(t *testing.T, db *database.DB, productID, retailerID string, price float64, stale bool) {
+	t.Helper()
+	dbtest.Exec(t, db, `
+		INSERT INTO offers (product_id, retailer_id, price, url, stale_at)
+		VALUES ($1, $2, $3, 'https://shop.example/' || $2, CASE WHEN $4::bool THEN NOW() END)`,
+		productID, retailerID, price, stale)
+}
+
+func TestPostgresRepository_CategoryExists(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	for id, want := range map[string]bool{
+		dbtest.SmartphonesCategoryID:           true,
+		"00000000-0000-0000-0000-000000000000": false,
+	} {
+		exists, err := repo.CategoryExists(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != want {
+			t.Errorf("CategoryExists(%s) = %v, want %v", id, exists, want)
+		}
+	}
+}
+
+func TestPostgresRepository_CandidatesLeaveOutStaleOffers(t *testing.T) {
+	db := dbtest.New(t)
+	repo := NewPostgresRepository(db)
+	ctx := context.Background()
+
+	// The cheapest phone offer went stale; the tablet has no fresh offer
+	phone := insertProduct(t, db, "Phone")
+	insertOffer(t, db, phone, "fnac", 250, true)
+	insertOffer(t, db, phone, "darty", 300, false)
+	tablet := insertProduct(t, db, "Tablet")
+	insertOffer(t, db, tablet, "fnac", 200, true)
+
+	tests := []struct {
+		name    string
+		filters *domain.ComparisonFilters
+		want    map[string]float64
+	}{
+		{"no filters", nil, map[string]float64{phone: 300}},
+		{"fresh offers", &domain.ComparisonFilters{}, map[string]float64{phone: 300}},
+		{"stale offers included", &domain.ComparisonFilters{IncludeStale: true}, map[string]float64{phone: 250, tablet: 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := repo.Candidates(ctx, dbtest.SmartphonesCategoryID, tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(candidates) != len(tt.want) {
+				t.Fatalf("candidates = %+v, want %d", candidates, len(tt.want))
+			}
+			includeStale := tt.filters != nil && tt.filters.IncludeStale
+			for _, c := range candidates {
+				price, ok := tt.want[c.Product.ID]
+				if !ok || c.BestPrice.Offer.Price != price {
+					t.Errorf("%s best price = %v, want %v", c.Product.Name, c.BestPrice.Offer.Price, price)
+				}
+				stale := c.BestPrice.Offer.StaleAt != nil
+				if stale != (c.BestPrice.Offer.Freshness == catalog.FreshnessStale) || (stale && !includeStale) {
+					t.Errorf("%s best offer freshness = %s, stale at %v", c.Product.Name, c.BestPrice.Offer.Freshness, c.BestPrice.Offer.StaleAt)
+				}
+			}
+		})
+	}
+}
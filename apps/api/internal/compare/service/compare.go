@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+	"github.com/clumineau/pareto/apps/api/internal/compare/repository"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// CompareService provides product comparison business logic
+type CompareService struct {
+	repo repository.Repository
+}
+
+// NewCompareService creates a new comparison service
+func NewCompareService(repo repository.Repository) *CompareService {
+	return &CompareService{repo: repo}
+}
+
+// Compare computes the Pareto frontier of a category's products over the
+// requested criteria
+func (s *CompareService) Compare(ctx context.Context, req *domain.ComparisonRequest) (*domain.ComparisonResult, error) {
+	if errs := requestErrors(req); len(errs) > 0 {
+		return nil, apperr.NewValidationError("Request validation failed", errs)
+	}
+
+	exists, err := s.repo.CategoryExists(ctx, req.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apperr.NewInvalidReferenceError("category", "categoryId")
+	}
+
+	candidates, err := s.repo.Candidates(ctx, req.CategoryID, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	criteria := make([]domain.ComparisonCriterion, len(req.Criteria))
+	for i, c := range req.Criteria {
+		criteria[i] = c.WithDefaults()
+	}
+	opts := domain.CompareOptions{
+		Scoring: domain.Scoring(req.Scoring),
+		Limit:   req.Limit,
+	}
+	if req.Explain {
+		opts.Explain = req.ExplainLimit
+		if opts.Explain == 0 {
+			opts.Explain = domain.DefaultExplainLimit
+		}
+	}
+	return domain.Compare(criteria, candidates, opts), nil
+}
+
+// requestErrors checks what struct tags cannot: criteria must be distinct
+// with at most one tolerance each, and the price range must not be empty
+func requestErrors(req *domain.ComparisonRequest) validator.Errors {
+	var errs validator.Errors
+	seen := make(map[string]bool, len(req.Criteria))
+	for i, c := range req.Criteria {
+		if seen[c.Attribute] {
+			errs = append(errs, validator.FieldError{
+				Field:   fmt.Sprintf("criteria[%d].attribute", i),
+				Rule:    "unique",
+				Message: "is already used by another criterion",
+			})
+		}
+		seen[c.Attribute] = true
+
+		if c.Tolerance != nil && c.RelativeTolerance != nil {
+			errs = append(errs, validator.FieldError{
+				Field:   fmt.Sprintf("criteria[%d].relativeTolerance", i),
+				Rule:    "excluded_with",
+				Message: "cannot be combined with tolerance",
+			})
+		}
+	}
+
+	if f := req.Filters; f != nil && f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		errs = append(errs, validator.FieldError{
+			Field:   "filters.maxPrice",
+			Rule:    "gtefield",
+			Message: "must be greater than or equal to minPrice",
+		})
+	}
+	return errs
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	catalog "github.com/clumineau/pareto/apps/api/internal/catalog/domain"
+	"github.com/clumineau/pareto/apps/api/internal/compare/domain"
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+const phonesID = "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+
+// stubRepo serves candidates for the phones category and records the
+// filters it is asked with
+type stubRepo struct {
+	candidates []domain.Candidate
+	filters    *domain.ComparisonFilters
+	queried    bool
+}
+
+func (r *stubRepo) CategoryExists(ctx context.Context, categoryID string) (bool, error) {
+	return categoryID == phonesID, nil
+}
+
+func (r *stubRepo) Candidates(ctx context.Context, categoryID string, filters *domain.ComparisonFilters) ([]domain.Candidate, error) {
+	r.filters, r.queried = filters, true
+	return r.candidates, nil
+}
+
+// candidate returns a product whose best offer is at price
+func candidate(id string, price float64) domain.Candidate {
+	return domain.Candidate{
+		Product:   catalog.Product{ID: id, Name: id},
+		BestPrice: &catalog.OfferWithRetailer{Offer: catalog.Offer{ID: "offer-" + id, Price: price}},
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		criteria []domain.ComparisonCriterion
+		filters  *domain.ComparisonFilters
+		want     []string // field:rule
+	}{
+		{name: "valid", criteria: []domain.ComparisonCriterion{{Attribute: "price"}, {Attribute: "battery", Tolerance: ptr(100)}},
+			filters: &domain.ComparisonFilters{MinPrice: ptr(100), MaxPrice: ptr(500)}},
+		{name: "empty price range", criteria: []domain.ComparisonCriterion{{Attribute: "price"}},
+			filters: &domain.ComparisonFilters{MinPrice: ptr(500), MaxPrice: ptr(100)},
+			want:    []string{"filters.maxPrice:gtefield"}},
+		{name: "single price", criteria: []domain.ComparisonCriterion{{Attribute: "price"}},
+			filters: &domain.ComparisonFilters{MinPrice: ptr(300), MaxPrice: ptr(300)}},
+		{name: "open price range", criteria: []domain.ComparisonCriterion{{Attribute: "price"}},
+			filters: &domain.ComparisonFilters{MinPrice: ptr(500)}},
+		{name: "duplicate criteria", criteria: []domain.ComparisonCriterion{{Attribute: "price"}, {Attribute: "battery"}, {Attribute: "price"}},
+			want: []string{"criteria[2].attribute:unique"}},
+		{name: "both tolerances", criteria: []domain.ComparisonCriterion{{Attribute: "price"}, {Attribute: "battery", Tolerance: ptr(100), RelativeTolerance: ptr(0.05)}},
+			want: []string{"criteria[1].relativeTolerance:excluded_with"}},
+		{name: "every problem", criteria: []domain.ComparisonCriterion{{Attribute: "price", Tolerance: ptr(5), RelativeTolerance: ptr(0.05)}, {Attribute: "price"}},
+			filters: &domain.ComparisonFilters{MinPrice: ptr(500), MaxPrice: ptr(100)},
+			want:    []string{"criteria[0].relativeTolerance:excluded_with", "criteria[1].attribute:unique", "filters.maxPrice:gtefield"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubRepo{}
+			_, err := NewCompareService(repo).Compare(context.Background(), &domain.ComparisonRequest{
+				CategoryID: phonesID, Criteria: tt.criteria, Filters: tt.filters,
+			})
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var derr *apperr.Error
+			if !errors.As(err, &derr) || !errors.Is(err, apperr.ErrValidation) {
+				t.Fatalf("err = %v, want a validation error", err)
+			}
+			var got []string
+			for _, fe := range derr.Details.(validator.Errors) {
+				got = append(got, fe.Field+":"+fe.Rule)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+			if repo.queried {
+				t.Error("an invalid request reached the repository")
+			}
+		})
+	}
+}
+
+func TestCompare_UnknownCategory(t *testing.T) {
+	repo := &stubRepo{}
+	_, err := NewCompareService(repo).Compare(context.Background(), &domain.ComparisonRequest{
+		CategoryID: "00000000-0000-0000-0000-000000000000",
+		Criteria:   []domain.ComparisonCriterion{{Attribute: "price"}},
+	})
+
+	var derr *apperr.Error
+	if !errors.As(err, &derr) || !errors.Is(err, apperr.ErrInvalidReference) ||
+		derr.Resource != "category" || derr.Field != "categoryId" {
+		t.Fatalf("err = %#v, want an invalid categoryId reference", err)
+	}
+	if repo.queried {
+		t.Error("candidates were loaded for an unknown category")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	filters := &domain.ComparisonFilters{InStockOnly: true}
+	repo := &stubRepo{candidates: []domain.Candidate{candidate("cheap", 200), candidate("mid", 300), candidate("pricey", 400)}}
+
+	tests := []struct {
+		name         string
+		explain      bool
+		explainLimit int
+		// wantDominators is the number of dominators listed for "pricey",
+		// -1 when it is not explained
+		wantDominators int
+	}{
+		{"without explanations", false, 0, -1},
+		{"explained", true, 0, 2},
+		{"explained with a limit", true, 1, 1},
+		// The limit alone does not ask for explanations
+		{"limit without explain", false, 1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewCompareService(repo).Compare(context.Background(), &domain.ComparisonRequest{
+				CategoryID:   phonesID,
+				Criteria:     []domain.ComparisonCriterion{{Attribute: domain.AttributePrice, Direction: domain.DirectionMinimize}},
+				Filters:      filters,
+				Explain:      tt.explain,
+				ExplainLimit: tt.explainLimit,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if repo.filters != filters {
+				t.Errorf("candidates loaded with filters %+v, want %+v", repo.filters, filters)
+			}
+			if len(result.ParetoFrontier) != 1 || result.ParetoFrontier[0].Product.ID != "cheap" || result.TotalProducts != 3 {
+				t.Fatalf("result = %+v, want cheap on the frontier", result)
+			}
+			if c := result.Criteria[0]; c.Weight == nil || *c.Weight != domain.DefaultWeight {
+				t.Errorf("criterion = %+v, want the default weight applied", c)
+			}
+			for _, p := range append(result.ParetoFrontier, result.Dominated...) {
+				if (p.Explanation != nil) != (tt.wantDominators >= 0) {
+					t.Errorf("%s explanation = %+v, want explained %v", p.Product.ID, p.Explanation, tt.wantDominators >= 0)
+				}
+				if p.Product.ID == "pricey" && p.Explanation != nil && len(p.Explanation.DominatedBy) != tt.wantDominators {
+					t.Errorf("pricey lists %d dominators, want %d", len(p.Explanation.DominatedBy), tt.wantDominators)
+				}
+			}
+		})
+	}
+}
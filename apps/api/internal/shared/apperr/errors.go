@@ -0,0 +1,106 @@
+// Package apperr defines the typed errors reported by the repositories and
+// services of every module, which httpx turns into API error responses.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds shared by the repository, service and handler layers.
+// Match them with errors.Is; use errors.As with *Error for the details.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrValidation       = errors.New("validation failed")
+	ErrInvalidReference = errors.New("invalid reference")
+)
+
+// Machine-readable error codes exposed in API responses
+const (
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeValidationFailed = "validation_failed"
+	CodeInvalidReference = "invalid_reference"
+	CodeInUse            = "in_use"
+)
+
+// Error is a typed error carrying a stable code and optional context
+type Error struct {
+	Kind     error       `json:"-"`
+	Code     string      `json:"code"`
+	Message  string      `json:"message"`
+	Resource string      `json:"resource,omitempty"`
+	Field    string      `json:"field,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+	Err      error       `json:"-"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap exposes both the kind sentinel and the underlying cause
+func (e *Error) Unwrap() []error {
+	errs := []error{e.Kind}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// NewNotFoundError reports that resource could not be found
+func NewNotFoundError(resource string) *Error {
+	return &Error{
+		Kind:     ErrNotFound,
+		Code:     CodeNotFound,
+		Message:  resource + " not found",
+		Resource: resource,
+	}
+}
+
+// NewConflictError reports that field must be unique for resource
+func NewConflictError(resource, field string) *Error {
+	return &Error{
+		Kind:     ErrConflict,
+		Code:     CodeConflict,
+		Message:  fmt.Sprintf("%s with this %s already exists", resource, field),
+		Resource: resource,
+		Field:    field,
+	}
+}
+
+// NewValidationError reports invalid input, with optional per-field details
+func NewValidationError(message string, details interface{}) *Error {
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    CodeValidationFailed,
+		Message: message,
+		Details: details,
+	}
+}
+
+// NewInvalidReferenceError reports that field points at a missing record
+func NewInvalidReferenceError(resource, field string) *Error {
+	return &Error{
+		Kind:     ErrInvalidReference,
+		Code:     CodeInvalidReference,
+		Message:  fmt.Sprintf("%s references a %s that does not exist", field, resource),
+		Resource: resource,
+		Field:    field,
+	}
+}
+
+// NewInUseError reports that resource cannot be removed while other records reference it
+func NewInUseError(resource string) *Error {
+	return &Error{
+		Kind:     ErrConflict,
+		Code:     CodeInUse,
+		Message:  resource + " is still referenced by other records",
+		Resource: resource,
+	}
+}
@@ -0,0 +1,163 @@
+// Package dbtest provides disposable Postgres schemas for integration tests.
+//
+// Tests run against the database named by TEST_DATABASE_URL and are skipped
+// when it is unset. Each call gets a schema of its own, dropped when the test
+// ends, so tests can run in parallel against a single database.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// URLEnv names the DSN of the disposable database tests run against
+const URLEnv = "TEST_DATABASE_URL"
+
+// SmartphonesCategoryID is the category seeded by the initial migration
+const SmartphonesCategoryID = "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+
+// txModeNone marks migrations that cannot run inside a transaction
+// (CREATE INDEX CONCURRENTLY); their statements are run one by one
+const txModeNone = "-- atlas:txmode none"
+
+// schemaSeq keeps schema names unique within a test binary
+var schemaSeq atomic.Int64
+
+// New returns a pool bound to a fresh schema built by applying migrations/
+// in order, the way deployed databases are built
+func New(t testing.TB) *database.DB {
+	t.Helper()
+	return open(t, func(ctx context.Context, pool *pgxpool.Pool) error {
+		files, err := filepath.Glob(filepath.Join(apiDir(), "migrations", "*.sql"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if err := execFile(ctx, pool, file); err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(file), err)
+			}
+		}
+		return nil
+	})
+}
+
+// FromSchemaFile returns a pool bound to a fresh schema loaded from the
+// declarative schema.sql
+func FromSchemaFile(t testing.TB) *database.DB {
+	t.Helper()
+	return open(t, func(ctx context.Context, pool *pgxpool.Pool) error {
+		return execFile(ctx, pool, filepath.Join(apiDir(), "schema.sql"))
+	})
+}
+
+// Exec runs sql and fails the test on error
+func Exec(t testing.TB, db *database.DB, sql string, args ...any) {
+	t.Helper()
+	if _, err := db.Pool.Exec(context.Background(), sql, args...); err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+}
+
+// open creates a schema, builds it with load and returns a pool whose
+// search_path points at it
+func open(t testing.TB, load func(ctx context.Context, pool *pgxpool.Pool) error) *database.DB {
+	t.Helper()
+	dsn := os.Getenv(URLEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", URLEnv)
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d_%d_%d", os.Getpid(), time.Now().UnixNano(), schemaSeq.Add(1))
+
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	// Extensions are database-wide: keep them in public so that dropping the
+	// test schema leaves them alone, and the migrations' CREATE EXTENSION IF
+	// NOT EXISTS find them there
+	if _, err := admin.Exec(ctx, `
+		CREATE EXTENSION IF NOT EXISTS "uuid-ossp" WITH SCHEMA public;
+		CREATE EXTENSION IF NOT EXISTS "pg_trgm" WITH SCHEMA public;
+		CREATE SCHEMA `+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), `DROP SCHEMA `+schema+` CASCADE`); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := load(ctx, pool); err != nil {
+		t.Fatalf("build schema: %v", err)
+	}
+	return &database.DB{Pool: pool}
+}
+
+// execFile runs a SQL file as a single batch, or statement by statement when
+// it opts out of transactions
+func execFile(ctx context.Context, pool *pgxpool.Pool, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sql := string(b)
+	if !strings.HasPrefix(sql, txModeNone) {
+		_, err := pool.Exec(ctx, sql)
+		return err
+	}
+	// Such files hold plain DDL: splitting on statement ends is safe
+	for _, stmt := range strings.Split(sql, ";\n") {
+		if strings.TrimSpace(stripComments(stmt)) == "" {
+			continue
+		}
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripComments drops the "--" comment lines of a statement
+func stripComments(stmt string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(stmt, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// apiDir returns the apps/api directory holding schema.sql and migrations/
+func apiDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "..")
+}
@@ -0,0 +1,63 @@
+package dbtest
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/database"
+)
+
+// schemaObjects lists the columns, constraints, indexes and triggers of the
+// current schema, one description per line
+const schemaObjects = `
+	SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable
+	FROM information_schema.columns WHERE table_schema = current_schema()
+	UNION ALL
+	SELECT 'constraint ' || conrelid::regclass::text || ' ' || conname || ' ' || pg_get_constraintdef(oid)
+	FROM pg_constraint WHERE connamespace = current_schema()::regnamespace
+	UNION ALL
+	SELECT 'index ' || tablename || ' ' || indexname
+	FROM pg_indexes WHERE schemaname = current_schema()
+	UNION ALL
+	SELECT 'trigger ' || event_object_table || ' ' || trigger_name || ' ' || event_manipulation
+	FROM information_schema.triggers WHERE trigger_schema = current_schema()
+	ORDER BY 1`
+
+func TestMigrationsMatchSchemaFile(t *testing.T) {
+	migrated := objects(t, New(t))
+	declared := objects(t, FromSchemaFile(t))
+
+	for _, o := range declared {
+		if !slices.Contains(migrated, o) {
+			t.Errorf("schema.sql has %s, the migrations do not", o)
+		}
+	}
+	for _, o := range migrated {
+		if !slices.Contains(declared, o) {
+			t.Errorf("the migrations have %s, schema.sql does not", o)
+		}
+	}
+}
+
+func objects(t *testing.T, db *database.DB) []string {
+	t.Helper()
+	rows, err := db.Pool.Query(context.Background(), schemaObjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var objects []string
+	for rows.Next() {
+		var o string
+		if err := rows.Scan(&o); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, o)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
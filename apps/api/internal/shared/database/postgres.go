@@ -56,3 +56,11 @@ func (db *DB) Close() {
 func (db *DB) Health(ctx context.Context) error {
 	return db.Pool.Ping(ctx)
 }
+
+// HasExtension reports whether the named extension is installed in the database
+func (db *DB) HasExtension(ctx context.Context, name string) (bool, error) {
+	var installed bool
+	err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1)`, name).Scan(&installed)
+	return installed, err
+}
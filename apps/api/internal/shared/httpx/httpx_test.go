@@ -0,0 +1,125 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+)
+
+type errorBody struct {
+	Success bool `json:"success"`
+	Error   struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Field   string `json:"field"`
+		Details []struct {
+			Field string `json:"field"`
+			Rule  string `json:"rule"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	var body errorBody
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	type request struct {
+		Name  string `json:"name" validate:"required"`
+		Count int    `json:"count"`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantDetail  string
+		wantMessage string
+	}{
+		{name: "valid", body: `{"name": "a", "count": 2}`, wantStatus: http.StatusOK},
+		{name: "empty", body: ``, wantStatus: http.StatusBadRequest, wantCode: "bad_request", wantMessage: "Request body is required"},
+		{name: "not JSON", body: `{"name": `, wantStatus: http.StatusBadRequest, wantCode: "bad_request", wantMessage: "Request body is not valid JSON"},
+		{name: "trailing data", body: `{"name": "a"} {}`, wantStatus: http.StatusBadRequest, wantCode: "bad_request", wantMessage: "Request body must contain a single JSON object"},
+		{name: "wrong type", body: `{"name": "a", "count": "two"}`, wantStatus: http.StatusUnprocessableEntity, wantCode: apperr.CodeValidationFailed, wantDetail: "count:type"},
+		{name: "unknown field", body: `{"name": "a", "colour": "red"}`, wantStatus: http.StatusUnprocessableEntity, wantCode: apperr.CodeValidationFailed, wantDetail: "colour:unknown"},
+		{name: "failed rule", body: `{"count": 2}`, wantStatus: http.StatusUnprocessableEntity, wantCode: apperr.CodeValidationFailed, wantDetail: "name:required"},
+		{name: "too large", body: `{"name": "` + strings.Repeat("a", MaxBodyBytes) + `"}`, wantStatus: http.StatusRequestEntityTooLarge, wantCode: "payload_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var dst request
+			ok := DecodeAndValidate(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)), &dst)
+			if ok != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("ok = %v, response %d %s", ok, rec.Code, rec.Body)
+			}
+			if ok {
+				if dst.Name != "a" || dst.Count != 2 {
+					t.Errorf("decoded %+v", dst)
+				}
+				return
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := decodeError(t, rec)
+			if body.Success || body.Error.Code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", body.Error.Code, tt.wantCode)
+			}
+			if tt.wantMessage != "" && body.Error.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", body.Error.Message, tt.wantMessage)
+			}
+			if tt.wantDetail != "" {
+				if len(body.Error.Details) != 1 || body.Error.Details[0].Field+":"+body.Error.Details[0].Rule != tt.wantDetail {
+					t.Errorf("details = %+v, want %s", body.Error.Details, tt.wantDetail)
+				}
+			}
+		})
+	}
+}
+
+func TestRespondServiceError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"not found", apperr.NewNotFoundError("product"), http.StatusNotFound, apperr.CodeNotFound},
+		{"conflict", apperr.NewConflictError("product", "slug"), http.StatusConflict, apperr.CodeConflict},
+		{"in use", apperr.NewInUseError("retailer"), http.StatusConflict, apperr.CodeInUse},
+		{"validation", apperr.NewValidationError("Request validation failed", nil), http.StatusUnprocessableEntity, apperr.CodeValidationFailed},
+		{"invalid reference", apperr.NewInvalidReferenceError("product", "productId"), http.StatusUnprocessableEntity, apperr.CodeInvalidReference},
+		{"untyped", errors.New("pq: password authentication failed for user pareto"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RespondServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			raw := rec.Body.String()
+			body := decodeError(t, rec)
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Error.Code, tt.wantCode)
+			}
+			// Untyped errors may carry internals that must not reach clients
+			if tt.wantStatus == http.StatusInternalServerError && strings.Contains(raw, "password") {
+				t.Errorf("response leaks the error: %s", raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+	"github.com/clumineau/pareto/apps/api/internal/shared/validator"
+)
+
+// MaxBodyBytes caps the size of JSON request bodies
+const MaxBodyBytes = 1 << 20
+
+// DecodeAndValidate decodes the JSON request body into dst, rejecting unknown
+// fields, and checks dst against its `validate` struct tags. On failure it
+// writes the error response and returns false.
+func DecodeAndValidate(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		respondDecodeError(w, r, err)
+		return false
+	}
+	if dec.More() {
+		RespondError(w, http.StatusBadRequest, "Request body must contain a single JSON object")
+		return false
+	}
+
+	if errs := validator.Validate(dst); errs != nil {
+		RespondServiceError(w, r, apperr.NewValidationError("Request validation failed", errs))
+		return false
+	}
+	return true
+}
+
+// respondDecodeError turns a json.Decoder error into an error response.
+// Type mismatches and unknown fields are reported per field like validation errors.
+func respondDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		RespondError(w, http.StatusBadRequest, "Request body is required")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		RespondError(w, http.StatusBadRequest, "Request body is not valid JSON")
+	case errors.As(err, &maxBytesErr):
+		RespondError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.As(err, &typeErr):
+		RespondServiceError(w, r, apperr.NewValidationError("Request validation failed", validator.Errors{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be of type " + jsonType(typeErr.Type.Kind().String()),
+		}}))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		RespondServiceError(w, r, apperr.NewValidationError("Request validation failed", validator.Errors{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: "is not an allowed field",
+		}}))
+	default:
+		RespondError(w, http.StatusBadRequest, "Invalid request body")
+	}
+}
+
+// jsonType converts a Go kind name into the matching JSON type name
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "bool":
+		return "boolean"
+	case kind == "slice", kind == "array":
+		return "array"
+	case kind == "map", kind == "struct":
+		return "object"
+	}
+	return kind
+}
@@ -0,0 +1,100 @@
+// Package httpx holds the JSON request and response helpers shared by the
+// HTTP handlers of every module. Service errors are expected to be the typed
+// errors of the apperr package, which all modules report.
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/clumineau/pareto/apps/api/internal/shared/apperr"
+)
+
+// errorStatuses maps apperr kinds to HTTP status codes
+var errorStatuses = []struct {
+	kind   error
+	status int
+}{
+	{apperr.ErrNotFound, http.StatusNotFound},
+	{apperr.ErrConflict, http.StatusConflict},
+	{apperr.ErrValidation, http.StatusUnprocessableEntity},
+	{apperr.ErrInvalidReference, http.StatusUnprocessableEntity},
+}
+
+// RespondJSON writes data in the success envelope
+func RespondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// RespondError writes an error raised by a handler itself, with the code
+// matching status
+func RespondError(w http.ResponseWriter, status int, message string) {
+	RespondErrorBody(w, status, map[string]string{
+		"code":    codeForStatus(status),
+		"message": message,
+	})
+}
+
+// RespondErrorBody writes body in the error envelope
+func RespondErrorBody(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   body,
+	})
+}
+
+// RespondServiceError maps a service error to an HTTP error response.
+// Typed apperr errors are exposed as-is; anything else is logged and hidden.
+func RespondServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var derr *apperr.Error
+	if errors.As(err, &derr) {
+		RespondErrorBody(w, statusForError(derr), derr)
+		return
+	}
+
+	log.Error().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("Request failed")
+	RespondError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// statusForError returns the HTTP status code for a typed error
+func statusForError(err error) int {
+	for _, es := range errorStatuses {
+		if errors.Is(err, es.kind) {
+			return es.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// codeForStatus returns the machine-readable code for errors raised
+// directly by the handlers rather than by the service layer
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return apperr.CodeNotFound
+	case http.StatusConflict:
+		return apperr.CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnprocessableEntity:
+		return apperr.CodeValidationFailed
+	default:
+		return "internal_error"
+	}
+}
@@ -0,0 +1,294 @@
+package validator
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	uuidRegex     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexColorRegex = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// FieldError describes a single failed rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors is the list of rule failures for a value
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks v (a struct or pointer to struct) against its `validate`
+// struct tags. Field paths use the `json` names, e.g. "images[2]".
+//
+// Supported rules: required, omitempty, len, min, max, gte, lte, oneof,
+// uuid, url, hexcolor, ean13 and dive (apply the following rules to each
+// element of a slice). Nested structs are validated recursively.
+// It returns nil when v is valid.
+func Validate(v interface{}) Errors {
+	var errs Errors
+	validateStruct(reflect.ValueOf(v), "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidEAN13 reports whether s is a 13-digit EAN with a correct check digit
+func ValidEAN13(s string) bool {
+	if len(s) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		if i == 12 {
+			break
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return int(s[12]-'0') == (10-sum%10)%10
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *Errors) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		if sf.Anonymous {
+			validateStruct(fv, prefix, errs)
+			continue
+		}
+
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		tag := sf.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			validateNested(fv, path, errs)
+			continue
+		}
+		validateValue(fv, path, strings.Split(tag, ","), errs)
+	}
+}
+
+// validateNested recurses into struct, pointer-to-struct and slice-of-struct fields
+func validateNested(v reflect.Value, path string, errs *Errors) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() != timeType {
+			validateStruct(v, path, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateValue(v reflect.Value, path string, rules []string, errs *Errors) {
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		switch name {
+		case "":
+			continue
+		case "omitempty":
+			if isEmpty(v) {
+				return
+			}
+			continue
+		case "required":
+			if isEmpty(v) {
+				addError(errs, path, name, "is required")
+				return
+			}
+			continue
+		case "dive":
+			elem := indirect(v)
+			if elem.Kind() != reflect.Slice && elem.Kind() != reflect.Array {
+				return
+			}
+			for j := 0; j < elem.Len(); j++ {
+				validateValue(elem.Index(j), fmt.Sprintf("%s[%d]", path, j), rules[i+1:], errs)
+			}
+			return
+		}
+
+		ev := indirect(v)
+		if !ev.IsValid() {
+			return
+		}
+		if msg, ok := checkRule(ev, name, param); !ok {
+			addError(errs, path, name, msg)
+			return
+		}
+	}
+
+	validateNested(v, path, errs)
+}
+
+// checkRule applies a single parameterised rule to a non-nil value
+func checkRule(v reflect.Value, rule, param string) (string, bool) {
+	switch rule {
+	case "len":
+		n, _ := strconv.Atoi(param)
+		if size, unit, ok := sizeOf(v); ok && size != float64(n) {
+			return fmt.Sprintf("must be exactly %d %s", n, unit), false
+		}
+	case "min", "gte":
+		n, _ := strconv.ParseFloat(param, 64)
+		if size, unit, ok := sizeOf(v); ok && size < n {
+			if unit == "" {
+				return "must be at least " + param, false
+			}
+			return fmt.Sprintf("must be at least %s %s", param, unit), false
+		}
+	case "max", "lte":
+		n, _ := strconv.ParseFloat(param, 64)
+		if size, unit, ok := sizeOf(v); ok && size > n {
+			if unit == "" {
+				return "must be at most " + param, false
+			}
+			return fmt.Sprintf("must be at most %s %s", param, unit), false
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(param) {
+			if s == opt {
+				return "", true
+			}
+		}
+		return "must be one of: " + strings.Join(strings.Fields(param), ", "), false
+	case "uuid":
+		if v.Kind() != reflect.String || !uuidRegex.MatchString(v.String()) {
+			return "must be a valid UUID", false
+		}
+	case "url":
+		if v.Kind() != reflect.String || !isURL(v.String()) {
+			return "must be a valid http(s) URL", false
+		}
+	case "hexcolor":
+		if v.Kind() != reflect.String || !hexColorRegex.MatchString(v.String()) {
+			return "must be a hex color like #1D1D1F", false
+		}
+	case "ean13":
+		if v.Kind() != reflect.String || !ValidEAN13(v.String()) {
+			return "must be a valid EAN-13 barcode", false
+		}
+	default:
+		panic(fmt.Sprintf("validator: unknown rule %q", rule))
+	}
+	return "", true
+}
+
+// sizeOf returns the length of strings/slices/maps or the numeric value
+func sizeOf(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
+
+func isURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isEmpty(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+func addError(errs *Errors, path, rule, message string) {
+	*errs = append(*errs, FieldError{Field: path, Rule: rule, Message: message})
+}
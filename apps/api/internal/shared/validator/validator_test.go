@@ -0,0 +1,177 @@
+package validator
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+type testCriterion struct {
+	Attribute string   `json:"attribute" validate:"required,max=10"`
+	Weight    *float64 `json:"weight,omitempty" validate:"omitempty,gte=0"`
+}
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type TestAudit struct {
+	Actor string `json:"actor" validate:"required"`
+}
+
+type testRequest struct {
+	TestAudit
+	Name      string          `json:"name" validate:"required,min=2,max=5"`
+	Code      string          `json:"code,omitempty" validate:"omitempty,len=3"`
+	Count     int             `json:"count" validate:"gte=1,lte=10"`
+	Kind      string          `json:"kind" validate:"omitempty,oneof=new used"`
+	ID        string          `json:"id" validate:"omitempty,uuid"`
+	URL       *string         `json:"url" validate:"omitempty,url"`
+	Color     string          `json:"color" validate:"omitempty,hexcolor"`
+	EAN       *string         `json:"ean" validate:"omitempty,ean13"`
+	Images    []string        `json:"images" validate:"max=3,dive,url"`
+	Tags      []string        `json:"tags" validate:"omitempty,dive,required,max=3"`
+	Criteria  []testCriterion `json:"criteria" validate:"required,min=1,dive"`
+	Address   *testAddress    `json:"address"`
+	Addresses []testAddress   `json:"addresses"`
+	At        time.Time       `json:"at"`
+	Internal  string          `json:"-" validate:"required"`
+	Unnamed   string          `validate:"omitempty,max=1"`
+}
+
+// validRequest returns a request passing every rule
+func validRequest() testRequest {
+	return testRequest{
+		TestAudit: TestAudit{Actor: "admin"},
+		Name:      "Phone",
+		Count:     1,
+		Images:    []string{"https://img.example/1.jpg"},
+		Criteria:  []testCriterion{{Attribute: "battery"}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	weight := -1.0
+
+	tests := []struct {
+		name   string
+		mutate func(r *testRequest)
+		want   []string // field:rule
+	}{
+		{name: "valid", mutate: func(r *testRequest) {}},
+		{name: "valid optional values", mutate: func(r *testRequest) {
+			r.Code, r.Kind, r.ID = "ABC", "used", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+			r.URL, r.Color, r.EAN = str("http://shop.example/p?id=1"), "#1D1D1F", str("4006381333931")
+			r.Tags = []string{"5g", "nfc"}
+			r.Address = &testAddress{City: "Lyon"}
+		}},
+		{name: "short hex color", mutate: func(r *testRequest) { r.Color = "#fff" }},
+
+		{name: "required", mutate: func(r *testRequest) { r.Name = "" }, want: []string{"name:required"}},
+		{name: "required slice", mutate: func(r *testRequest) { r.Criteria = nil }, want: []string{"criteria:required"}},
+		{name: "min length", mutate: func(r *testRequest) { r.Name = "P" }, want: []string{"name:min"}},
+		// Lengths count characters, not bytes
+		{name: "max length in characters", mutate: func(r *testRequest) { r.Name = "Télé" }},
+		{name: "max length", mutate: func(r *testRequest) { r.Name = "Phones" }, want: []string{"name:max"}},
+		{name: "exact length", mutate: func(r *testRequest) { r.Code = "AB" }, want: []string{"code:len"}},
+		{name: "gte", mutate: func(r *testRequest) { r.Count = 0 }, want: []string{"count:gte"}},
+		{name: "lte", mutate: func(r *testRequest) { r.Count = 11 }, want: []string{"count:lte"}},
+		{name: "oneof", mutate: func(r *testRequest) { r.Kind = "broken" }, want: []string{"kind:oneof"}},
+		{name: "uuid", mutate: func(r *testRequest) { r.ID = "a0eebc99-9c0b-4ef8-bb6d" }, want: []string{"id:uuid"}},
+		{name: "url scheme", mutate: func(r *testRequest) { r.URL = str("ftp://shop.example/p") }, want: []string{"url:url"}},
+		{name: "url without host", mutate: func(r *testRequest) { r.URL = str("https://") }, want: []string{"url:url"}},
+		{name: "relative url", mutate: func(r *testRequest) { r.URL = str("/p/1") }, want: []string{"url:url"}},
+		{name: "hexcolor", mutate: func(r *testRequest) { r.Color = "1D1D1F" }, want: []string{"color:hexcolor"}},
+		{name: "ean13", mutate: func(r *testRequest) { r.EAN = str("4006381333932") }, want: []string{"ean:ean13"}},
+		{name: "max items", mutate: func(r *testRequest) {
+			r.Images = []string{"https://a.example", "https://b.example", "https://c.example", "https://d.example"}
+		}, want: []string{"images:max"}},
+
+		{name: "dive", mutate: func(r *testRequest) {
+			r.Images = []string{"https://a.example", "https://b.example", "not a url"}
+		}, want: []string{"images[2]:url"}},
+		{name: "dive, rules after required", mutate: func(r *testRequest) {
+			r.Tags = []string{"5g", "", "wireless"}
+		}, want: []string{"tags[1]:required", "tags[2]:max"}},
+		{name: "dive into structs", mutate: func(r *testRequest) {
+			r.Criteria = []testCriterion{{Attribute: "battery"}, {Attribute: ""}, {Attribute: "price", Weight: &weight}}
+		}, want: []string{"criteria[1].attribute:required", "criteria[2].weight:gte"}},
+		{name: "nested pointer", mutate: func(r *testRequest) { r.Address = &testAddress{} }, want: []string{"address.city:required"}},
+		{name: "nested slice without rules", mutate: func(r *testRequest) {
+			r.Addresses = []testAddress{{City: "Lyon"}, {}}
+		}, want: []string{"addresses[1].city:required"}},
+		{name: "embedded", mutate: func(r *testRequest) { r.Actor = "" }, want: []string{"actor:required"}},
+		{name: "go field name without json tag", mutate: func(r *testRequest) { r.Unnamed = "ab" }, want: []string{"Unnamed:max"}},
+
+		{name: "one error per field", mutate: func(r *testRequest) { r.Name, r.Count = "", 0 }, want: []string{"name:required", "count:gte"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validRequest()
+			tt.mutate(&r)
+			var got []string
+			for _, fe := range Validate(&r) {
+				got = append(got, fe.Field+":"+fe.Rule)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_NonStruct(t *testing.T) {
+	var nilRequest *testRequest
+	for _, v := range []interface{}{nil, nilRequest, "text", 42} {
+		if errs := Validate(v); errs != nil {
+			t.Errorf("Validate(%#v) = %v, want nil", v, errs)
+		}
+	}
+}
+
+func TestValidate_Messages(t *testing.T) {
+	r := validRequest()
+	r.Name, r.Kind, r.Images = "Phones", "broken", nil
+	errs := Validate(r)
+	want := "name must be at most 5 characters; kind must be one of: new, used"
+	if errs.Error() != want {
+		t.Errorf("message = %q, want %q", errs.Error(), want)
+	}
+}
+
+func TestValidate_UnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("an unknown rule did not panic")
+		}
+	}()
+	Validate(struct {
+		Name string `validate:"shiny"`
+	}{Name: "x"})
+}
+
+func TestValidEAN13(t *testing.T) {
+	tests := []struct {
+		ean  string
+		want bool
+	}{
+		{"4006381333931", true},
+		{"5901234123457", true},
+		{"3760113140068", true},
+		{"0000000000000", true},
+		{"4006381333932", false},
+		{"5901234123450", false},
+		{"400638133393", false},
+		{"40063813339310", false},
+		{"400638133393a", false},
+		{"4006381 33931", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidEAN13(tt.ean); got != tt.want {
+			t.Errorf("ValidEAN13(%q) = %v, want %v", tt.ean, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+-- atlas:txmode none
+
+-- Create index "idx_products_brand_trgm" to table: "products"
+CREATE INDEX CONCURRENTLY "idx_products_brand_trgm" ON "products" USING gin ("brand" gin_trgm_ops);
+-- Create index "idx_products_model_trgm" to table: "products"
+CREATE INDEX CONCURRENTLY "idx_products_model_trgm" ON "products" USING gin ("model" gin_trgm_ops);
@@ -0,0 +1,10 @@
+-- Modify "categories" table
+ALTER TABLE "categories" ADD COLUMN "schema_version" integer NOT NULL DEFAULT 1;
+-- Create "category_schema_versions" table
+CREATE TABLE "category_schema_versions" ("category_id" uuid NOT NULL, "version" integer NOT NULL, "attribute_schema" jsonb NOT NULL DEFAULT '{}', "status" text NOT NULL DEFAULT 'pending', "checked_count" integer NOT NULL DEFAULT 0, "nonconforming_count" integer NOT NULL DEFAULT 0, "nonconforming" jsonb NOT NULL DEFAULT '[]', "error" text NULL, "created_at" timestamptz NULL DEFAULT now(), "started_at" timestamptz NULL, "validated_at" timestamptz NULL, PRIMARY KEY ("category_id", "version"), CONSTRAINT "category_schema_versions_category_id_fkey" FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "idx_category_schema_versions_status" to table: "category_schema_versions"
+CREATE INDEX "idx_category_schema_versions_status" ON "category_schema_versions" ("status") WHERE (status = ANY (ARRAY['pending'::text, 'running'::text]));
+
+-- Record the existing schemas as version 1; the API validates their products on startup
+INSERT INTO "category_schema_versions" ("category_id", "version", "attribute_schema")
+SELECT "id", 1, COALESCE("attribute_schema", '{}') FROM "categories";
@@ -0,0 +1,2 @@
+-- Modify "products" table
+ALTER TABLE "products" ADD COLUMN "quarantined_attributes" jsonb NULL DEFAULT '{}';
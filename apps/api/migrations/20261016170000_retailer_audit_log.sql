@@ -0,0 +1,4 @@
+-- Create "retailer_audit_log" table
+CREATE TABLE "retailer_audit_log" ("id" bigserial NOT NULL, "retailer_id" text NOT NULL, "action" text NOT NULL, "changes" jsonb NOT NULL DEFAULT '{}', "actor" text NULL, "request_id" text NULL, "created_at" timestamptz NOT NULL DEFAULT now(), PRIMARY KEY ("id"));
+-- Create index "idx_retailer_audit_log_retailer" to table: "retailer_audit_log"
+CREATE INDEX "idx_retailer_audit_log_retailer" ON "retailer_audit_log" ("retailer_id", "created_at" DESC);
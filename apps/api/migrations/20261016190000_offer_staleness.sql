@@ -0,0 +1,6 @@
+-- Modify "retailers" table
+ALTER TABLE "retailers" ADD COLUMN "freshness_window_hours" integer NULL;
+-- Modify "offers" table
+ALTER TABLE "offers" ADD COLUMN "stale_at" timestamptz NULL;
+-- Create index "idx_offers_fresh" to table: "offers"
+CREATE INDEX "idx_offers_fresh" ON "offers" ("retailer_id", "scraped_at") WHERE (stale_at IS NULL);
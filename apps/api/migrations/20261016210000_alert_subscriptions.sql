@@ -0,0 +1,19 @@
+-- Create "alert_subscriptions" table
+CREATE TABLE "alert_subscriptions" ("id" uuid NOT NULL DEFAULT uuid_generate_v4(), "product_id" uuid NOT NULL, "variant_id" uuid NULL, "target_price" numeric(10,2) NULL, "drop_percent" integer NULL, "reference_price" numeric(10,2) NULL, "channel" text NOT NULL, "target" text NOT NULL, "active" boolean NULL DEFAULT true, "last_notified_price" numeric(10,2) NULL, "last_notified_at" timestamptz NULL, "created_at" timestamptz NULL DEFAULT now(), "updated_at" timestamptz NULL DEFAULT now(), PRIMARY KEY ("id"), CONSTRAINT "alert_subscriptions_product_id_fkey" FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON UPDATE NO ACTION ON DELETE CASCADE, CONSTRAINT "alert_subscriptions_variant_id_fkey" FOREIGN KEY ("variant_id") REFERENCES "variants" ("id") ON UPDATE NO ACTION ON DELETE CASCADE, CONSTRAINT "alert_subscriptions_check" CHECK ((target_price IS NULL) <> (drop_percent IS NULL)), CONSTRAINT "alert_subscriptions_check1" CHECK ((drop_percent IS NULL) OR (reference_price IS NOT NULL)));
+-- Create index "idx_alert_subscriptions_product" to table: "alert_subscriptions"
+CREATE INDEX "idx_alert_subscriptions_product" ON "alert_subscriptions" ("product_id") WHERE (active = true);
+-- Create "alert_notifications" table
+CREATE TABLE "alert_notifications" ("id" bigserial NOT NULL, "subscription_id" uuid NOT NULL, "retailer_id" text NOT NULL, "price" numeric(10,2) NOT NULL, "status" text NOT NULL, "error" text NULL, "created_at" timestamptz NOT NULL DEFAULT now(), PRIMARY KEY ("id"), CONSTRAINT "alert_notifications_subscription_id_fkey" FOREIGN KEY ("subscription_id") REFERENCES "alert_subscriptions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "idx_alert_notifications_subscription" to table: "alert_notifications"
+CREATE INDEX "idx_alert_notifications_subscription" ON "alert_notifications" ("subscription_id", "created_at" DESC);
+-- Create trigger "trg_alert_subscriptions_updated_at"
+CREATE TRIGGER "trg_alert_subscriptions_updated_at" BEFORE UPDATE ON "alert_subscriptions" FOR EACH ROW EXECUTE FUNCTION "update_updated_at"();
+-- Create "notify_price_history" function
+CREATE FUNCTION "notify_price_history" () RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('price_history', NEW.product_id::text);
+    RETURN NEW;
+END;
+$$;
+-- Create trigger "trg_price_history_notify"
+CREATE TRIGGER "trg_price_history_notify" AFTER INSERT ON "price_history" FOR EACH ROW EXECUTE FUNCTION "notify_price_history"();
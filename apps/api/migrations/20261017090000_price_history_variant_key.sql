@@ -0,0 +1,5 @@
+-- Modify "price_history" table: sibling variants sold by the same retailer
+-- can change at the same instant, so variant_id joins the key. It is
+-- nullable, hence a NULLS NOT DISTINCT unique constraint rather than a
+-- primary key; it keeps "time" as TimescaleDB requires of hypertable keys.
+ALTER TABLE "price_history" DROP CONSTRAINT "price_history_pkey", ADD CONSTRAINT "price_history_key" UNIQUE NULLS NOT DISTINCT ("time", "product_id", "variant_id", "retailer_id");
@@ -0,0 +1,3 @@
+-- Modify "alert_subscriptions" table: failed deliveries are retried after a
+-- growing delay, and the subscription is deactivated after too many in a row
+ALTER TABLE "alert_subscriptions" ADD COLUMN "failed_deliveries" integer NOT NULL DEFAULT 0, ADD COLUMN "retry_at" timestamptz NULL;
@@ -24,6 +24,8 @@ CREATE TABLE categories (
     -- JSONB schema for category-specific attributes
     -- e.g., {"screen_size": "number", "battery_mah": "number", "5g": "boolean"}
     attribute_schema JSONB DEFAULT '{}',
+    -- Current revision in category_schema_versions
+    schema_version INT NOT NULL DEFAULT 1,
     sort_order INT DEFAULT 0,
     active BOOLEAN DEFAULT true,
     created_at TIMESTAMPTZ DEFAULT NOW(),
@@ -34,6 +36,29 @@ CREATE INDEX idx_categories_slug ON categories(slug);
 CREATE INDEX idx_categories_parent ON categories(parent_id);
 CREATE INDEX idx_categories_active ON categories(active) WHERE active = true;
 
+-- Attribute schema history, with the re-validation report of each revision
+CREATE TABLE category_schema_versions (
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    attribute_schema JSONB NOT NULL DEFAULT '{}',
+
+    -- Background re-validation of the category's products
+    status TEXT NOT NULL DEFAULT 'pending',  -- 'pending', 'running', 'completed', 'failed'
+    checked_count INT NOT NULL DEFAULT 0,
+    nonconforming_count INT NOT NULL DEFAULT 0,
+    nonconforming JSONB NOT NULL DEFAULT '[]',  -- [{productId, name, violations}], truncated
+    error TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    validated_at TIMESTAMPTZ,
+
+    PRIMARY KEY (category_id, version)
+);
+
+CREATE INDEX idx_category_schema_versions_status ON category_schema_versions(status)
+    WHERE status IN ('pending', 'running');
+
 -- ============================================
 -- Retailers
 -- ============================================
@@ -50,6 +75,7 @@ CREATE TABLE retailers (
     -- Scraping configuration
     rate_limit_ms INT DEFAULT 2000,
     anti_bot_level TEXT DEFAULT 'medium',  -- 'none', 'light', 'medium', 'heavy'
+    freshness_window_hours INT,  -- Offers expire after this long; NULL = derived from rate limit and priority
     -- Status
     active BOOLEAN DEFAULT true,
     priority INT DEFAULT 0,  -- Higher = more important
@@ -60,6 +86,20 @@ CREATE TABLE retailers (
 CREATE INDEX idx_retailers_active ON retailers(active) WHERE active = true;
 CREATE INDEX idx_retailers_priority ON retailers(priority DESC);
 
+-- Changes made to retailers through the API. No foreign key: entries are
+-- kept after the retailer is deleted.
+CREATE TABLE retailer_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    retailer_id TEXT NOT NULL,
+    action TEXT NOT NULL,  -- 'create', 'update', 'delete'
+    changes JSONB NOT NULL DEFAULT '{}',  -- field -> {"from": ..., "to": ...}
+    actor TEXT,       -- fingerprint of the admin API key that made the change
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_retailer_audit_log_retailer ON retailer_audit_log(retailer_id, created_at DESC);
+
 -- ============================================
 -- Products (from brand websites)
 -- ============================================
@@ -84,6 +124,8 @@ CREATE TABLE products (
     -- Complete specifications (40+ attributes from brand websites)
     -- Stored as JSONB for flexibility across categories
     attributes JSONB DEFAULT '{}',
+    -- Keys the category schema does not declare (UNKNOWN_ATTRIBUTES=quarantine)
+    quarantined_attributes JSONB DEFAULT '{}',
 
     -- Source tracking
     source TEXT NOT NULL DEFAULT 'brand',  -- 'brand', 'manual', 'api'
@@ -114,8 +156,10 @@ CREATE INDEX idx_products_active ON products(active) WHERE active = true;
 -- Enables queries like: attributes @> '{"5g": true}'
 CREATE INDEX idx_products_attributes ON products USING GIN(attributes);
 
--- Trigram index for fuzzy name search
+-- Trigram indexes for fuzzy search on name, brand and model
 CREATE INDEX idx_products_name_trgm ON products USING GIN(name gin_trgm_ops);
+CREATE INDEX idx_products_brand_trgm ON products USING GIN(brand gin_trgm_ops);
+CREATE INDEX idx_products_model_trgm ON products USING GIN(model gin_trgm_ops);
 
 -- ============================================
 -- Product Variants (color, storage combinations)
@@ -190,6 +234,7 @@ CREATE TABLE offers (
 
     -- Timestamps
     scraped_at TIMESTAMPTZ DEFAULT NOW(),
+    stale_at TIMESTAMPTZ,          -- Set when not re-scraped within the retailer's freshness window
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
 
@@ -203,6 +248,7 @@ CREATE INDEX idx_offers_retailer ON offers(retailer_id);
 CREATE INDEX idx_offers_price ON offers(price);
 CREATE INDEX idx_offers_in_stock ON offers(in_stock) WHERE in_stock = true;
 CREATE INDEX idx_offers_scraped_at ON offers(scraped_at DESC);
+CREATE INDEX idx_offers_fresh ON offers(retailer_id, scraped_at) WHERE stale_at IS NULL;
 
 -- ============================================
 -- Price History (for trends and alerts)
@@ -215,7 +261,9 @@ CREATE TABLE price_history (
     price DECIMAL(10, 2) NOT NULL,
     in_stock BOOLEAN DEFAULT true,
 
-    PRIMARY KEY (time, product_id, retailer_id)
+    -- Sibling variants can change at the same instant; NULLS NOT DISTINCT
+    -- keeps one row per product without variants
+    CONSTRAINT price_history_key UNIQUE NULLS NOT DISTINCT (time, product_id, variant_id, retailer_id)
 );
 
 -- Note: Convert to TimescaleDB hypertable after creation:
@@ -290,6 +338,52 @@ CREATE INDEX idx_affiliate_clicks_product ON affiliate_clicks(product_id);
 CREATE INDEX idx_affiliate_clicks_retailer ON affiliate_clicks(retailer_id);
 CREATE INDEX idx_affiliate_clicks_date ON affiliate_clicks(clicked_at DESC);
 
+-- ============================================
+-- Price Alerts
+-- ============================================
+CREATE TABLE alert_subscriptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id UUID REFERENCES variants(id) ON DELETE CASCADE,  -- NULL = any variant
+
+    -- Condition: a target price, or a drop from the reference price
+    target_price DECIMAL(10, 2),
+    drop_percent INT,
+    reference_price DECIMAL(10, 2),  -- Best price when subscribing
+
+    -- Delivery
+    channel TEXT NOT NULL,  -- 'webhook', 'email', 'log'
+    target TEXT NOT NULL,   -- Webhook URL or email address
+
+    -- State: notified again only when the price drops below the last notified one
+    active BOOLEAN DEFAULT true,
+    last_notified_price DECIMAL(10, 2),
+    last_notified_at TIMESTAMPTZ,
+    -- Consecutive failed deliveries; the next attempt waits until retry_at
+    failed_deliveries INT NOT NULL DEFAULT 0,
+    retry_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CHECK ((target_price IS NULL) <> (drop_percent IS NULL)),
+    CHECK (drop_percent IS NULL OR reference_price IS NOT NULL)
+);
+
+CREATE INDEX idx_alert_subscriptions_product ON alert_subscriptions(product_id) WHERE active = true;
+
+CREATE TABLE alert_notifications (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES alert_subscriptions(id) ON DELETE CASCADE,
+    retailer_id TEXT NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    status TEXT NOT NULL,  -- 'sent', 'failed'
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_alert_notifications_subscription ON alert_notifications(subscription_id, created_at DESC);
+
 -- ============================================
 -- Functions & Triggers
 -- ============================================
@@ -328,6 +422,23 @@ CREATE TRIGGER trg_scrape_jobs_updated_at
     BEFORE UPDATE ON scrape_jobs
     FOR EACH ROW EXECUTE FUNCTION update_updated_at();
 
+CREATE TRIGGER trg_alert_subscriptions_updated_at
+    BEFORE UPDATE ON alert_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at();
+
+-- Wake the price alert worker with the product whose price changed
+CREATE OR REPLACE FUNCTION notify_price_history()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('price_history', NEW.product_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_price_history_notify
+    AFTER INSERT ON price_history
+    FOR EACH ROW EXECUTE FUNCTION notify_price_history();
+
 -- ============================================
 -- Initial Seed Data
 -- ============================================
@@ -358,6 +469,10 @@ VALUES (
     true
 ) ON CONFLICT (slug) DO NOTHING;
 
+INSERT INTO category_schema_versions (category_id, version, attribute_schema)
+SELECT id, 1, attribute_schema FROM categories WHERE slug = 'smartphones'
+ON CONFLICT DO NOTHING;
+
 -- Default retailers
 INSERT INTO retailers (id, name, slug, website_url, affiliate_network, rate_limit_ms, anti_bot_level, active, priority)
 VALUES
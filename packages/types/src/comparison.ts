@@ -9,8 +9,16 @@ export interface ComparisonRequest {
   criteria: ComparisonCriterion[];
   filters?: ComparisonFilters;
   limit?: number;
+  scoring?: ScoringStrategy;
+  explain?: boolean;
+  explainLimit?: number;
 }
 
+/**
+ * Strategy computing the composite score of compared products
+ */
+export type ScoringStrategy = 'weighted_sum' | 'topsis' | 'lexicographic';
+
 /**
  * Criterion for Pareto optimization
  */
@@ -18,6 +26,10 @@ export interface ComparisonCriterion {
   attribute: keyof ProductAttributes | 'price';
   weight: number;
   direction: 'minimize' | 'maximize';
+  /** Values this close, in the attribute's unit, tie in the dominance check */
+  tolerance?: number;
+  /** Values this close, as a fraction of the larger one, tie; excludes tolerance */
+  relativeTolerance?: number;
 }
 
 /**
@@ -37,6 +49,7 @@ export interface ComparisonFilters {
  */
 export interface ComparisonResult {
   criteria: ComparisonCriterion[];
+  scoring: ScoringStrategy;
   paretoFrontier: ParetoProduct[];
   dominated: ParetoProduct[];
   totalProducts: number;
@@ -52,7 +65,51 @@ export interface ParetoProduct {
   normalizedScores: NormalizedScores;
   paretoOptimal: boolean;
   dominatedBy: string[];
+  /** Pareto front, 1 being the frontier */
   rank: number;
+  /** Isolation within the front; null for the boundary products of a front */
+  crowdingDistance: number | null;
+  /** Composite score of the scoring strategy, higher being better */
+  score: number;
+  /** Place by composite score, ties sharing it */
+  scoreRank: number;
+  /** Products that would dominate this one without the criteria tolerances */
+  toleratedBy?: string[];
+  /** On the frontier only thanks to the criteria tolerances */
+  keptByTolerance?: boolean;
+  /** Set when the request asks for explanations */
+  explanation?: ParetoExplanation;
+}
+
+/**
+ * Why a product is, or is not, on the Pareto frontier
+ */
+export interface ParetoExplanation {
+  /** Best dominators of a dominated product */
+  dominatedBy?: ProductComparison[];
+  /** Criteria a frontier product is the single best of the frontier on */
+  bestOn?: string[];
+  /** Closest frontier products, compared with a frontier product */
+  tradeOffs?: ProductComparison[];
+}
+
+/**
+ * Another product compared with the explained one, criterion by criterion
+ */
+export interface ProductComparison {
+  productId: string;
+  productName: string;
+  deltas: CriterionDelta[];
+}
+
+/**
+ * Difference on one criterion; the outcome is the other product's
+ */
+export interface CriterionDelta {
+  attribute: string;
+  value: number;
+  delta: number;
+  outcome: 'better' | 'equal' | 'worse';
 }
 
 /**
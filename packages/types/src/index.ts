@@ -39,8 +39,12 @@ export type {
   ComparisonRequest,
   ComparisonCriterion,
   ComparisonFilters,
+  ScoringStrategy,
   ComparisonResult,
   ParetoProduct,
+  ParetoExplanation,
+  ProductComparison,
+  CriterionDelta,
   NormalizedScores,
   ParetoPoint,
   ParetoChartData,